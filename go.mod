@@ -8,6 +8,7 @@ require (
 	github.com/shirou/gopsutil/v3 v3.21.6
 	github.com/stretchr/testify v1.7.0
 	go.bug.st/serial v1.6.1
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
 
 require (
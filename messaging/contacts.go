@@ -0,0 +1,156 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package messaging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notecard"
+)
+
+// ContactID returns the ID under which a contact is kept in the address book, which is
+// the contact's email address if known, otherwise the name
+func ContactID(contact note.MessageContact) string {
+	if contact.Email != "" {
+		return strings.ToLower(contact.Email)
+	}
+	return strings.ToLower(contact.Name)
+}
+
+// Owner returns the contact describing the owner of this device
+func (m *Messenger) Owner() (owner note.MessageContact, found bool, err error) {
+	return m.Contact(note.ContactOwnerNoteID)
+}
+
+// SetOwner sets the contact describing the owner of this device
+func (m *Messenger) SetOwner(owner note.MessageContact) (err error) {
+	return m.putContact(note.ContactOwnerNoteID, owner)
+}
+
+// Contact returns a contact from the address book
+func (m *Messenger) Contact(contactID string) (contact note.MessageContact, found bool, err error) {
+	rsp, err := m.card.TransactionRequest(notecard.Request{Req: notecard.ReqNoteGet, NotefileID: note.ContactStore, NoteID: contactID})
+	if err != nil {
+		if note.ErrorContains(err, note.ErrNoteNoExist) || note.ErrorContains(err, note.ErrNotefileNoExist) {
+			err = nil
+		}
+		return
+	}
+	if rsp.Body != nil {
+		err = note.BodyToObject(rsp.Body, &contact)
+		if err != nil {
+			return
+		}
+	}
+	return contact, true, nil
+}
+
+// Contacts returns every contact in the address book other than the owner, ordered by name
+func (m *Messenger) Contacts() (contacts []note.MessageContact, err error) {
+	notes, err := m.notes(note.ContactStore)
+	if err != nil {
+		return
+	}
+	for noteID, info := range notes {
+		if noteID == note.ContactOwnerNoteID || info.Deleted {
+			continue
+		}
+		var contact note.MessageContact
+		if info.Body != nil {
+			err = note.BodyToObject(info.Body, &contact)
+			if err != nil {
+				return
+			}
+		}
+		contacts = append(contacts, contact)
+	}
+	sort.Slice(contacts, func(i, j int) bool {
+		return strings.ToLower(contacts[i].Name) < strings.ToLower(contacts[j].Name)
+	})
+	return
+}
+
+// SaveContact adds a contact to the address book or replaces it, returning its ID
+func (m *Messenger) SaveContact(contact note.MessageContact) (contactID string, err error) {
+	contactID = ContactID(contact)
+	if contactID == "" {
+		return "", fmt.Errorf("contact must have a name or email address")
+	}
+	if contactID == note.ContactOwnerNoteID {
+		return "", fmt.Errorf("contact ID is reserved: %s", contactID)
+	}
+	return contactID, m.putContact(contactID, contact)
+}
+
+// DeleteContact removes a contact from the address book
+func (m *Messenger) DeleteContact(contactID string) (err error) {
+	_, err = m.card.TransactionRequest(notecard.Request{Req: notecard.ReqNoteDelete, NotefileID: note.ContactStore, NoteID: contactID})
+	return
+}
+
+// putContact writes a contact to the address book
+func (m *Messenger) putContact(contactID string, contact note.MessageContact) (err error) {
+	body, err := note.ObjectToBody(contact)
+	if err != nil {
+		return
+	}
+	_, err = m.card.TransactionRequest(notecard.Request{Req: notecard.ReqNoteUpdate, NotefileID: note.ContactStore, NoteID: contactID, Body: &body})
+	return
+}
+
+// rememberSender records the addresses from which a contact has sent a message, with
+// the time at which each was last active, adding the contact to the address book if
+// it isn't already present
+func (m *Messenger) rememberSender(sender note.MessageContact, when uint32) (err error) {
+	contactID := ContactID(sender)
+	if contactID == "" || contactID == note.ContactOwnerNoteID {
+		return
+	}
+	contact, found, err := m.Contact(contactID)
+	if err != nil {
+		return
+	}
+	if !found {
+		contact = sender
+		contact.StoreTags = nil
+		contact.Addresses = nil
+	}
+	changed := !found
+	for _, address := range sender.Addresses {
+		address.Active = when
+		if mergeAddress(&contact, address) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	return m.putContact(contactID, contact)
+}
+
+// mergeAddress adds an address to a contact, or updates the activity time of an
+// existing address for the same device, returning true if the contact changed
+func mergeAddress(contact *note.MessageContact, address note.MessageAddress) bool {
+	for i := range contact.Addresses {
+		existing := &contact.Addresses[i]
+		if existing.DeviceUID == address.DeviceUID && existing.ProductUID == address.ProductUID && existing.Hub == address.Hub {
+			if address.Active <= existing.Active && (address.DeviceSN == "" || address.DeviceSN == existing.DeviceSN) {
+				return false
+			}
+			if address.Active > existing.Active {
+				existing.Active = address.Active
+			}
+			if address.DeviceSN != "" {
+				existing.DeviceSN = address.DeviceSN
+			}
+			return true
+		}
+	}
+	contact.Addresses = append(contact.Addresses, address)
+	return true
+}
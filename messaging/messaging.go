@@ -0,0 +1,302 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package messaging implements device-to-device messaging on top of a Notecard, using
+// the message and contact structures and notefiles defined in note/message.go.  Messages
+// are sent through the messages.qo outbox, received from the messages.qi inbox, and
+// retained in messages.db, while contacts.db is the address book whose "owner" note
+// describes the owner of the device.
+package messaging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notecard"
+)
+
+// Transactor is the subset of notecard.Context needed to exchange messages
+type Transactor interface {
+	TransactionRequest(req notecard.Request) (rsp notecard.Request, err error)
+}
+
+// Messenger sends, receives and stores messages using a Notecard
+type Messenger struct {
+	card Transactor
+
+	// Now returns the current epoch time, and may be replaced for testing
+	Now func() uint32
+
+	// Retain controls whether sent and received messages are kept in messages.db
+	Retain bool
+}
+
+// New returns a messenger that retains the messages that it sends and receives
+func New(card Transactor) *Messenger {
+	return &Messenger{
+		card:   card,
+		Now:    func() uint32 { return uint32(time.Now().Unix()) },
+		Retain: true,
+	}
+}
+
+// newMessageUID generates a unique ID for a message
+func newMessageUID(now uint32) string {
+	random := make([]byte, 6)
+	_, err := rand.Read(random)
+	if err != nil {
+		return fmt.Sprintf("%d", now)
+	}
+	return fmt.Sprintf("%d-%s", now, hex.EncodeToString(random))
+}
+
+// messageBody converts a message to the body of a note.  The UID isn't stored in the
+// body, because it is the noteID.
+func messageBody(msg note.Message) (body *map[string]interface{}, err error) {
+	msg.UID = ""
+	b, err := note.ObjectToBody(msg)
+	if err != nil {
+		return
+	}
+	return &b, nil
+}
+
+// bodyMessage converts the body of a note to a message
+func bodyMessage(noteID string, body *map[string]interface{}) (msg note.Message, err error) {
+	if body != nil {
+		err = note.BodyToObject(body, &msg)
+		if err != nil {
+			return
+		}
+	}
+	if noteID != "" {
+		msg.UID = noteID
+	}
+	return
+}
+
+// Send queues a message in the outbox to be delivered on the next sync, returning the
+// message as sent.  If the message has no sender the owner contact is used, and if it
+// is retained it is stored with the "sent" store tag.
+func (m *Messenger) Send(msg note.Message) (sent note.Message, err error) {
+	if len(msg.To) == 0 {
+		return sent, fmt.Errorf("message has no recipients")
+	}
+	if msg.From.Name == "" && msg.From.Email == "" && len(msg.From.Addresses) == 0 {
+		var owner note.MessageContact
+		owner, _, err = m.Owner()
+		if err != nil {
+			return
+		}
+		msg.From = owner
+		msg.From.StoreTags = nil
+	}
+	if msg.Sent == 0 {
+		msg.Sent = m.Now()
+	}
+	if msg.UID == "" {
+		msg.UID = newMessageUID(msg.Sent)
+	}
+	msg.StoreTags = nil
+
+	req := notecard.Request{Req: notecard.ReqNoteAdd, NotefileID: note.MessageOutbox}
+	req.Body, err = messageBody(msg)
+	if err != nil {
+		return
+	}
+	_, err = m.card.TransactionRequest(req)
+	if err != nil {
+		return
+	}
+
+	msg.StoreTags = []string{note.MessageSTagSent}
+	if m.Retain {
+		err = m.store(msg)
+	}
+	return msg, err
+}
+
+// Receive removes every message waiting in the inbox, returning them in the order in
+// which they were sent.  Received messages are retained with the "received" store tag,
+// and the addresses from which they came are recorded against the sender's contact.
+func (m *Messenger) Receive() (received []note.Message, err error) {
+	for {
+		var rsp notecard.Request
+		rsp, err = m.card.TransactionRequest(notecard.Request{Req: notecard.ReqNoteGet, NotefileID: note.MessageInbox, Delete: true})
+		if err != nil {
+			if note.ErrorContains(err, note.ErrNoteNoExist) {
+				err = nil
+				break
+			}
+			return
+		}
+		var msg note.Message
+		msg, err = bodyMessage("", rsp.Body)
+		if err != nil {
+			return
+		}
+		if msg.UID == "" {
+			msg.UID = newMessageUID(msg.Sent)
+		}
+		if msg.Received == 0 {
+			msg.Received = m.Now()
+		}
+		msg.StoreTags = []string{note.MessageSTagReceived}
+		if m.Retain {
+			err = m.store(msg)
+			if err != nil {
+				return
+			}
+		}
+		err = m.rememberSender(msg.From, msg.Received)
+		if err != nil {
+			return
+		}
+		received = append(received, msg)
+	}
+	sort.SliceStable(received, func(i, j int) bool { return received[i].Sent < received[j].Sent })
+	return
+}
+
+// store retains a message in the message store
+func (m *Messenger) store(msg note.Message) (err error) {
+	req := notecard.Request{Req: notecard.ReqNoteUpdate, NotefileID: note.MessageStore, NoteID: msg.UID}
+	req.Body, err = messageBody(msg)
+	if err != nil {
+		return
+	}
+	_, err = m.card.TransactionRequest(req)
+	return
+}
+
+// Stored returns the retained messages having the specified store tag, or all retained
+// messages if the tag is empty, most recent first
+func (m *Messenger) Stored(storeTag string) (messages []note.Message, err error) {
+	notes, err := m.notes(note.MessageStore)
+	if err != nil {
+		return
+	}
+	for noteID, info := range notes {
+		if info.Deleted {
+			continue
+		}
+		var msg note.Message
+		msg, err = bodyMessage(noteID, info.Body)
+		if err != nil {
+			return
+		}
+		if storeTag == "" || HasTag(msg.StoreTags, storeTag) {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		ti, tj := messageTime(messages[i]), messageTime(messages[j])
+		if ti != tj {
+			return ti > tj
+		}
+		return messages[i].UID < messages[j].UID
+	})
+	return
+}
+
+// messageTime is the time at which a message was sent or, failing that, received
+func messageTime(msg note.Message) uint32 {
+	if msg.Sent != 0 {
+		return msg.Sent
+	}
+	return msg.Received
+}
+
+// SetStoreTags replaces the store tags of a retained message, such as to file it
+func (m *Messenger) SetStoreTags(uid string, storeTags ...string) (err error) {
+	rsp, err := m.card.TransactionRequest(notecard.Request{Req: notecard.ReqNoteGet, NotefileID: note.MessageStore, NoteID: uid})
+	if err != nil {
+		return
+	}
+	msg, err := bodyMessage(uid, rsp.Body)
+	if err != nil {
+		return
+	}
+	msg.StoreTags = storeTags
+	return m.store(msg)
+}
+
+// DeleteStored removes a retained message
+func (m *Messenger) DeleteStored(uid string) (err error) {
+	_, err = m.card.TransactionRequest(notecard.Request{Req: notecard.ReqNoteDelete, NotefileID: note.MessageStore, NoteID: uid})
+	return
+}
+
+// notes returns every note in a database notefile
+func (m *Messenger) notes(notefileID string) (notes map[string]note.Info, err error) {
+	rsp, err := m.card.TransactionRequest(notecard.Request{Req: notecard.ReqNoteChanges, NotefileID: notefileID})
+	if err != nil {
+		if note.ErrorContains(err, note.ErrNotefileNoExist) {
+			err = nil
+		}
+		return
+	}
+	if rsp.Notes != nil {
+		notes = *rsp.Notes
+	}
+	return
+}
+
+// HasTag determines whether a list of tags contains a tag, ignoring case
+func HasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTag adds a tag to a list of tags if it isn't already present
+func AddTag(tags []string, tag string) []string {
+	if HasTag(tags, tag) {
+		return tags
+	}
+	return append(tags, tag)
+}
+
+// RemoveTag removes a tag from a list of tags
+func RemoveTag(tags []string, tag string) (result []string) {
+	for _, t := range tags {
+		if !strings.EqualFold(t, tag) {
+			result = append(result, t)
+		}
+	}
+	return
+}
+
+// Important determines whether the sender marked a message as important
+func Important(msg note.Message) bool {
+	return HasTag(msg.Tags, note.MessageTagImportant)
+}
+
+// Urgent determines whether the sender marked a message as urgent
+func Urgent(msg note.Message) bool {
+	return HasTag(msg.Tags, note.MessageTagUrgent)
+}
+
+// NewMessage creates a text message to one or more contacts, applying the
+// "important" and "urgent" tags as requested
+func NewMessage(content string, important bool, urgent bool, to ...note.MessageContact) (msg note.Message) {
+	msg.ContentType = note.MessageContentASCII
+	msg.Content = content
+	msg.To = to
+	if important {
+		msg.Tags = AddTag(msg.Tags, note.MessageTagImportant)
+	}
+	if urgent {
+		msg.Tags = AddTag(msg.Tags, note.MessageTagUrgent)
+	}
+	return
+}
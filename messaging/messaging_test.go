@@ -0,0 +1,127 @@
+package messaging
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notecard"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCard is a minimal in-memory Notecard supporting the note requests used here
+type fakeCard struct {
+	queues map[string][]map[string]interface{}
+	dbs    map[string]map[string]map[string]interface{}
+}
+
+func newFakeCard() *fakeCard {
+	return &fakeCard{queues: map[string][]map[string]interface{}{}, dbs: map[string]map[string]map[string]interface{}{}}
+}
+
+func (c *fakeCard) TransactionRequest(req notecard.Request) (rsp notecard.Request, err error) {
+	db := c.dbs[req.NotefileID]
+	switch req.Req {
+	case notecard.ReqNoteAdd:
+		c.queues[req.NotefileID] = append(c.queues[req.NotefileID], *req.Body)
+	case notecard.ReqNoteUpdate:
+		if db == nil {
+			db = map[string]map[string]interface{}{}
+			c.dbs[req.NotefileID] = db
+		}
+		db[req.NoteID] = *req.Body
+	case notecard.ReqNoteGet:
+		if req.NoteID == "" {
+			queue := c.queues[req.NotefileID]
+			if len(queue) == 0 {
+				return rsp, fmt.Errorf("no notes available %s", note.ErrNoteNoExist)
+			}
+			rsp.Body = &queue[0]
+			c.queues[req.NotefileID] = queue[1:]
+			return
+		}
+		body, present := db[req.NoteID]
+		if !present || body == nil {
+			return rsp, fmt.Errorf("note not found %s", note.ErrNoteNoExist)
+		}
+		rsp.Body = &body
+	case notecard.ReqNoteDelete:
+		// Deleted notes are reported by note.changes as tombstones without a body
+		db[req.NoteID] = nil
+	case notecard.ReqNoteChanges:
+		if db == nil {
+			return rsp, fmt.Errorf("notefile not found %s", note.ErrNotefileNoExist)
+		}
+		notes := map[string]note.Info{}
+		for noteID, body := range db {
+			if body == nil {
+				notes[noteID] = note.Info{Deleted: true}
+				continue
+			}
+			b := body
+			notes[noteID] = note.Info{Body: &b}
+		}
+		rsp.Notes = &notes
+	}
+	return
+}
+
+func TestSendReceive(t *testing.T) {
+	card := newFakeCard()
+	m := New(card)
+	m.Now = func() uint32 { return 1000 }
+
+	owner := note.MessageContact{Name: "Ray", Email: "ray@example.com"}
+	require.NoError(t, m.SetOwner(owner))
+	bob := note.MessageContact{Name: "Bob", Email: "Bob@example.com", Addresses: []note.MessageAddress{{DeviceUID: "dev:2"}}}
+
+	sent, err := m.Send(NewMessage("hello", true, false, bob))
+	require.NoError(t, err)
+	require.Equal(t, "Ray", sent.From.Name)
+	require.True(t, Important(sent))
+	require.False(t, Urgent(sent))
+	require.Len(t, card.queues[note.MessageOutbox], 1)
+	_, hasUID := card.queues[note.MessageOutbox][0]["id"]
+	require.False(t, hasUID)
+
+	// Deliver a reply into the inbox
+	reply := NewMessage("hi", false, true, owner)
+	reply.From = bob
+	reply.Sent = 1100
+	body, err := messageBody(reply)
+	require.NoError(t, err)
+	card.queues[note.MessageInbox] = append(card.queues[note.MessageInbox], *body)
+	m.Now = func() uint32 { return 1200 }
+
+	received, err := m.Receive()
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	require.True(t, Urgent(received[0]))
+	require.Equal(t, uint32(1200), received[0].Received)
+
+	stored, err := m.Stored("")
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	require.Equal(t, "hi", stored[0].Content)
+	inbox, err := m.Stored(note.MessageSTagReceived)
+	require.NoError(t, err)
+	require.Len(t, inbox, 1)
+
+	// The sender has been added to the address book
+	contacts, err := m.Contacts()
+	require.NoError(t, err)
+	require.Len(t, contacts, 1)
+	require.Equal(t, uint32(1200), contacts[0].Addresses[0].Active)
+
+	require.NoError(t, m.DeleteStored(sent.UID))
+	stored, err = m.Stored(note.MessageSTagSent)
+	require.NoError(t, err)
+	require.Empty(t, stored)
+	stored, err = m.Stored("")
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	require.Equal(t, "hi", stored[0].Content)
+
+	_, err = m.Send(note.Message{Content: "nobody"})
+	require.Error(t, err)
+}
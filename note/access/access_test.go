@@ -0,0 +1,61 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	node, err := Parse("app:read|dev:*&file:update")
+	require.NoError(t, err)
+	require.Equal(t, "app:read|dev:*&file:update", node.String())
+	or, isOr := node.(Or)
+	require.True(t, isOr)
+	require.Len(t, or.Operands, 2)
+	_, isAnd := or.Operands[1].(And)
+	require.True(t, isAnd)
+
+	node, err = Parse("(app:read|app:update)&dev:read")
+	require.NoError(t, err)
+	require.Equal(t, "(app:read|app:update)&dev:read", node.String())
+
+	for _, bad := range []string{"", "app", "app:fly", "bogus:read", "dev:create", "app:read|", "(app:read", "app:read)"} {
+		_, err = Parse(bad)
+		require.True(t, note.ErrorContains(err, note.ErrSyntax), bad)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	p := NewPrincipal()
+	require.NoError(t, p.Grant("app:1234", "app:read,dev:read,file:*"))
+	require.NoError(t, p.Grant("app:*:dev:abcd", "dev:update"))
+	require.Error(t, p.Grant("app:1234", "dev:fly"))
+
+	allowed, err := p.Allowed("app:1234", "read")
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	allowed, _ = p.Allowed("app:5678", "read")
+	require.False(t, allowed)
+
+	allowed, _ = p.Allowed("app:1234:dev:abcd", "read&update")
+	require.True(t, allowed)
+
+	allowed, _ = p.Allowed("app:1234:dev:efgh", "read&update")
+	require.False(t, allowed)
+
+	allowed, _ = p.Allowed("app:1234:dev:efgh:file:data.qo", "delete")
+	require.True(t, allowed)
+
+	allowed, _ = p.Allowed("app:1234:dev:efgh", "*")
+	require.False(t, allowed)
+
+	allowed, err = p.Authorize("app:1234:dev:abcd", "app:read&dev:update&file:*")
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	allowed, _ = p.Authorize("app:1234:dev:abcd", "app:delete|dev:delete")
+	require.False(t, allowed)
+}
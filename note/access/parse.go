@@ -0,0 +1,258 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package access parses and evaluates the permission grammar defined in note/access.go,
+// in which permissions such as "dev:read" are combined with "&" (all are required) and
+// "|" (any is sufficient), and in which "*" stands for every valid action.
+package access
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blues/note-go/note"
+)
+
+// validActions maps each resource type to the set of actions that may be performed on it
+var validActions = map[string]map[string]bool{}
+
+func init() {
+	for _, list := range []string{
+		note.ACValidActionsApp,
+		note.ACValidActionsDev,
+		note.ACValidActionsFile,
+		note.ACValidActionsAccount,
+		note.ACValidActionsRoute,
+		note.ACValidActionsNotecard,
+		note.ACValidActionsFirmware,
+	} {
+		for _, permission := range strings.Split(list, ",") {
+			parts := strings.SplitN(permission, note.ACResourceSep, 2)
+			if validActions[parts[0]] == nil {
+				validActions[parts[0]] = map[string]bool{}
+			}
+			validActions[parts[0]][parts[1]] = true
+		}
+	}
+}
+
+// ActionAll is the wildcard action, standing for every action valid on a resource type
+const ActionAll = "*"
+
+// Permission is a single action on a type of resource, such as "dev:read"
+type Permission struct {
+	Resource string
+	Action   string
+}
+
+// String returns the permission in its textual form
+func (p Permission) String() string {
+	return p.Resource + note.ACResourceSep + p.Action
+}
+
+// ParsePermission parses and validates a single "resource:action" permission
+func ParsePermission(s string) (p Permission, err error) {
+	parts := strings.SplitN(strings.TrimSpace(s), note.ACResourceSep, 2)
+	if len(parts) != 2 {
+		return p, fmt.Errorf("permission must be of the form resource:action: %s %s", s, note.ErrSyntax)
+	}
+	p = Permission{Resource: parts[0], Action: parts[1]}
+	actions, known := validActions[p.Resource]
+	if !known {
+		return Permission{}, fmt.Errorf("unknown resource type: %s %s", s, note.ErrSyntax)
+	}
+	if p.Action != ActionAll && !actions[p.Action] {
+		return Permission{}, fmt.Errorf("action not valid on resource type: %s %s", s, note.ErrSyntax)
+	}
+	return p, nil
+}
+
+// Node is a node of a parsed permission expression
+type Node interface {
+	// Eval evaluates the expression, given a function that reports whether a single
+	// permission is held
+	Eval(has func(Permission) bool) bool
+	// String returns the expression in its textual form
+	String() string
+}
+
+// Term is a leaf of a permission expression
+type Term struct {
+	Permission Permission
+}
+
+// Eval reports whether the permission is held
+func (t Term) Eval(has func(Permission) bool) bool {
+	return has(t.Permission)
+}
+
+// String returns the permission
+func (t Term) String() string {
+	return t.Permission.String()
+}
+
+// And requires all of its operands to be satisfied
+type And struct {
+	Operands []Node
+}
+
+// Eval reports whether all operands are satisfied
+func (n And) Eval(has func(Permission) bool) bool {
+	for _, operand := range n.Operands {
+		if !operand.Eval(has) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the expression, parenthesizing nested alternatives
+func (n And) String() string {
+	var parts []string
+	for _, operand := range n.Operands {
+		if _, isOr := operand.(Or); isOr {
+			parts = append(parts, "("+operand.String()+")")
+		} else {
+			parts = append(parts, operand.String())
+		}
+	}
+	return strings.Join(parts, note.ACActionAnd)
+}
+
+// Or requires any of its operands to be satisfied
+type Or struct {
+	Operands []Node
+}
+
+// Eval reports whether any operand is satisfied
+func (n Or) Eval(has func(Permission) bool) bool {
+	for _, operand := range n.Operands {
+		if operand.Eval(has) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the expression
+func (n Or) String() string {
+	var parts []string
+	for _, operand := range n.Operands {
+		parts = append(parts, operand.String())
+	}
+	return strings.Join(parts, note.ACActionOr)
+}
+
+// parser is a recursive-descent parser in which "&" binds more tightly than "|"
+type parser struct {
+	src      string
+	pos      int
+	resource string
+}
+
+// Parse parses a permission expression such as "app:read|dev:*&file:update",
+// validating each permission against the table of valid actions
+func Parse(s string) (Node, error) {
+	return parse(s, "")
+}
+
+// ParseActions parses an expression of bare actions such as "read&update", each of
+// which is applied to the specified resource type
+func ParseActions(resource string, s string) (Node, error) {
+	return parse(s, resource)
+}
+
+func parse(s string, resource string) (node Node, err error) {
+	p := &parser{src: s, resource: resource}
+	node, err = p.or()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected '%c' at offset %d: %s %s", p.src[p.pos], p.pos, s, note.ErrSyntax)
+	}
+	return node, nil
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) accept(op string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], op) {
+		p.pos += len(op)
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (Node, error) {
+	var operands []Node
+	for {
+		operand, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		if !p.accept(note.ACActionOr) {
+			break
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return Or{Operands: operands}, nil
+}
+
+func (p *parser) and() (Node, error) {
+	var operands []Node
+	for {
+		operand, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		if !p.accept(note.ACActionAnd) {
+			break
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return And{Operands: operands}, nil
+}
+
+func (p *parser) term() (Node, error) {
+	if p.accept("(") {
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ')' at offset %d: %s %s", p.pos, p.src, note.ErrSyntax)
+		}
+		return node, nil
+	}
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune("&|() ", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	if text == "" {
+		return nil, fmt.Errorf("permission expected at offset %d: %s %s", start, p.src, note.ErrSyntax)
+	}
+	if p.resource != "" {
+		text = p.resource + note.ACResourceSep + text
+	}
+	permission, err := ParsePermission(text)
+	if err != nil {
+		return nil, err
+	}
+	return Term{Permission: permission}, nil
+}
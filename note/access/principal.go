@@ -0,0 +1,180 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package access
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blues/note-go/note"
+)
+
+// Segment is one "type:id" component of a resource URN such as
+// app:xxx-xxxx-xxxx-xxxx:dev:xxxxxxxxxxx:file:xxxx
+type Segment struct {
+	Type string
+	ID   string
+}
+
+// ParseURN splits a resource URN into its segments.  An ID of "*" stands for all
+// resources of that type, and a trailing type with no ID is treated the same way.
+func ParseURN(urn string) (segments []Segment, err error) {
+	if urn == "" {
+		return
+	}
+	parts := strings.Split(urn, note.ACResourceSep)
+	for i := 0; i < len(parts); i += 2 {
+		segment := Segment{Type: parts[i], ID: ActionAll}
+		if i+1 < len(parts) && parts[i+1] != "" {
+			segment.ID = parts[i+1]
+		}
+		if _, known := validActions[segment.Type]; !known {
+			return nil, fmt.Errorf("unknown resource type in %s %s", urn, note.ErrSyntax)
+		}
+		segments = append(segments, segment)
+	}
+	return
+}
+
+// covers determines whether a grant's resource pattern applies to a resource, which
+// is the case when each of the pattern's segments matches the corresponding leading
+// segment of the resource.  An empty pattern applies to every resource.
+func covers(pattern []Segment, resource []Segment) bool {
+	if len(pattern) > len(resource) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i].Type != resource[i].Type {
+			return false
+		}
+		if pattern[i].ID != ActionAll && pattern[i].ID != resource[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+// scope truncates a resource URN just after the segment of the specified type, so that
+// a permission on, say, a device is evaluated against that device rather than against
+// a notefile within it.  If the type doesn't appear the resource is returned unchanged.
+func scope(resource []Segment, resourceType string) []Segment {
+	for i := range resource {
+		if resource[i].Type == resourceType {
+			return resource[:i+1]
+		}
+	}
+	return resource
+}
+
+// grant is a set of permissions held on the resources matching a pattern
+type grant struct {
+	pattern     []Segment
+	permissions []Permission
+}
+
+// Principal is a user, token, or other actor holding permissions on resources
+type Principal struct {
+	grants []grant
+}
+
+// NewPrincipal returns a principal holding no permissions
+func NewPrincipal() *Principal {
+	return &Principal{}
+}
+
+// Grant gives the principal a comma-separated list of permissions, such as
+// "dev:read,file:*", on every resource matching the URN pattern.  The pattern
+// "app:xxxx" covers the project and everything within it, "app:*:dev:*" covers
+// every device in every project, and "" covers everything.
+func (p *Principal) Grant(pattern string, permissions string) (err error) {
+	g := grant{}
+	g.pattern, err = ParseURN(pattern)
+	if err != nil {
+		return
+	}
+	for _, s := range strings.Split(permissions, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		var permission Permission
+		permission, err = ParsePermission(s)
+		if err != nil {
+			return
+		}
+		g.permissions = append(g.permissions, permission)
+	}
+	p.grants = append(p.grants, g)
+	return
+}
+
+// Has determines whether the principal holds a permission on a resource, where a
+// held action of "*" implies every action on that type of resource
+func (p *Principal) Has(resource []Segment, permission Permission) bool {
+	scoped := scope(resource, permission.Resource)
+	for _, g := range p.grants {
+		if !covers(g.pattern, scoped) {
+			continue
+		}
+		for _, held := range g.permissions {
+			if held.Resource != permission.Resource {
+				continue
+			}
+			if held.Action == ActionAll || held.Action == permission.Action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Satisfies determines whether the principal holds the permissions required by a
+// parsed expression on a resource.  A required action of "*" is satisfied only if
+// every valid action on that type of resource is held.
+func (p *Principal) Satisfies(resource []Segment, required Node) bool {
+	return required.Eval(func(permission Permission) bool {
+		if permission.Action != ActionAll {
+			return p.Has(resource, permission)
+		}
+		for action := range validActions[permission.Resource] {
+			if !p.Has(resource, Permission{Resource: permission.Resource, Action: action}) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Allowed determines whether the principal may perform an action on a resource.  The
+// action may be a combination such as "read&update", and applies to the most specific
+// type of resource named by the URN, so that the action "update" on the resource
+// "app:xxxx:dev:yyyy" requires the permission "dev:update".
+func (p *Principal) Allowed(resourceURN string, action string) (allowed bool, err error) {
+	resource, err := ParseURN(resourceURN)
+	if err != nil {
+		return
+	}
+	if len(resource) == 0 {
+		return false, fmt.Errorf("resource must be specified %s", note.ErrSyntax)
+	}
+	required, err := ParseActions(resource[len(resource)-1].Type, action)
+	if err != nil {
+		return
+	}
+	return p.Satisfies(resource, required), nil
+}
+
+// Authorize determines whether the principal holds the permissions described by an
+// expression such as "app:read|dev:*&file:update" on a resource
+func (p *Principal) Authorize(resourceURN string, expression string) (allowed bool, err error) {
+	resource, err := ParseURN(resourceURN)
+	if err != nil {
+		return
+	}
+	required, err := Parse(expression)
+	if err != nil {
+		return
+	}
+	return p.Satisfies(resource, required), nil
+}
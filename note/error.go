@@ -0,0 +1,236 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package note error.go contains a structured error type for errors carrying keywords
+package note
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error is an error returned by the notecard or notehub, decomposed into the request
+// that failed, the human-readable message, and the {keywords} embedded within it.
+// Errors may be tested for keywords with errors.Is, using the sentinels below.
+type Error struct {
+	// Request is the type of request that failed, such as "note.add", if known
+	Request string
+	// Message is the error text with all keywords removed
+	Message string
+	// Keywords are the error keywords, such as "{note-noexist}", in order of appearance
+	Keywords []string
+
+	text  string
+	cause error
+}
+
+// Error returns the original error text, including the request and keywords
+func (e *Error) Error() string {
+	return e.text
+}
+
+// Unwrap returns the error from which this one was parsed, if any
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether the error matches the target.  A target created by ErrorKeyword
+// matches any error carrying that keyword; otherwise the error text must match.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if t.text == "" {
+		for _, keyword := range t.Keywords {
+			if !e.HasKeyword(keyword) {
+				return false
+			}
+		}
+		return len(t.Keywords) > 0
+	}
+	return e.text == t.text
+}
+
+// HasKeyword determines whether the error carries the specified {keyword}
+func (e *Error) HasKeyword(keyword string) bool {
+	for _, k := range e.Keywords {
+		if k == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorKeyword returns a sentinel that matches, via errors.Is, any error carrying the keyword
+func ErrorKeyword(keyword string) *Error {
+	return &Error{Keywords: []string{keyword}}
+}
+
+// Sentinels for each of the error keywords, for use with errors.Is
+var (
+	ErrTimeoutSentinel                  = ErrorKeyword(ErrTimeout)
+	ErrInternalTimeoutSentinel          = ErrorKeyword(ErrInternalTimeout)
+	ErrRouteTimeoutSentinel             = ErrorKeyword(ErrRouteTimeout)
+	ErrClosedSentinel                   = ErrorKeyword(ErrClosed)
+	ErrFileNoExistSentinel              = ErrorKeyword(ErrFileNoExist)
+	ErrNotefileNameSentinel             = ErrorKeyword(ErrNotefileName)
+	ErrNotefileInUseSentinel            = ErrorKeyword(ErrNotefileInUse)
+	ErrNotefileExistsSentinel           = ErrorKeyword(ErrNotefileExists)
+	ErrNotefileNoExistSentinel          = ErrorKeyword(ErrNotefileNoExist)
+	ErrNotefileQueueDisallowedSentinel  = ErrorKeyword(ErrNotefileQueueDisallowed)
+	ErrNoteNoExistSentinel              = ErrorKeyword(ErrNoteNoExist)
+	ErrNoteExistsSentinel               = ErrorKeyword(ErrNoteExists)
+	ErrTrackerNoExistSentinel           = ErrorKeyword(ErrTrackerNoExist)
+	ErrTrackerExistsSentinel            = ErrorKeyword(ErrTrackerExists)
+	ErrTransportConnectedSentinel       = ErrorKeyword(ErrTransportConnected)
+	ErrTransportDisconnectedSentinel    = ErrorKeyword(ErrTransportDisconnected)
+	ErrTransportConnectingSentinel      = ErrorKeyword(ErrTransportConnecting)
+	ErrTransportConnectFailureSentinel  = ErrorKeyword(ErrTransportConnectFailure)
+	ErrTransportConnectedClosedSentinel = ErrorKeyword(ErrTransportConnectedClosed)
+	ErrTransportWaitServiceSentinel     = ErrorKeyword(ErrTransportWaitService)
+	ErrTransportWaitDataSentinel        = ErrorKeyword(ErrTransportWaitData)
+	ErrTransportWaitGatewaySentinel     = ErrorKeyword(ErrTransportWaitGateway)
+	ErrTransportWaitModuleSentinel      = ErrorKeyword(ErrTransportWaitModule)
+	ErrNetworkSentinel                  = ErrorKeyword(ErrNetwork)
+	ErrRegistrationFailureSentinel      = ErrorKeyword(ErrRegistrationFailure)
+	ErrExtendedNetworkFailureSentinel   = ErrorKeyword(ErrExtendedNetworkFailure)
+	ErrExtendedServiceFailureSentinel   = ErrorKeyword(ErrExtendedServiceFailure)
+	ErrHostUnreachableSentinel          = ErrorKeyword(ErrHostUnreachable)
+	ErrDFUNotReadySentinel              = ErrorKeyword(ErrDFUNotReady)
+	ErrDFUInProgressSentinel            = ErrorKeyword(ErrDFUInProgress)
+	ErrAuthSentinel                     = ErrorKeyword(ErrAuth)
+	ErrTicketSentinel                   = ErrorKeyword(ErrTicket)
+	ErrHubNoHandlerSentinel             = ErrorKeyword(ErrHubNoHandler)
+	ErrIdleSentinel                     = ErrorKeyword(ErrIdle)
+	ErrNtnIdleSentinel                  = ErrorKeyword(ErrNtnIdle)
+	ErrDeviceNotFoundSentinel           = ErrorKeyword(ErrDeviceNotFound)
+	ErrDeviceNotSpecifiedSentinel       = ErrorKeyword(ErrDeviceNotSpecified)
+	ErrDeviceIdSentinel                 = ErrorKeyword(ErrDeviceId)
+	ErrDeviceDisabledSentinel           = ErrorKeyword(ErrDeviceDisabled)
+	ErrProductNotFoundSentinel          = ErrorKeyword(ErrProductNotFound)
+	ErrProductNotSpecifiedSentinel      = ErrorKeyword(ErrProductNotSpecified)
+	ErrAppNotFoundSentinel              = ErrorKeyword(ErrAppNotFound)
+	ErrAppNotSpecifiedSentinel          = ErrorKeyword(ErrAppNotSpecified)
+	ErrAppDeletedSentinel               = ErrorKeyword(ErrAppDeleted)
+	ErrAppExistsSentinel                = ErrorKeyword(ErrAppExists)
+	ErrFleetNotFoundSentinel            = ErrorKeyword(ErrFleetNotFound)
+	ErrCardIoSentinel                   = ErrorKeyword(ErrCardIo)
+	ErrCardHeartbeatSentinel            = ErrorKeyword(ErrCardHeartbeat)
+	ErrAccessDeniedSentinel             = ErrorKeyword(ErrAccessDenied)
+	ErrDoNotRouteSentinel               = ErrorKeyword(ErrDoNotRoute)
+	ErrAddToFleetSentinel               = ErrorKeyword(ErrAddToFleet)
+	ErrRemoveFromFleetSentinel          = ErrorKeyword(ErrRemoveFromFleet)
+	ErrLeaveFleetAloneSentinel          = ErrorKeyword(ErrLeaveFleetAlone)
+	ErrWebPayloadSentinel               = ErrorKeyword(ErrWebPayload)
+	ErrHubModeSentinel                  = ErrorKeyword(ErrHubMode)
+	ErrTemplateIncompatibleSentinel     = ErrorKeyword(ErrTemplateIncompatible)
+	ErrSyntaxSentinel                   = ErrorKeyword(ErrSyntax)
+	ErrIncompatibleSentinel             = ErrorKeyword(ErrIncompatible)
+	ErrReqNotSupportedSentinel          = ErrorKeyword(ErrReqNotSupported)
+	ErrTooBigSentinel                   = ErrorKeyword(ErrTooBig)
+	ErrJsonSentinel                     = ErrorKeyword(ErrJson)
+	ErrGPSInactiveSentinel              = ErrorKeyword(ErrGPSInactive)
+	ErrDeviceDelay5Sentinel             = ErrorKeyword(ErrDeviceDelay5)
+	ErrDeviceDelay10Sentinel            = ErrorKeyword(ErrDeviceDelay10)
+	ErrDeviceDelay15Sentinel            = ErrorKeyword(ErrDeviceDelay15)
+	ErrDeviceDelay20Sentinel            = ErrorKeyword(ErrDeviceDelay20)
+	ErrDeviceDelay30Sentinel            = ErrorKeyword(ErrDeviceDelay30)
+	ErrDeviceDelay60Sentinel            = ErrorKeyword(ErrDeviceDelay60)
+)
+
+// ErrorKeywords returns the {keywords} embedded in an error string, in order of appearance
+func ErrorKeywords(errstr string) (keywords []string) {
+	for {
+		open := strings.Index(errstr, "{")
+		if open < 0 {
+			return
+		}
+		end := strings.Index(errstr[open:], "}")
+		if end < 0 {
+			return
+		}
+		keyword := errstr[open : open+end+1]
+		if isKeyword(keyword) {
+			keywords = append(keywords, keyword)
+		}
+		errstr = errstr[open+end+1:]
+	}
+}
+
+// isKeyword determines whether a {braced} string is shaped like an error keyword, so
+// that JSON fragments quoted within error messages aren't mistaken for keywords
+func isKeyword(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+	for _, c := range s[1 : len(s)-1] {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// isRequestType determines whether a string is shaped like a request type, such as "note.add"
+func isRequestType(s string) bool {
+	if s == "" || !strings.Contains(s, ".") || strings.HasPrefix(s, ".") || strings.HasSuffix(s, ".") {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '.' && c != '_' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// cleanMessage removes keywords and surrounding whitespace from error text
+func cleanMessage(errstr string) string {
+	return strings.TrimSpace(ErrorString(ErrorClean(errors.New(errstr))))
+}
+
+// NewError creates an error for a failed request, given the error text returned by
+// the notecard or notehub.  The request type may be empty.
+func NewError(request string, errstr string) *Error {
+	e := &Error{
+		Request:  request,
+		Message:  cleanMessage(errstr),
+		Keywords: ErrorKeywords(errstr),
+		text:     errstr,
+	}
+	if request != "" {
+		e.text = request + ": " + errstr
+	}
+	return e
+}
+
+// ParseError parses an error string, recognizing a leading "request.type: " prefix
+func ParseError(errstr string) *Error {
+	e := &Error{
+		Message:  cleanMessage(errstr),
+		Keywords: ErrorKeywords(errstr),
+		text:     errstr,
+	}
+	if parts := strings.SplitN(errstr, ": ", 2); len(parts) == 2 && isRequestType(parts[0]) {
+		e.Request = parts[0]
+		e.Message = cleanMessage(parts[1])
+	}
+	return e
+}
+
+// ErrorFrom converts any error to an *Error, returning it unchanged if it already is
+// one (or wraps one), and otherwise parsing its text.  It returns nil for nil.
+func ErrorFrom(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) && e.text == err.Error() {
+		return e
+	}
+	e = ParseError(err.Error())
+	e.cause = err
+	return e
+}
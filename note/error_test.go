@@ -0,0 +1,57 @@
+package note
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestError(t *testing.T) {
+	e := NewError("note.get", "note not found {note-noexist} {io}")
+	require.Equal(t, "note.get: note not found {note-noexist} {io}", e.Error())
+	require.Equal(t, "note.get", e.Request)
+	require.Equal(t, "note not found", e.Message)
+	require.Equal(t, []string{ErrNoteNoExist, ErrCardIo}, e.Keywords)
+
+	var err error = e
+	require.True(t, errors.Is(err, ErrNoteNoExistSentinel))
+	require.True(t, errors.Is(err, ErrCardIoSentinel))
+	require.False(t, errors.Is(err, ErrTimeoutSentinel))
+	require.True(t, errors.Is(fmt.Errorf("wrapped: %w", err), ErrNoteNoExistSentinel))
+	require.True(t, ErrorContains(err, ErrNoteNoExist))
+
+	parsed := ParseError(e.Error())
+	require.Equal(t, "note.get", parsed.Request)
+	require.Equal(t, "note not found", parsed.Message)
+
+	parsed = ParseError("error opening port: no such file {io}")
+	require.Equal(t, "", parsed.Request)
+	require.True(t, errors.Is(parsed, ErrCardIoSentinel))
+
+	plain := fmt.Errorf("serial I/O timeout %s", ErrCardIo)
+	converted := ErrorFrom(plain)
+	require.Equal(t, plain.Error(), converted.Error())
+	require.True(t, errors.Is(converted, ErrCardIoSentinel))
+	require.Same(t, converted, ErrorFrom(converted))
+	require.Nil(t, ErrorFrom(nil))
+
+	require.Empty(t, ErrorKeywords(`bad body {"a":1}`))
+}
+
+func TestErrorJSON(t *testing.T) {
+	require.Equal(t, `{"err":"boom"}`, string(ErrorJSON("", errors.New("boom"))))
+	require.Equal(t, `{"err":"bad \"thing\""}`, string(ErrorJSON(`bad "thing"`, nil)))
+	require.Equal(t, `{"err":"note.get: missing {note-noexist}"}`, string(ErrorJSON("note.get", fmt.Errorf("missing %s", ErrNoteNoExist))))
+
+	rspJSON := ErrorJSONWithDetails("", errors.New("too big "+ErrTooBig), map[string]interface{}{"max": 10})
+	reply, err := ErrorReplyFromJSON(rspJSON)
+	require.NoError(t, err)
+	require.Equal(t, "10", fmt.Sprintf("%v", reply.Details["max"]))
+	require.True(t, errors.Is(reply.AsError(), ErrTooBigSentinel))
+
+	reply, err = ErrorReplyFromJSON([]byte(`{"status":"ok"}`))
+	require.NoError(t, err)
+	require.Nil(t, reply.AsError())
+}
@@ -247,14 +247,53 @@ func ErrorString(err error) string {
 	return fmt.Sprintf("%s", err)
 }
 
+// ErrorReply is the body of a notecard-style reply to a request that failed
+type ErrorReply struct {
+	Err     string                 `json:"err"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// AsError returns the reply's error as an *Error, or nil if there is none
+func (reply ErrorReply) AsError() error {
+	if reply.Err == "" {
+		return nil
+	}
+	return ParseError(reply.Err)
+}
+
+// errorText combines an optional message and an optional error into a single string
+func errorText(message string, err error) string {
+	if message == "" {
+		return ErrorString(err)
+	}
+	if err == nil {
+		return message
+	}
+	return message + ": " + ErrorString(err)
+}
+
 // ErrorJSON returns a JSON object with nothing but an error code, and with an optional message
 func ErrorJSON(message string, err error) (rspJSON []byte) {
-	if message == "" {
-		rspJSON = []byte(fmt.Sprintf("{\"err\":\"%q\"}", err))
-	} else if err == nil {
-		rspJSON = []byte(fmt.Sprintf("{\"err\":\"%q\"}", message))
-	} else {
-		rspJSON = []byte(fmt.Sprintf("{\"err\":\"%q: %q\"}", message, err))
+	return ErrorJSONWithDetails(message, err, nil)
+}
+
+// ErrorJSONWithDetails returns a JSON object with an error code, an optional message,
+// and optional structured details.  Error keywords are preserved so that the reply may
+// be tested with ErrorContains or errors.Is once it has been parsed.
+func ErrorJSONWithDetails(message string, err error, details map[string]interface{}) (rspJSON []byte) {
+	reply := ErrorReply{Err: errorText(message, err), Details: details}
+	rspJSON, e := JSONMarshal(reply)
+	if e != nil {
+		// The details couldn't be marshaled, but the error text always can be
+		reply.Details = nil
+		rspJSON, _ = JSONMarshal(reply)
 	}
 	return
 }
+
+// ErrorReplyFromJSON parses a reply, such as one generated by ErrorJSON.  Replies that
+// contain other fields in addition to "err" are accepted, and those fields are ignored.
+func ErrorReplyFromJSON(rspJSON []byte) (reply ErrorReply, err error) {
+	err = JSONUnmarshal(rspJSON, &reply)
+	return
+}
@@ -0,0 +1,285 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package note location.go contains helpers for choosing among the several kinds of
+// location attached to events and sessions, and for reasoning about them geometrically
+package note
+
+import (
+	"math"
+)
+
+// LocationTypeGPS is a location reported by the device's GPS/GNSS
+const LocationTypeGPS = "gps"
+
+// LocationTypeTriangulated is a location computed from scanned towers and access points
+const LocationTypeTriangulated = "triangulated"
+
+// LocationTypeTower is the location of the cell tower serving the session
+const LocationTypeTower = "tower"
+
+// EarthRadiusMeters is the mean radius of the earth used for distance calculations
+const EarthRadiusMeters = 6371008.8
+
+// Location is a single location of a known type
+type Location struct {
+	Type     string  `json:"type,omitempty"`
+	When     int64   `json:"when,omitempty"`
+	Lat      float64 `json:"lat,omitempty"`
+	Lon      float64 `json:"lon,omitempty"`
+	OLC      string  `json:"olc,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Country  string  `json:"country,omitempty"`
+	TimeZone string  `json:"timezone,omitempty"`
+}
+
+// Valid returns true if the location has coordinates.  (Exactly 0,0 is treated as
+// missing, as it is everywhere else that these structures are omitempty.)
+func (loc Location) Valid() bool {
+	return loc.Lat != 0 || loc.Lon != 0
+}
+
+// locationFromOLC fills in missing coordinates from an open location code, if possible
+func locationFromOLC(loc Location) Location {
+	if !loc.Valid() && loc.OLC != "" {
+		lat, lon, err := OLCToLatLon(loc.OLC)
+		if err == nil {
+			loc.Lat = lat
+			loc.Lon = lon
+		}
+	}
+	return loc
+}
+
+// Locations returns each of the valid locations attached to an event, in order of
+// decreasing precision: GPS, then triangulation, then tower
+func (event *Event) Locations() (locs []Location) {
+	candidates := []Location{
+		locationFromOLC(Location{
+			Type:     LocationTypeGPS,
+			When:     event.WhereWhen,
+			Lat:      event.WhereLat,
+			Lon:      event.WhereLon,
+			OLC:      event.Where,
+			Name:     event.WhereLocation,
+			Country:  event.WhereCountry,
+			TimeZone: event.WhereTimeZone,
+		}),
+		{
+			Type:     LocationTypeTriangulated,
+			When:     event.TriWhen,
+			Lat:      event.TriLat,
+			Lon:      event.TriLon,
+			Name:     event.TriLocation,
+			Country:  event.TriCountry,
+			TimeZone: event.TriTimeZone,
+		},
+		{
+			Type:     LocationTypeTower,
+			When:     event.TowerWhen,
+			Lat:      event.TowerLat,
+			Lon:      event.TowerLon,
+			Name:     event.TowerLocation,
+			Country:  event.TowerCountry,
+			TimeZone: event.TowerTimeZone,
+		},
+	}
+	for _, loc := range candidates {
+		if loc.Valid() {
+			locs = append(locs, loc)
+		}
+	}
+	return
+}
+
+// PreferredLocation returns the best location for an event.  If the notehub has already
+// chosen one (as indicated by BestLocationType) that location is returned, otherwise
+// the most precise of the locations attached to the event is chosen.
+func (event *Event) PreferredLocation() (loc Location, found bool) {
+	if event.BestLocationType != "" && (event.BestLat != 0 || event.BestLon != 0) {
+		return Location{
+			Type:     event.BestLocationType,
+			When:     event.BestLocationWhen,
+			Lat:      event.BestLat,
+			Lon:      event.BestLon,
+			Name:     event.BestLocation,
+			Country:  event.BestCountry,
+			TimeZone: event.BestTimeZone,
+		}, true
+	}
+	locs := event.Locations()
+	if len(locs) == 0 {
+		return
+	}
+	return locs[0], true
+}
+
+// towerToLocation converts a TowerLocation to a Location of the specified type
+func towerToLocation(locType string, tower TowerLocation) Location {
+	return locationFromOLC(Location{
+		Type:     locType,
+		When:     tower.When,
+		Lat:      tower.Lat,
+		Lon:      tower.Lon,
+		OLC:      tower.OLC,
+		Name:     tower.Name,
+		Country:  tower.CountryCode,
+		TimeZone: tower.TimeZone,
+	})
+}
+
+// Locations returns each of the valid locations known for a session, in order of
+// decreasing precision: GPS, then triangulation, then tower
+func (s *DeviceSession) Locations() (locs []Location) {
+	candidates := []Location{
+		locationFromOLC(Location{
+			Type:     LocationTypeGPS,
+			When:     s.WhereWhen,
+			Lat:      s.WhereLat,
+			Lon:      s.WhereLon,
+			OLC:      s.WhereOLC,
+			Name:     s.WhereLocation,
+			Country:  s.WhereCountry,
+			TimeZone: s.WhereTimeZone,
+		}),
+		towerToLocation(LocationTypeTriangulated, s.Tri),
+		towerToLocation(LocationTypeTower, s.Tower),
+	}
+	for _, loc := range candidates {
+		if loc.Valid() {
+			locs = append(locs, loc)
+		}
+	}
+	return
+}
+
+// PreferredLocation returns the most precise location known for a session
+func (s *DeviceSession) PreferredLocation() (loc Location, found bool) {
+	locs := s.Locations()
+	if len(locs) == 0 {
+		return
+	}
+	return locs[0], true
+}
+
+// Distance returns the great-circle distance in meters between two points, using
+// the haversine formula
+func Distance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusMeters * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// DistanceTo returns the distance in meters between two locations
+func (loc Location) DistanceTo(other Location) float64 {
+	return Distance(loc.Lat, loc.Lon, other.Lat, other.Lon)
+}
+
+// Geofence is a region against which locations may be tested
+type Geofence interface {
+	Contains(lat float64, lon float64) bool
+}
+
+// BoundingBox is a rectangular region.  If West is greater than East the box is
+// taken to span the antimeridian.
+type BoundingBox struct {
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	North float64 `json:"north"`
+	East  float64 `json:"east"`
+}
+
+// Contains determines whether a point lies within the bounding box
+func (box BoundingBox) Contains(lat float64, lon float64) bool {
+	if lat < box.South || lat > box.North {
+		return false
+	}
+	if box.West <= box.East {
+		return lon >= box.West && lon <= box.East
+	}
+	return lon >= box.West || lon <= box.East
+}
+
+// CircleGeofence is the region within a radius of a center point
+type CircleGeofence struct {
+	Lat          float64 `json:"lat"`
+	Lon          float64 `json:"lon"`
+	RadiusMeters float64 `json:"radius"`
+}
+
+// Contains determines whether a point lies within the circle
+func (fence CircleGeofence) Contains(lat float64, lon float64) bool {
+	return Distance(fence.Lat, fence.Lon, lat, lon) <= fence.RadiusMeters
+}
+
+// PolygonGeofence is the region enclosed by a polygon whose vertices are given as
+// latitude/longitude pairs.  Edges are treated as straight lines in degree space,
+// which is accurate for the small regions for which geofences are generally used.
+type PolygonGeofence struct {
+	Vertices [][2]float64 `json:"vertices"`
+}
+
+// Contains determines whether a point lies within the polygon, by ray casting
+func (fence PolygonGeofence) Contains(lat float64, lon float64) bool {
+	inside := false
+	n := len(fence.Vertices)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		latI, lonI := fence.Vertices[i][0], fence.Vertices[i][1]
+		latJ, lonJ := fence.Vertices[j][0], fence.Vertices[j][1]
+		if (latI > lat) != (latJ > lat) && lon < (lonJ-lonI)*(lat-latI)/(latJ-latI)+lonI {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// GeofenceTransition describes how a device moved relative to a geofence
+type GeofenceTransition int
+
+const (
+	// GeofenceOutside means the device was outside the geofence and still is
+	GeofenceOutside GeofenceTransition = iota
+	// GeofenceInside means the device was inside the geofence and still is
+	GeofenceInside
+	// GeofenceEntered means the device has moved from outside the geofence to inside
+	GeofenceEntered
+	// GeofenceExited means the device has moved from inside the geofence to outside
+	GeofenceExited
+)
+
+// String returns a string representation of the transition
+func (t GeofenceTransition) String() string {
+	switch t {
+	case GeofenceOutside:
+		return "outside"
+	case GeofenceInside:
+		return "inside"
+	case GeofenceEntered:
+		return "entered"
+	case GeofenceExited:
+		return "exited"
+	default:
+		return "invalid"
+	}
+}
+
+// EvaluateGeofence compares a device's previous and current locations against a
+// geofence.  An invalid previous location is treated as having been outside.
+func EvaluateGeofence(fence Geofence, previous Location, current Location) GeofenceTransition {
+	wasInside := previous.Valid() && fence.Contains(previous.Lat, previous.Lon)
+	isInside := current.Valid() && fence.Contains(current.Lat, current.Lon)
+	switch {
+	case isInside && wasInside:
+		return GeofenceInside
+	case isInside:
+		return GeofenceEntered
+	case wasInside:
+		return GeofenceExited
+	default:
+		return GeofenceOutside
+	}
+}
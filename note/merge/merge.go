@@ -0,0 +1,214 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package merge reconciles two versions of the same Note, as happens when a
+// replicated database notefile has been modified on more than one endpoint.
+package merge
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+
+	"github.com/blues/note-go/note"
+)
+
+// Relation describes how two versions of a note relate to one another
+type Relation int
+
+const (
+	// RelationEqual means that both versions have seen exactly the same updates
+	RelationEqual Relation = iota
+	// RelationBefore means that the first version is an ancestor of the second
+	RelationBefore
+	// RelationAfter means that the first version supersedes the second
+	RelationAfter
+	// RelationConcurrent means that each version has updates that the other has not seen
+	RelationConcurrent
+)
+
+// String returns a string representation of the relation
+func (r Relation) String() string {
+	switch r {
+	case RelationEqual:
+		return "equal"
+	case RelationBefore:
+		return "before"
+	case RelationAfter:
+		return "after"
+	case RelationConcurrent:
+		return "concurrent"
+	default:
+		return "invalid"
+	}
+}
+
+// Strategy resolves two concurrently-modified versions of a note.  It returns the
+// version that should become current, and the version (if any) that should be
+// retained in the note's conflict list.
+type Strategy func(local note.Note, remote note.Note) (winner note.Note, conflict *note.Note)
+
+// versions returns the highest sequence number seen from each endpoint in a note's history
+func versions(n note.Note) (v map[string]int32) {
+	v = map[string]int32{}
+	if n.Histories == nil {
+		return
+	}
+	for _, h := range *n.Histories {
+		if seq, present := v[h.EndpointID]; !present || h.Sequence > seq {
+			v[h.EndpointID] = h.Sequence
+		}
+	}
+	return
+}
+
+// dominates returns true if every update recorded in b is also recorded in a
+func dominates(a map[string]int32, b map[string]int32) bool {
+	for endpointID, seq := range b {
+		if a[endpointID] < seq {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare determines the causal relationship between two versions of a note by
+// examining the per-endpoint sequence numbers recorded in their histories.
+func Compare(a note.Note, b note.Note) Relation {
+	va := versions(a)
+	vb := versions(b)
+	aCoversB := dominates(va, vb)
+	bCoversA := dominates(vb, va)
+	switch {
+	case aCoversB && bCoversA:
+		return RelationEqual
+	case aCoversB:
+		return RelationAfter
+	case bCoversA:
+		return RelationBefore
+	default:
+		return RelationConcurrent
+	}
+}
+
+// Merge combines two versions of the same note.  If one version has seen all of
+// the updates of the other it simply wins; otherwise the strategy is consulted
+// and the losing version, if any, is appended to the merged note's conflicts.
+// In all cases the histories and conflicts of both versions are unioned.
+func Merge(local note.Note, remote note.Note, strategy Strategy) (merged note.Note) {
+	if strategy == nil {
+		strategy = LastWriterWins
+	}
+
+	var conflict *note.Note
+	switch Compare(local, remote) {
+	case RelationEqual, RelationAfter:
+		merged = local.Dup()
+	case RelationBefore:
+		merged = remote.Dup()
+	case RelationConcurrent:
+		merged, conflict = strategy(local, remote)
+	}
+
+	histories := mergeHistories(local.Histories, remote.Histories)
+	if len(histories) == 0 {
+		merged.Histories = nil
+	} else {
+		merged.Histories = &histories
+	}
+
+	var conflicts []note.Note
+	conflicts = appendConflicts(conflicts, local.GetConflicts()...)
+	conflicts = appendConflicts(conflicts, remote.GetConflicts()...)
+	if conflict != nil {
+		conflicts = appendConflicts(conflicts, *conflict)
+	}
+	for i := 0; i < len(conflicts); i++ {
+		if sameContent(conflicts[i], merged) {
+			conflicts = append(conflicts[:i], conflicts[i+1:]...)
+			i--
+		}
+	}
+	if len(conflicts) == 0 {
+		merged.Conflicts = nil
+	} else {
+		merged.Conflicts = &conflicts
+	}
+
+	merged.Updates = maxInt32(local.Updates, remote.Updates)
+	if local.Change > remote.Change {
+		merged.Change = local.Change
+	} else {
+		merged.Change = remote.Change
+	}
+
+	return
+}
+
+// mergeHistories unions two history lists, most recent first
+func mergeHistories(a *[]note.History, b *[]note.History) (histories []note.History) {
+	type key struct {
+		endpointID string
+		sequence   int32
+	}
+	seen := map[key]int{}
+	add := func(list *[]note.History) {
+		if list == nil {
+			return
+		}
+		for _, h := range *list {
+			k := key{h.EndpointID, h.Sequence}
+			if i, present := seen[k]; present {
+				if h.When > histories[i].When {
+					histories[i] = h
+				}
+				continue
+			}
+			seen[k] = len(histories)
+			histories = append(histories, h)
+		}
+	}
+	add(a)
+	add(b)
+	sort.SliceStable(histories, func(i, j int) bool {
+		if histories[i].When != histories[j].When {
+			return histories[i].When > histories[j].When
+		}
+		return histories[i].Sequence > histories[j].Sequence
+	})
+	return
+}
+
+// sameContent determines whether two versions of a note carry the same user data
+func sameContent(a note.Note, b note.Note) bool {
+	return a.Deleted == b.Deleted &&
+		bytes.Equal(a.Payload, b.Payload) &&
+		reflect.DeepEqual(a.Body, b.Body)
+}
+
+// appendConflicts appends conflicting versions that aren't already present,
+// stripping their own nested conflicts because those are carried at top level
+func appendConflicts(conflicts []note.Note, candidates ...note.Note) []note.Note {
+	for _, c := range candidates {
+		c.Conflicts = nil
+		duplicate := false
+		for _, existing := range conflicts {
+			if sameContent(existing, c) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts
+}
+
+func maxInt32(a int32, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,61 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func version(body map[string]interface{}, histories ...note.History) note.Note {
+	return note.Note{Body: body, Histories: &histories}
+}
+
+func TestCompare(t *testing.T) {
+	a := version(nil, note.History{EndpointID: "dev", Sequence: 2, When: 20}, note.History{EndpointID: "1", Sequence: 1, When: 10})
+	b := version(nil, note.History{EndpointID: "1", Sequence: 1, When: 10})
+	c := version(nil, note.History{EndpointID: "1", Sequence: 3, When: 30})
+
+	require.Equal(t, RelationEqual, Compare(a, a))
+	require.Equal(t, RelationAfter, Compare(a, b))
+	require.Equal(t, RelationBefore, Compare(b, a))
+	require.Equal(t, RelationConcurrent, Compare(a, c))
+}
+
+func TestMergeLastWriterWins(t *testing.T) {
+	local := version(map[string]interface{}{"temp": 1}, note.History{EndpointID: "dev", Sequence: 1, When: 100})
+	remote := version(map[string]interface{}{"temp": 2}, note.History{EndpointID: "1", Sequence: 1, When: 200})
+
+	merged := Merge(local, remote, LastWriterWins)
+	require.Equal(t, 2, merged.Body["temp"])
+	require.True(t, merged.HasConflicts())
+	require.Equal(t, 1, merged.GetConflicts()[0].Body["temp"])
+	require.Len(t, *merged.Histories, 2)
+	require.Equal(t, "1", merged.EndpointID())
+
+	// Merging again must not duplicate the conflict
+	again := Merge(merged, remote, LastWriterWins)
+	require.Len(t, again.GetConflicts(), 1)
+}
+
+func TestMergeEndpointPriority(t *testing.T) {
+	local := version(map[string]interface{}{"v": "card"}, note.History{EndpointID: "dev", Sequence: 1, When: 100})
+	remote := version(map[string]interface{}{"v": "hub"}, note.History{EndpointID: "1", Sequence: 1, When: 200})
+
+	merged := Merge(local, remote, EndpointPriority("dev"))
+	require.Equal(t, "card", merged.Body["v"])
+}
+
+func TestMergeFieldMerge(t *testing.T) {
+	local := version(map[string]interface{}{"a": 1, "b": 1}, note.History{EndpointID: "dev", Sequence: 1, When: 100})
+	remote := version(map[string]interface{}{"a": 1, "c": 3}, note.History{EndpointID: "1", Sequence: 1, When: 200})
+
+	merged := Merge(local, remote, FieldMerge(nil))
+	require.Equal(t, map[string]interface{}{"a": 1, "b": 1, "c": 3}, merged.Body)
+	require.False(t, merged.HasConflicts())
+
+	remote.Body["b"] = 2
+	merged = Merge(local, remote, FieldMerge(nil))
+	require.Equal(t, 2, merged.Body["b"])
+	require.True(t, merged.HasConflicts())
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package merge
+
+import (
+	"reflect"
+
+	"github.com/blues/note-go/note"
+)
+
+// LastWriterWins selects the version whose most recent history entry has the later
+// modification time.  Ties are broken by endpoint ID so that every replica arrives
+// at the same answer.
+func LastWriterWins(local note.Note, remote note.Note) (winner note.Note, conflict *note.Note) {
+	if newer(local, remote) {
+		return resolved(local, remote)
+	}
+	return resolved(remote, local)
+}
+
+// EndpointPriority returns a strategy in which the version last modified by the
+// endpoint appearing earliest in the list wins.  If neither endpoint is listed, or
+// both versions were last modified by equally-ranked endpoints, the strategy falls
+// back to last-writer-wins.
+func EndpointPriority(endpointIDs ...string) Strategy {
+	rank := map[string]int{}
+	for i, endpointID := range endpointIDs {
+		if _, present := rank[endpointID]; !present {
+			rank[endpointID] = i
+		}
+	}
+	ranking := func(n note.Note) int {
+		if r, present := rank[n.EndpointID()]; present {
+			return r
+		}
+		return len(endpointIDs)
+	}
+	return func(local note.Note, remote note.Note) (winner note.Note, conflict *note.Note) {
+		localRank := ranking(local)
+		remoteRank := ranking(remote)
+		switch {
+		case localRank < remoteRank:
+			return resolved(local, remote)
+		case remoteRank < localRank:
+			return resolved(remote, local)
+		default:
+			return LastWriterWins(local, remote)
+		}
+	}
+}
+
+// FieldMerge returns a strategy that merges the bodies of the two versions field by
+// field, as the union of their fields.  Without a common base version it can't tell
+// whether a field was added to one version or removed from the other, so a field
+// present in only one version is kept, resurrecting a field that was deleted on the
+// other side.  Fields present in both with different values are taken from the version
+// preferred by the fallback strategy, as is the payload if both have one, and the other
+// version is then recorded as a conflict.  Versions that disagree about deletion can't
+// be merged, so the preferred version wins and the other is recorded as a conflict.
+func FieldMerge(fallback Strategy) Strategy {
+	if fallback == nil {
+		fallback = LastWriterWins
+	}
+	return func(local note.Note, remote note.Note) (winner note.Note, conflict *note.Note) {
+		preferred, other := pick(fallback, local, remote)
+
+		// Deletion can't be merged at the field level
+		if local.Deleted != remote.Deleted {
+			return resolved(preferred, other)
+		}
+
+		overlap := false
+		var body map[string]interface{}
+		if preferred.Body != nil || other.Body != nil {
+			body = map[string]interface{}{}
+		}
+		for k, v := range other.Body {
+			body[k] = v
+		}
+		for k, v := range preferred.Body {
+			if ov, present := other.Body[k]; present && !reflect.DeepEqual(ov, v) {
+				overlap = true
+			}
+			body[k] = v
+		}
+
+		winner = preferred.Dup()
+		winner.Body = body
+		if len(winner.Payload) == 0 {
+			winner.Payload = other.Payload
+		} else if len(other.Payload) != 0 && !reflect.DeepEqual(winner.Payload, other.Payload) {
+			overlap = true
+		}
+		if overlap {
+			loser := other.Dup()
+			conflict = &loser
+		}
+		return
+	}
+}
+
+// pick runs a strategy and reports which of the two input versions it preferred
+func pick(strategy Strategy, local note.Note, remote note.Note) (preferred note.Note, other note.Note) {
+	winner, _ := strategy(local, remote)
+	if winner.EndpointID() == remote.EndpointID() && winner.When() == remote.When() &&
+		(winner.EndpointID() != local.EndpointID() || winner.When() != local.When()) {
+		return remote, local
+	}
+	return local, remote
+}
+
+// newer determines whether a was modified more recently than b
+func newer(a note.Note, b note.Note) bool {
+	if a.When() != b.When() {
+		return a.When() > b.When()
+	}
+	return a.EndpointID() > b.EndpointID()
+}
+
+// resolved returns the winner, and the loser as a conflict unless it carries the same content
+func resolved(winner note.Note, loser note.Note) (note.Note, *note.Note) {
+	if sameContent(winner, loser) {
+		return winner.Dup(), nil
+	}
+	conflict := loser.Dup()
+	return winner.Dup(), &conflict
+}
@@ -4,6 +4,11 @@
 
 package note
 
+import (
+	"fmt"
+	"strings"
+)
+
 // TrackNotefile is the hard-wired notefile that the notecard can use for tracking the device
 const TrackNotefile = "_track.qo"
 
@@ -94,3 +99,111 @@ type NotefileDesc struct {
 	TemplateFormat  uint32       `json:"template_format,omitempty"`
 	TemplatePort    uint16       `json:"template_port,omitempty"`
 }
+
+// NotefileIDMaxLen is the longest notefileID accepted by the Notecard
+const NotefileIDMaxLen = 64
+
+// NotefileKind indicates whether a notefile has queue or database semantics
+type NotefileKind int
+
+const (
+	// NotefileKindQueue notefiles hold notes only until they have been synchronized
+	NotefileKindQueue NotefileKind = iota
+	// NotefileKindDatabase notefiles hold persistent notes addressed by note ID
+	NotefileKindDatabase
+)
+
+// NotefileDirection indicates the direction in which a notefile is synchronized
+type NotefileDirection int
+
+const (
+	// NotefileDirectionLocal notefiles are never synchronized with the notehub
+	NotefileDirectionLocal NotefileDirection = iota
+	// NotefileDirectionOutbound notefiles flow from the device to the notehub
+	NotefileDirectionOutbound
+	// NotefileDirectionInbound notefiles flow from the notehub to the device
+	NotefileDirectionInbound
+	// NotefileDirectionBidirectional notefiles are replicated in both directions
+	NotefileDirectionBidirectional
+)
+
+// NotefileType describes the semantics encoded in a notefileID
+type NotefileType struct {
+	NotefileID string
+	// Name is the notefileID without its type suffix
+	Name string
+	// Suffix is the type suffix, including the leading "."
+	Suffix    string
+	Kind      NotefileKind
+	Direction NotefileDirection
+	// Secure notefiles are only synchronized over encrypted sessions
+	Secure bool
+	// System notefiles, whose names begin with an underscore, are reserved for the Notecard and Notehub
+	System bool
+	// Templatable notefiles may have a note.template applied to them
+	Templatable bool
+}
+
+// notefileTypes maps each valid suffix to its semantics
+var notefileTypes = map[string]NotefileType{
+	".qo":  {Kind: NotefileKindQueue, Direction: NotefileDirectionOutbound, Templatable: true},
+	".qos": {Kind: NotefileKindQueue, Direction: NotefileDirectionOutbound, Secure: true, Templatable: true},
+	".qi":  {Kind: NotefileKindQueue, Direction: NotefileDirectionInbound, Templatable: true},
+	".qis": {Kind: NotefileKindQueue, Direction: NotefileDirectionInbound, Secure: true, Templatable: true},
+	".db":  {Kind: NotefileKindDatabase, Direction: NotefileDirectionBidirectional, Templatable: true},
+	".dbs": {Kind: NotefileKindDatabase, Direction: NotefileDirectionBidirectional, Secure: true},
+	".dbx": {Kind: NotefileKindDatabase, Direction: NotefileDirectionLocal, Templatable: true},
+}
+
+// ParseNotefileID validates a notefileID the way the Notecard does, and classifies
+// it according to its suffix and whether or not it is a system notefile
+func ParseNotefileID(notefileID string) (t NotefileType, err error) {
+	if notefileID == "" {
+		return t, fmt.Errorf("notefile name must be specified %s", ErrNotefileName)
+	}
+	if len(notefileID) > NotefileIDMaxLen {
+		return t, fmt.Errorf("notefile name is longer than %d characters: %s %s", NotefileIDMaxLen, notefileID, ErrNotefileName)
+	}
+	for _, c := range notefileID {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '_' && c != '-' && c != '.' {
+			return t, fmt.Errorf("notefile name contains invalid character '%c': %s %s", c, notefileID, ErrNotefileName)
+		}
+	}
+	dot := strings.Index(notefileID, ".")
+	if dot < 0 {
+		return t, fmt.Errorf("notefile name must end in a type such as .qo or .db: %s %s", notefileID, ErrNotefileName)
+	}
+	if dot == 0 {
+		return t, fmt.Errorf("notefile name must precede its type: %s %s", notefileID, ErrNotefileName)
+	}
+	suffix := notefileID[dot:]
+	t, valid := notefileTypes[suffix]
+	if !valid {
+		return NotefileType{}, fmt.Errorf("notefile type %s is not one of .qo .qos .qi .qis .db .dbs .dbx: %s %s", suffix, notefileID, ErrNotefileName)
+	}
+	t.NotefileID = notefileID
+	t.Name = notefileID[:dot]
+	t.Suffix = suffix
+	t.System = strings.HasPrefix(notefileID, "_")
+	return t, nil
+}
+
+// IsQueue returns true if notes are removed from the notefile once they have been synchronized
+func (t NotefileType) IsQueue() bool {
+	return t.Kind == NotefileKindQueue
+}
+
+// IsDatabase returns true if notes in the notefile are persistent and individually addressable
+func (t NotefileType) IsDatabase() bool {
+	return t.Kind == NotefileKindDatabase
+}
+
+// IsOutbound returns true if notes in the notefile are sent from the device to the notehub
+func (t NotefileType) IsOutbound() bool {
+	return t.Direction == NotefileDirectionOutbound || t.Direction == NotefileDirectionBidirectional
+}
+
+// IsInbound returns true if notes in the notefile are sent from the notehub to the device
+func (t NotefileType) IsInbound() bool {
+	return t.Direction == NotefileDirectionInbound || t.Direction == NotefileDirectionBidirectional
+}
@@ -0,0 +1,32 @@
+package note
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNotefileID(t *testing.T) {
+	nt, err := ParseNotefileID(TrackNotefile)
+	require.NoError(t, err)
+	require.True(t, nt.System)
+	require.True(t, nt.IsQueue())
+	require.Equal(t, NotefileDirectionOutbound, nt.Direction)
+	require.Equal(t, "_track", nt.Name)
+
+	nt, err = ParseNotefileID(EnvNotefile)
+	require.NoError(t, err)
+	require.True(t, nt.IsDatabase())
+	require.True(t, nt.Secure)
+	require.False(t, nt.Templatable)
+
+	nt, err = ParseNotefileID("sensors.dbx")
+	require.NoError(t, err)
+	require.False(t, nt.IsInbound())
+	require.False(t, nt.IsOutbound())
+
+	for _, bad := range []string{"", "data", ".qo", "data.txt", "my data.qo", "data.qo.db"} {
+		_, err = ParseNotefileID(bad)
+		require.True(t, ErrorContains(err, ErrNotefileName), bad)
+	}
+}
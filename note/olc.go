@@ -0,0 +1,239 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package note olc.go implements the Open Location Code encoding used for locations
+// throughout the notecard and notehub (see https://github.com/google/open-location-code)
+package note
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// OLC encoding parameters
+const (
+	olcSeparator         = '+'
+	olcSeparatorPosition = 8
+	olcPadding           = '0'
+	olcAlphabet          = "23456789CFGHJMPQRVWX"
+	olcEncodingBase      = 20
+	olcLatMax            = 90
+	olcLonMax            = 180
+	olcPairCodeLen       = 10
+	olcGridCodeLen       = 5
+	olcGridRows          = 5
+	olcGridCols          = 4
+	olcMaxCodeLen        = olcPairCodeLen + olcGridCodeLen
+	olcPairFirstPlace    = 160000 // olcEncodingBase ^ (olcPairCodeLen/2 - 1)
+	olcPairPrecision     = 8000   // olcEncodingBase ^ 3
+	olcGridLatFirstPlace = 625    // olcGridRows ^ (olcGridCodeLen - 1)
+	olcGridLonFirstPlace = 256    // olcGridCols ^ (olcGridCodeLen - 1)
+	olcFinalLatPrecision = olcPairPrecision * 3125
+	olcFinalLonPrecision = olcPairPrecision * 1024
+)
+
+// OLCDefaultCodeLen is the code length used by the notecard, roughly 14m x 14m
+const OLCDefaultCodeLen = 10
+
+// OLCArea is the rectangle described by an Open Location Code
+type OLCArea struct {
+	LatLo   float64
+	LonLo   float64
+	LatHi   float64
+	LonHi   float64
+	CodeLen int
+}
+
+// Center returns the center of the area, which is the conventional decoded location
+func (area OLCArea) Center() (lat float64, lon float64) {
+	lat = math.Min((area.LatLo+area.LatHi)/2, olcLatMax)
+	lon = math.Min((area.LonLo+area.LonHi)/2, olcLonMax)
+	return
+}
+
+// OLCEncode encodes a location as an Open Location Code of the specified length, which
+// must be 2, 4, 6, 8, or any length from 10 to 15.  A length of 0 uses the default.
+func OLCEncode(lat float64, lon float64, codeLen int) (code string, err error) {
+	if codeLen == 0 {
+		codeLen = OLCDefaultCodeLen
+	}
+	if codeLen > olcMaxCodeLen {
+		codeLen = olcMaxCodeLen
+	}
+	if codeLen < 2 || (codeLen < olcPairCodeLen && codeLen%2 == 1) {
+		return "", fmt.Errorf("invalid open location code length: %d", codeLen)
+	}
+
+	// Clip latitude and normalize longitude.  Codes for the north pole would otherwise
+	// describe an area that lies beyond it, so nudge them down by one code's height.
+	lat = math.Min(math.Max(lat, -olcLatMax), olcLatMax)
+	for lon < -olcLonMax {
+		lon += 2 * olcLonMax
+	}
+	for lon >= olcLonMax {
+		lon -= 2 * olcLonMax
+	}
+	if lat == olcLatMax {
+		lat -= olcLatPrecision(codeLen)
+	}
+
+	// Work in integers to avoid floating point rounding differences between implementations
+	latVal := int64(math.Round((lat+olcLatMax)*olcFinalLatPrecision*1e6) / 1e6)
+	lonVal := int64(math.Round((lon+olcLonMax)*olcFinalLonPrecision*1e6) / 1e6)
+
+	digits := make([]byte, olcMaxCodeLen)
+	if codeLen > olcPairCodeLen {
+		for i := 0; i < olcGridCodeLen; i++ {
+			digits[olcMaxCodeLen-i-1] = olcAlphabet[(latVal%olcGridRows)*olcGridCols+lonVal%olcGridCols]
+			latVal /= olcGridRows
+			lonVal /= olcGridCols
+		}
+	} else {
+		latVal /= 3125
+		lonVal /= 1024
+	}
+	for i := 0; i < olcPairCodeLen/2; i++ {
+		digits[olcPairCodeLen-i*2-1] = olcAlphabet[lonVal%olcEncodingBase]
+		digits[olcPairCodeLen-i*2-2] = olcAlphabet[latVal%olcEncodingBase]
+		latVal /= olcEncodingBase
+		lonVal /= olcEncodingBase
+	}
+
+	// Pad short codes and insert the separator
+	var b strings.Builder
+	if codeLen >= olcSeparatorPosition {
+		b.Write(digits[:olcSeparatorPosition])
+		b.WriteByte(olcSeparator)
+		b.Write(digits[olcSeparatorPosition:codeLen])
+	} else {
+		b.Write(digits[:codeLen])
+		b.WriteString(strings.Repeat(string(olcPadding), olcSeparatorPosition-codeLen))
+		b.WriteByte(olcSeparator)
+	}
+	return b.String(), nil
+}
+
+// olcLatPrecision returns the height in degrees of a code of the specified length
+func olcLatPrecision(codeLen int) float64 {
+	if codeLen <= olcPairCodeLen {
+		return math.Pow(olcEncodingBase, float64(codeLen/-2+2))
+	}
+	return math.Pow(olcEncodingBase, -3) / math.Pow(olcGridRows, float64(codeLen-olcPairCodeLen))
+}
+
+// OLCIsValid determines whether a string is a syntactically valid Open Location Code,
+// which may be either full or short (missing leading digits)
+func OLCIsValid(code string) bool {
+	code = strings.ToUpper(code)
+	sep := strings.IndexByte(code, olcSeparator)
+	if sep < 0 || sep != strings.LastIndexByte(code, olcSeparator) || sep > olcSeparatorPosition || sep%2 == 1 {
+		return false
+	}
+	if pad := strings.IndexByte(code, olcPadding); pad >= 0 {
+		// Padding is only permitted in full codes, in pairs, immediately before the separator
+		if pad == 0 || pad%2 == 1 || sep != olcSeparatorPosition || len(code) != sep+1 {
+			return false
+		}
+		if strings.Trim(code[pad:sep], string(olcPadding)) != "" {
+			return false
+		}
+	}
+	if len(code)-sep-1 == 1 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		if c != olcSeparator && c != olcPadding && strings.IndexByte(olcAlphabet, c) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// OLCIsFull determines whether a string is a valid full Open Location Code, which
+// unlike a short code describes a single location without needing a reference point
+func OLCIsFull(code string) bool {
+	if !OLCIsValid(code) {
+		return false
+	}
+	code = strings.ToUpper(code)
+	if strings.IndexByte(code, olcSeparator) != olcSeparatorPosition {
+		return false
+	}
+	if strings.IndexByte(olcAlphabet, code[0])*olcEncodingBase >= 2*olcLatMax {
+		return false
+	}
+	if len(code) > 1 && strings.IndexByte(olcAlphabet, code[1])*olcEncodingBase >= 2*olcLonMax {
+		return false
+	}
+	return true
+}
+
+// OLCDecode decodes a full Open Location Code into the area that it describes
+func OLCDecode(code string) (area OLCArea, err error) {
+	if !OLCIsFull(code) {
+		return area, fmt.Errorf("not a valid full open location code: %s", code)
+	}
+	code = strings.ToUpper(code)
+	code = strings.Replace(code, string(olcSeparator), "", 1)
+	code = strings.TrimRight(code, string(olcPadding))
+	if len(code) > olcMaxCodeLen {
+		code = code[:olcMaxCodeLen]
+	}
+
+	// Decode the pairs
+	latVal := int64(-olcLatMax * olcPairPrecision)
+	lonVal := int64(-olcLonMax * olcPairPrecision)
+	placeValue := int64(olcPairFirstPlace)
+	digits := len(code)
+	if digits > olcPairCodeLen {
+		digits = olcPairCodeLen
+	}
+	for i := 0; i < digits; i += 2 {
+		latVal += int64(strings.IndexByte(olcAlphabet, code[i])) * placeValue
+		lonVal += int64(strings.IndexByte(olcAlphabet, code[i+1])) * placeValue
+		if i < digits-2 {
+			placeValue /= olcEncodingBase
+		}
+	}
+	latPrecision := float64(placeValue) / olcPairPrecision
+	lonPrecision := float64(placeValue) / olcPairPrecision
+
+	// Decode the grid refinement
+	var gridLat, gridLon int64
+	if len(code) > olcPairCodeLen {
+		rowValue := int64(olcGridLatFirstPlace)
+		colValue := int64(olcGridLonFirstPlace)
+		for i := olcPairCodeLen; i < len(code); i++ {
+			d := int64(strings.IndexByte(olcAlphabet, code[i]))
+			gridLat += (d / olcGridCols) * rowValue
+			gridLon += (d % olcGridCols) * colValue
+			if i < len(code)-1 {
+				rowValue /= olcGridRows
+				colValue /= olcGridCols
+			}
+		}
+		latPrecision = float64(rowValue) / olcFinalLatPrecision
+		lonPrecision = float64(colValue) / olcFinalLonPrecision
+	}
+
+	area.LatLo = float64(latVal)/olcPairPrecision + float64(gridLat)/olcFinalLatPrecision
+	area.LonLo = float64(lonVal)/olcPairPrecision + float64(gridLon)/olcFinalLonPrecision
+	area.LatHi = area.LatLo + latPrecision
+	area.LonHi = area.LonLo + lonPrecision
+	area.CodeLen = len(code)
+	return area, nil
+}
+
+// OLCToLatLon is a convenience method that decodes a full Open Location Code to the
+// latitude and longitude of the center of the area that it describes
+func OLCToLatLon(code string) (lat float64, lon float64, err error) {
+	area, err := OLCDecode(code)
+	if err != nil {
+		return
+	}
+	lat, lon = area.Center()
+	return
+}
@@ -0,0 +1,61 @@
+package note
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOLC(t *testing.T) {
+	code, err := OLCEncode(47.365590, 8.524997, 10)
+	require.NoError(t, err)
+	require.Equal(t, "8FVC9G8F+6X", code)
+
+	code, err = OLCEncode(20.375, 2.775, 6)
+	require.NoError(t, err)
+	require.Equal(t, "7FG49Q00+", code)
+
+	lat, lon, err := OLCToLatLon("8FVC9G8F+6X")
+	require.NoError(t, err)
+	require.InDelta(t, 47.365590, lat, 0.0001)
+	require.InDelta(t, 8.524997, lon, 0.0001)
+
+	code, err = OLCEncode(-33.8567844, 151.213108, 15)
+	require.NoError(t, err)
+	area, err := OLCDecode(code)
+	require.NoError(t, err)
+	require.Equal(t, 15, area.CodeLen)
+	require.True(t, area.LatLo <= -33.8567844 && area.LatHi >= -33.8567844)
+
+	require.False(t, OLCIsFull("9G8F+6X"))
+	require.True(t, OLCIsValid("9G8F+6X"))
+	require.False(t, OLCIsValid("8FVC9G8F6X"))
+	_, err = OLCDecode("XXXX0000+")
+	require.Error(t, err)
+}
+
+func TestGeofence(t *testing.T) {
+	// Boston to New York is about 306km
+	require.InDelta(t, 306000, Distance(42.3601, -71.0589, 40.7128, -74.0060), 2000)
+
+	box := BoundingBox{South: -10, West: 170, North: 10, East: -170}
+	require.True(t, box.Contains(0, 179))
+	require.True(t, box.Contains(0, -175))
+	require.False(t, box.Contains(0, 0))
+
+	square := PolygonGeofence{Vertices: [][2]float64{{0, 0}, {0, 1}, {1, 1}, {1, 0}}}
+	require.True(t, square.Contains(0.5, 0.5))
+	require.False(t, square.Contains(1.5, 0.5))
+
+	fence := CircleGeofence{Lat: 42.3601, Lon: -71.0589, RadiusMeters: 1000}
+	here := Location{Lat: 42.3601, Lon: -71.0589}
+	there := Location{Lat: 40.7128, Lon: -74.0060}
+	require.Equal(t, GeofenceEntered, EvaluateGeofence(fence, there, here))
+	require.Equal(t, GeofenceExited, EvaluateGeofence(fence, here, there))
+
+	event := Event{Where: "8FVC9G8F+6X", TowerLat: 1, TowerLon: 1}
+	loc, found := event.PreferredLocation()
+	require.True(t, found)
+	require.Equal(t, LocationTypeGPS, loc.Type)
+	require.InDelta(t, 47.3656, loc.Lat, 0.001)
+}
@@ -0,0 +1,400 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package note wordlist.go generalizes the word phrases of words.go to arbitrary
+// 2048-word dictionaries, and adds an optional checksum word, correction of misheard
+// or misspelled words, and a registry mapping phrases back to the strings they came from
+package note
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DictionarySize is the number of words in a dictionary, each of which encodes 11 bits
+const DictionarySize = 2048
+
+// WordsMaxDistance is the default maximum edit distance at which a misspelled word is
+// corrected to a word in the dictionary
+const WordsMaxDistance = 2
+
+// Dictionary is an ordered list of words used to encode numbers as phrases
+type Dictionary struct {
+	words []string
+	index map[string]uint
+}
+
+// DefaultDictionary holds the same words as WordsFromNumber and WordsToNumber, which
+// index that list directly, and is used by the checksum and correction functions and
+// by registries created without a dictionary
+var DefaultDictionary = mustDictionary(words2048)
+
+func mustDictionary(words []string) *Dictionary {
+	d, err := NewDictionary(words)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewDictionary creates a dictionary from exactly 2048 distinct words.  The order of the
+// words is significant, because a word's position is the number that it encodes.
+func NewDictionary(words []string) (d *Dictionary, err error) {
+	if len(words) != DictionarySize {
+		return nil, fmt.Errorf("dictionary must contain %d words, not %d", DictionarySize, len(words))
+	}
+	d = &Dictionary{words: make([]string, DictionarySize), index: map[string]uint{}}
+	for i, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" || strings.ContainsAny(word, "- ") {
+			return nil, fmt.Errorf("dictionary word %d is not a single word: '%s'", i, words[i])
+		}
+		if _, present := d.index[word]; present {
+			return nil, fmt.Errorf("dictionary contains '%s' more than once", word)
+		}
+		d.words[i] = word
+		d.index[word] = uint(i)
+	}
+	return d, nil
+}
+
+// Word returns the word encoding a number from 0 to 2047
+func (d *Dictionary) Word(num uint) string {
+	return d.words[num%DictionarySize]
+}
+
+// Lookup returns the number encoded by a word
+func (d *Dictionary) Lookup(word string) (num uint, found bool) {
+	num, found = d.index[strings.ToLower(word)]
+	return
+}
+
+// FromNumber converts a number to two or three words, in the same way as WordsFromNumber
+func (d *Dictionary) FromNumber(number uint32) string {
+	left := (number >> 22) & 0x000003ff
+	middle := (number >> 11) & 0x000007ff
+	right := number & 0x000007ff
+	if left == 0 {
+		return d.words[middle] + "-" + d.words[right]
+	}
+	return d.words[left] + "-" + d.words[middle] + "-" + d.words[right]
+}
+
+// FromString hashes a string and converts the hash to words, in the same way as WordsFromString
+func (d *Dictionary) FromString(in string) string {
+	return d.FromNumber(wordsHash(in))
+}
+
+// FromNumberWithChecksum converts a number to words followed by a checksum word
+func (d *Dictionary) FromNumberWithChecksum(number uint32) string {
+	return d.FromNumber(number) + "-" + d.words[wordsChecksum(number)]
+}
+
+// FromStringWithChecksum hashes a string and converts the hash to words followed by a checksum word
+func (d *Dictionary) FromStringWithChecksum(in string) string {
+	return d.FromNumberWithChecksum(wordsHash(in))
+}
+
+// ToNumber converts a phrase of two or three exactly-spelled words back to a number
+func (d *Dictionary) ToNumber(phrase string) (num uint32, found bool) {
+	indexes, found := d.lookupAll(splitPhrase(phrase))
+	if !found || len(indexes) < 2 || len(indexes) > 3 {
+		return 0, false
+	}
+	return wordsNumber(indexes), true
+}
+
+// ToNumberWithChecksum converts a phrase of exactly-spelled words ending in a checksum
+// word back to a number, failing if the checksum doesn't match
+func (d *Dictionary) ToNumberWithChecksum(phrase string) (num uint32, found bool) {
+	indexes, found := d.lookupAll(splitPhrase(phrase))
+	if !found || len(indexes) < 3 || len(indexes) > 4 {
+		return 0, false
+	}
+	num = wordsNumber(indexes[:len(indexes)-1])
+	if wordsChecksum(num) != indexes[len(indexes)-1] {
+		return 0, false
+	}
+	return num, true
+}
+
+// Closest returns the words in the dictionary within the specified edit distance of a
+// possibly-misspelled word, nearest first.  An exactly-spelled word is its own sole match.
+func (d *Dictionary) Closest(word string, maxDistance int) (matches []string) {
+	word = strings.ToLower(word)
+	if _, found := d.index[word]; found {
+		return []string{word}
+	}
+	distances := map[string]int{}
+	for _, candidate := range d.words {
+		// Words differing in length by more than the distance can't be within it
+		if diff := len(candidate) - len(word); diff > maxDistance || -diff > maxDistance {
+			continue
+		}
+		if distance := editDistance(word, candidate); distance <= maxDistance {
+			distances[candidate] = distance
+			matches = append(matches, candidate)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if distances[matches[i]] != distances[matches[j]] {
+			return distances[matches[i]] < distances[matches[j]]
+		}
+		return matches[i] < matches[j]
+	})
+	return
+}
+
+// Correct converts a phrase that may contain misspelled words to a number, returning the
+// corrected phrase.  For each word, only the candidates at the smallest edit distance are
+// considered.  If the phrase has a checksum word it is used to choose among candidates,
+// otherwise the correction must be unambiguous.
+func (d *Dictionary) Correct(phrase string, withChecksum bool) (num uint32, corrected string, err error) {
+	solutions, err := d.corrections(phrase, withChecksum)
+	if err != nil {
+		return
+	}
+	if len(solutions) > 1 {
+		return 0, "", fmt.Errorf("'%s' is ambiguous", phrase)
+	}
+	num = d.solutionNumber(solutions[0], withChecksum)
+	var parts []string
+	for _, index := range solutions[0] {
+		parts = append(parts, d.words[index])
+	}
+	return num, strings.Join(parts, "-"), nil
+}
+
+// corrections returns the word indexes of every correction of a phrase that is
+// consistent with its checksum, if it has one
+func (d *Dictionary) corrections(phrase string, withChecksum bool) (solutions [][]uint, err error) {
+	words := splitPhrase(phrase)
+	minWords, maxWords := 2, 3
+	if withChecksum {
+		minWords, maxWords = 3, 4
+	}
+	if len(words) < minWords || len(words) > maxWords {
+		return nil, fmt.Errorf("phrase must have %d or %d words: %s", minWords, maxWords, phrase)
+	}
+
+	// Gather the nearest candidates for each word
+	candidates := make([][]uint, len(words))
+	for i, word := range words {
+		for _, match := range nearest(d.Closest(word, WordsMaxDistance), word) {
+			candidates[i] = append(candidates[i], d.index[match])
+		}
+		if len(candidates[i]) == 0 {
+			return nil, fmt.Errorf("'%s' is not close to any known word", word)
+		}
+	}
+
+	// Try every combination of candidates, keeping those that are self-consistent
+	combination := make([]uint, len(words))
+	var try func(i int)
+	try = func(i int) {
+		if i == len(words) {
+			if withChecksum && wordsChecksum(wordsNumber(combination[:len(words)-1])) != combination[len(words)-1] {
+				return
+			}
+			solutions = append(solutions, append([]uint{}, combination...))
+			return
+		}
+		for _, candidate := range candidates[i] {
+			combination[i] = candidate
+			try(i + 1)
+		}
+	}
+	try(0)
+	if len(solutions) == 0 {
+		return nil, fmt.Errorf("no correction of '%s' has a valid checksum", phrase)
+	}
+	return
+}
+
+// solutionNumber converts the word indexes of a correction to the number that they encode
+func (d *Dictionary) solutionNumber(solution []uint, withChecksum bool) uint32 {
+	if withChecksum {
+		return wordsNumber(solution[:len(solution)-1])
+	}
+	return wordsNumber(solution)
+}
+
+// lookupAll converts each word to its number
+func (d *Dictionary) lookupAll(words []string) (indexes []uint, found bool) {
+	for _, word := range words {
+		index, present := d.index[strings.ToLower(word)]
+		if !present {
+			return nil, false
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, true
+}
+
+// nearest filters a list of matches, sorted nearest first, to those at the smallest distance
+func nearest(matches []string, word string) []string {
+	if len(matches) == 0 {
+		return nil
+	}
+	best := editDistance(strings.ToLower(word), matches[0])
+	for i := 1; i < len(matches); i++ {
+		if editDistance(strings.ToLower(word), matches[i]) > best {
+			return matches[:i]
+		}
+	}
+	return matches
+}
+
+// splitPhrase splits a phrase into words, accepting the spaces that people tend to type
+// when transcribing a phrase that was read aloud as well as the hyphens that separate them
+func splitPhrase(phrase string) []string {
+	return strings.FieldsFunc(phrase, func(r rune) bool {
+		return r == '-' || r == ' ' || r == '\t' || r == ','
+	})
+}
+
+// wordsNumber maps two or three word indexes, msb to lsb, back to bit fields
+func wordsNumber(indexes []uint) (result uint32) {
+	for _, index := range indexes {
+		result = result<<11 | uint32(index)
+	}
+	return
+}
+
+// wordsHash is the 32-bit hash used to convert strings to words
+func wordsHash(in string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(in))
+	return hash.Sum32()
+}
+
+// wordsChecksum computes the index of the checksum word for a number
+func wordsChecksum(number uint32) uint {
+	hash := fnv.New32a()
+	hash.Write([]byte{byte(number >> 24), byte(number >> 16), byte(number >> 8), byte(number)})
+	return uint(hash.Sum32() % DictionarySize)
+}
+
+// editDistance computes the optimal string alignment distance between two words, which
+// counts insertions, deletions, substitutions and transpositions of adjacent letters
+func editDistance(a string, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = cur[j-1] + 1
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && prev2[j-2]+1 < cur[j] {
+				cur[j] = prev2[j-2] + 1
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// WordsFromNumberWithChecksum converts a number to simple words followed by a checksum word
+func WordsFromNumberWithChecksum(number uint32) string {
+	return DefaultDictionary.FromNumberWithChecksum(number)
+}
+
+// WordsFromStringWithChecksum hashes a string and converts it to simple words followed by a checksum word
+func WordsFromStringWithChecksum(in string) string {
+	return DefaultDictionary.FromStringWithChecksum(in)
+}
+
+// WordsToNumberWithChecksum looks up a number from simple words followed by a checksum word
+func WordsToNumberWithChecksum(words string) (num uint32, found bool) {
+	return DefaultDictionary.ToNumberWithChecksum(words)
+}
+
+// WordsCorrect converts a possibly-misspelled phrase to a number using the default dictionary
+func WordsCorrect(phrase string, withChecksum bool) (num uint32, corrected string, err error) {
+	return DefaultDictionary.Correct(phrase, withChecksum)
+}
+
+// WordsRegistry remembers the strings, such as DeviceUIDs, from which phrases were
+// generated so that a phrase read aloud can be mapped back to them.  It is safe for
+// concurrent use.
+type WordsRegistry struct {
+	dictionary *Dictionary
+	lock       sync.RWMutex
+	byHash     map[uint32][]string
+}
+
+// NewWordsRegistry creates a registry using the specified dictionary, or the default if nil
+func NewWordsRegistry(d *Dictionary) *WordsRegistry {
+	if d == nil {
+		d = DefaultDictionary
+	}
+	return &WordsRegistry{dictionary: d, byHash: map[uint32][]string{}}
+}
+
+// Register records a string, returning its phrase
+func (r *WordsRegistry) Register(in string) (phrase string) {
+	hash := wordsHash(in)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, existing := range r.byHash[hash] {
+		if existing == in {
+			return r.dictionary.FromNumber(hash)
+		}
+	}
+	r.byHash[hash] = append(r.byHash[hash], in)
+	return r.dictionary.FromNumber(hash)
+}
+
+// Lookup returns the registered strings whose phrase matches, correcting misspelled
+// words.  Where a word could be corrected in more than one way, only the corrections
+// matching registered strings are considered.  A phrase may end in a checksum word,
+// which is recognized by trying the phrase with and without one.  More than one string
+// is returned if the phrase remains ambiguous or if the hashes of strings collide.
+func (r *WordsRegistry) Lookup(phrase string) (matches []string, err error) {
+	var hashes []uint32
+	for _, withChecksum := range []bool{false, true} {
+		solutions, solveErr := r.dictionary.corrections(phrase, withChecksum)
+		if solveErr != nil {
+			if err == nil {
+				err = solveErr
+			}
+			continue
+		}
+		for _, solution := range solutions {
+			hashes = append(hashes, r.dictionary.solutionNumber(solution, withChecksum))
+		}
+	}
+	if len(hashes) == 0 {
+		return nil, err
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	seen := map[uint32]bool{}
+	for _, hash := range hashes {
+		if !seen[hash] {
+			seen[hash] = true
+			matches = append(matches, r.byHash[hash]...)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no registered phrase matches '%s'", phrase)
+	}
+	return matches, nil
+}
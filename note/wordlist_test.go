@@ -0,0 +1,63 @@
+package note
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWordsChecksum(t *testing.T) {
+	for _, number := range []uint32{0x12345, 0xfedcba98, 7} {
+		phrase := WordsFromNumberWithChecksum(number)
+		num, found := WordsToNumberWithChecksum(phrase)
+		require.True(t, found, phrase)
+		require.Equal(t, number, num)
+		plain, _ := WordsToNumber(WordsFromNumber(number))
+		require.Equal(t, number, plain)
+	}
+
+	// A wrong checksum word must be detected
+	_, found := WordsToNumberWithChecksum(WordsFromNumber(0x12345) + "-" + DefaultDictionary.Word(wordsChecksum(0x12345)+1))
+	require.False(t, found)
+}
+
+func TestWordsCorrect(t *testing.T) {
+	require.Equal(t, 1, editDistance("stcok", "stock"))
+	require.Equal(t, 2, editDistance("flower", "flour"))
+
+	num, corrected, err := WordsCorrect("flour wator stock", false)
+	require.NoError(t, err)
+	require.Equal(t, "flour-water-stock", corrected)
+	require.Equal(t, WordsFromString("dev:qwerty"), WordsFromNumber(num))
+
+	phrase := WordsFromStringWithChecksum("dev:qwerty")
+	words := splitPhrase(phrase)
+	words[1] = "watr"
+	_, corrected, err = WordsCorrect(words[0]+" "+words[1]+" "+words[2]+" "+words[3], true)
+	require.NoError(t, err)
+	require.Equal(t, phrase, corrected)
+
+	_, _, err = WordsCorrect("flour-xyzzyq-stock", false)
+	require.Error(t, err)
+}
+
+func TestWordsRegistry(t *testing.T) {
+	_, err := NewDictionary([]string{"one", "two"})
+	require.Error(t, err)
+
+	r := NewWordsRegistry(nil)
+	phrase := r.Register("dev:qwerty")
+	require.Equal(t, "flour-water-stock", phrase)
+	r.Register("dev:foobar")
+
+	matches, err := r.Lookup("flour-watter-stock")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev:qwerty"}, matches)
+
+	matches, err = r.Lookup(WordsFromStringWithChecksum("dev:foobar"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev:foobar"}, matches)
+
+	_, err = r.Lookup("near-eat-stock")
+	require.Error(t, err)
+}
@@ -668,7 +668,7 @@ func (context *Context) transactionRequest(req Request, multiport bool, portConf
 		// we want to make sure that we indicate to the caller that there was an I/O error (corruption)
 		err2 := note.JSONUnmarshal(rspJSON, &rsp)
 		if err2 != nil {
-			err = fmt.Errorf("%s %s", err, note.ErrCardIo)
+			err = note.ErrorFrom(fmt.Errorf("%s %s", err, note.ErrCardIo))
 		}
 		return
 	}
@@ -740,7 +740,7 @@ func (context *Context) Transaction(req map[string]interface{}) (rsp map[string]
 	// Perform the transaction
 	rspJSON, err2 := context.TransactionJSON(reqJSON)
 	if err2 != nil {
-		err = fmt.Errorf("error from TransactionJSON: %s", err2)
+		err = fmt.Errorf("error from TransactionJSON: %w", err2)
 		return
 	}
 
@@ -960,11 +960,7 @@ func (context *Context) transactionJSON(reqJSON []byte, multiport bool, portConf
 				err = fmt.Errorf("error unmarshaling reply from module: %s %s: %s", err, note.ErrCardIo, rspJSON)
 			} else {
 				if rsp.Err != "" {
-					if req.Req == "" {
-						err = fmt.Errorf("%s", rsp.Err)
-					} else {
-						err = fmt.Errorf("%s: %s", req.Req, rsp.Err)
-					}
+					err = note.NewError(req.Req, rsp.Err)
 				}
 			}
 		}
@@ -1018,6 +1014,11 @@ func (context *Context) transactionJSON(reqJSON []byte, multiport bool, portConf
 
 	}
 
+	// Return a structured error so that callers may test for keywords with errors.Is
+	if err != nil {
+		err = note.ErrorFrom(err)
+	}
+
 	// Bump the request sequence number now that we've processed this request, success or error
 	context.lastRequestSeqno++
 
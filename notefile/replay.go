@@ -0,0 +1,109 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package notefile
+
+import (
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notecard"
+)
+
+// Transactor is the subset of notecard.Context needed to replay notes to a Notecard
+type Transactor interface {
+	TransactionRequest(req notecard.Request) (rsp notecard.Request, err error)
+}
+
+// Replay sends the notes of a notefile that haven't yet been sent to the Notecard,
+// in the order in which they were modified.  Queued notes are removed from the store
+// once the Notecard has accepted them, and database notes are marked as sent.
+// Replay stops at the first error, so that it may be resumed later without loss.
+func (store *Store) Replay(card Transactor, notefileID string) (sent int, err error) {
+	store.lock.Lock()
+	file, err := store.lookup(notefileID)
+	var noteIDs []string
+	if err == nil {
+		for _, noteID := range changedSince(file, 0) {
+			if !file.Notes[noteID].Sent {
+				noteIDs = append(noteIDs, noteID)
+			}
+		}
+	}
+	store.lock.Unlock()
+	if err != nil {
+		return
+	}
+
+	queue := isQueue(notefileID)
+	for _, noteID := range noteIDs {
+
+		// Take a snapshot of the note, which may have been changed or removed since we looked
+		store.lock.Lock()
+		n, present := file.Notes[noteID]
+		store.lock.Unlock()
+		if !present || n.Sent {
+			continue
+		}
+
+		// Build the request
+		req := notecard.Request{NotefileID: notefileID}
+		switch {
+		case n.Deleted:
+			req.Req = notecard.ReqNoteDelete
+			req.NoteID = noteID
+		case queue:
+			req.Req = notecard.ReqNoteAdd
+		default:
+			req.Req = notecard.ReqNoteUpdate
+			req.NoteID = noteID
+		}
+		if !n.Deleted {
+			if n.Body != nil {
+				body := n.Body
+				req.Body = &body
+			}
+			if len(n.Payload) > 0 {
+				payload := n.Payload
+				req.Payload = &payload
+			}
+		}
+
+		_, err = card.TransactionRequest(req)
+		if err != nil && !(n.Deleted && note.ErrorContains(err, note.ErrNoteNoExist)) {
+			return
+		}
+		err = nil
+
+		// Retire the note, unless it was modified while we were sending it
+		store.lock.Lock()
+		current, present := file.Notes[noteID]
+		if present && current.Change == n.Change {
+			if queue || current.Deleted {
+				delete(file.Notes, noteID)
+			} else {
+				current.Sent = true
+				file.Notes[noteID] = current
+			}
+			err = store.save(notefileID)
+		}
+		store.lock.Unlock()
+		if err != nil {
+			return
+		}
+		sent++
+	}
+	return
+}
+
+// ReplayAll replays every notefile in the store, returning the total number of notes sent
+func (store *Store) ReplayAll(card Transactor) (sent int, err error) {
+	for _, notefileID := range store.Files() {
+		var n int
+		n, err = store.Replay(card, notefileID)
+		sent += n
+		if err != nil {
+			return
+		}
+	}
+	return
+}
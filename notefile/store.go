@@ -0,0 +1,445 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package notefile implements Notecard queue and database notefile semantics on the
+// host, so that notes may be buffered locally while a Notecard is absent and then
+// replayed to it when it returns.
+package notefile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/note/merge"
+)
+
+// fileSuffix is appended to the notefileID to form the name of the file on disk
+const fileSuffix = ".json"
+
+// Notefile is the persistent state of a single notefile
+type Notefile struct {
+	Info     note.NotefileInfo    `json:"info,omitempty"`
+	Notes    map[string]note.Note `json:"notes,omitempty"`
+	Change   int64                `json:"change,omitempty"`
+	Trackers map[string]int64     `json:"trackers,omitempty"`
+}
+
+// Store is a directory of notefiles
+type Store struct {
+	// EndpointID is recorded in the history of notes modified through this store
+	EndpointID string
+
+	dir   string
+	lock  sync.Mutex
+	files map[string]*Notefile
+}
+
+// Open opens (creating if necessary) a notefile store rooted at the specified directory
+func Open(dir string) (store *Store, err error) {
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	store = &Store{
+		EndpointID: note.DefaultDeviceEndpointID,
+		dir:        dir,
+		files:      map[string]*Notefile{},
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileSuffix) {
+			continue
+		}
+		var contents []byte
+		contents, err = ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		file := &Notefile{}
+		err = note.JSONUnmarshal(contents, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", entry.Name(), err)
+		}
+		file.init()
+		store.files[strings.TrimSuffix(entry.Name(), fileSuffix)] = file
+	}
+	return
+}
+
+// init makes sure that a notefile's maps are allocated
+func (file *Notefile) init() {
+	if file.Notes == nil {
+		file.Notes = map[string]note.Note{}
+	}
+	if file.Trackers == nil {
+		file.Trackers = map[string]int64{}
+	}
+}
+
+// save persists a notefile atomically.  The caller must hold the store lock.
+func (store *Store) save(notefileID string) (err error) {
+	file, present := store.files[notefileID]
+	path := filepath.Join(store.dir, notefileID+fileSuffix)
+	if !present {
+		err = os.Remove(path)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	contents, err := note.JSONMarshal(file)
+	if err != nil {
+		return
+	}
+	temp := path + ".tmp"
+	err = ioutil.WriteFile(temp, contents, 0600)
+	if err != nil {
+		return
+	}
+	return os.Rename(temp, path)
+}
+
+// lookup finds an existing notefile.  The caller must hold the store lock.
+func (store *Store) lookup(notefileID string) (file *Notefile, err error) {
+	file, present := store.files[notefileID]
+	if !present {
+		err = fmt.Errorf("notefile does not exist: %s %s", notefileID, note.ErrNotefileNoExist)
+	}
+	return
+}
+
+// create makes a new notefile.  The caller must hold the store lock.
+func (store *Store) create(notefileID string, info note.NotefileInfo) (file *Notefile, err error) {
+	err = validateNotefileID(notefileID)
+	if err != nil {
+		return
+	}
+	file = &Notefile{Info: info}
+	file.init()
+	store.files[notefileID] = file
+	return
+}
+
+// Files returns the sorted list of notefiles in the store
+func (store *Store) Files() (notefileIDs []string) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for notefileID := range store.files {
+		notefileIDs = append(notefileIDs, notefileID)
+	}
+	sort.Strings(notefileIDs)
+	return
+}
+
+// CreateNotefile creates a new, empty notefile with the specified sync parameters
+func (store *Store) CreateNotefile(notefileID string, info note.NotefileInfo) (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if _, present := store.files[notefileID]; present {
+		return fmt.Errorf("notefile already exists: %s %s", notefileID, note.ErrNotefileExists)
+	}
+	_, err = store.create(notefileID, info)
+	if err != nil {
+		return
+	}
+	return store.save(notefileID)
+}
+
+// DeleteNotefile removes a notefile and all of its notes
+func (store *Store) DeleteNotefile(notefileID string) (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	_, err = store.lookup(notefileID)
+	if err != nil {
+		return
+	}
+	delete(store.files, notefileID)
+	return store.save(notefileID)
+}
+
+// Info returns the sync parameters of a notefile, along with the number of notes
+// it contains and the number of those that have been modified
+func (store *Store) Info(notefileID string) (info note.NotefileInfo, err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, err := store.lookup(notefileID)
+	if err != nil {
+		return
+	}
+	info = file.Info
+	info.Total = 0
+	info.Changes = 0
+	for _, n := range file.Notes {
+		if !n.Deleted {
+			info.Total++
+		}
+		if !n.Sent {
+			info.Changes++
+		}
+	}
+	return
+}
+
+// SetInfo replaces the sync parameters of a notefile
+func (store *Store) SetInfo(notefileID string, info note.NotefileInfo) (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, err := store.lookup(notefileID)
+	if err != nil {
+		return
+	}
+	info.Total = 0
+	info.Changes = 0
+	file.Info = info
+	return store.save(notefileID)
+}
+
+// touch assigns a new change number to a note and records the modification in its history
+func (store *Store) touch(file *Notefile, n *note.Note) {
+	file.Change++
+	n.Change = file.Change
+	n.Updates++
+	n.Sent = false
+
+	entry := note.History{
+		When:       time.Now().Unix(),
+		EndpointID: store.EndpointID,
+		Sequence:   n.Updates,
+	}
+	// The histories are copied because notes returned to callers share them
+	var histories []note.History
+	if n.Histories != nil {
+		histories = append([]note.History{}, *n.Histories...)
+	}
+	if len(histories) > 0 && histories[0].EndpointID == store.EndpointID {
+		histories[0] = entry
+	} else {
+		histories = append([]note.History{entry}, histories...)
+	}
+	n.Histories = &histories
+}
+
+// AddNote adds a note to a notefile, creating the notefile if necessary.  For queues
+// the noteID is assigned by the store; for databases it may be supplied, and it is an
+// error if a note with that ID already exists.
+func (store *Store) AddNote(notefileID string, noteID string, body map[string]interface{}, payload []byte) (newNoteID string, err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, present := store.files[notefileID]
+	if !present {
+		file, err = store.create(notefileID, note.NotefileInfo{})
+		if err != nil {
+			return
+		}
+	}
+	if isQueue(notefileID) {
+		if noteID != "" {
+			return "", fmt.Errorf("note ID may not be specified for a queue %s", note.ErrNotefileQueueDisallowed)
+		}
+		noteID = strconv.FormatInt(file.Change+1, 10)
+	} else if noteID == "" {
+		noteID = strconv.FormatInt(file.Change+1, 10)
+	}
+	existing, exists := file.Notes[noteID]
+	if exists && !existing.Deleted {
+		return "", fmt.Errorf("note already exists: %s %s", noteID, note.ErrNoteExists)
+	}
+	n := note.Note{Body: body, Payload: payload}
+	if exists {
+		n.Updates = existing.Updates
+		n.Histories = existing.Histories
+	}
+	store.touch(file, &n)
+	file.Notes[noteID] = n
+	err = store.save(notefileID)
+	if err != nil {
+		return
+	}
+	return noteID, nil
+}
+
+// UpdateNote replaces the body and payload of an existing database note, creating
+// it if it doesn't yet exist
+func (store *Store) UpdateNote(notefileID string, noteID string, body map[string]interface{}, payload []byte) (err error) {
+	if isQueue(notefileID) {
+		return fmt.Errorf("notes in a queue may not be updated %s", note.ErrNotefileQueueDisallowed)
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, present := store.files[notefileID]
+	if !present {
+		file, err = store.create(notefileID, note.NotefileInfo{})
+		if err != nil {
+			return
+		}
+	}
+	n := file.Notes[noteID]
+	n.Body = body
+	n.Payload = payload
+	n.Deleted = false
+	store.touch(file, &n)
+	file.Notes[noteID] = n
+	return store.save(notefileID)
+}
+
+// DeleteNote deletes a note.  Database notes are retained as tombstones so that the
+// deletion may be propagated, while queued notes are simply removed.
+func (store *Store) DeleteNote(notefileID string, noteID string) (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, err := store.lookup(notefileID)
+	if err != nil {
+		return
+	}
+	n, present := file.Notes[noteID]
+	if !present || n.Deleted {
+		return fmt.Errorf("note does not exist: %s %s", noteID, note.ErrNoteNoExist)
+	}
+	if isQueue(notefileID) {
+		delete(file.Notes, noteID)
+	} else {
+		n.Deleted = true
+		n.Body = nil
+		n.Payload = nil
+		store.touch(file, &n)
+		file.Notes[noteID] = n
+	}
+	return store.save(notefileID)
+}
+
+// GetNote retrieves a note that hasn't been deleted
+func (store *Store) GetNote(notefileID string, noteID string) (n note.Note, err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, err := store.lookup(notefileID)
+	if err != nil {
+		return
+	}
+	n, present := file.Notes[noteID]
+	if !present || n.Deleted {
+		return note.Note{}, fmt.Errorf("note does not exist: %s %s", noteID, note.ErrNoteNoExist)
+	}
+	return n.Dup(), nil
+}
+
+// MergeNote merges a version of a database note received from another endpoint with
+// the local version, using the supplied conflict resolution strategy
+func (store *Store) MergeNote(notefileID string, noteID string, remote note.Note, strategy merge.Strategy) (merged note.Note, err error) {
+	if isQueue(notefileID) {
+		return merged, fmt.Errorf("notes in a queue may not be merged %s", note.ErrNotefileQueueDisallowed)
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, present := store.files[notefileID]
+	if !present {
+		file, err = store.create(notefileID, note.NotefileInfo{})
+		if err != nil {
+			return
+		}
+	}
+	local, exists := file.Notes[noteID]
+	if exists {
+		merged = merge.Merge(local, remote, strategy)
+	} else {
+		merged = remote.Dup()
+	}
+	file.Change++
+	merged.Change = file.Change
+	merged.Sent = false
+	file.Notes[noteID] = merged
+	err = store.save(notefileID)
+	return
+}
+
+// Changes returns the notes that have been modified since the tracker was last
+// advanced, keyed by noteID, along with the number of changes that remain
+// afterward.  Trackers are created implicitly; an empty trackerID returns
+// changes without tracking.  If purge is specified, returned queue notes are
+// removed and returned tombstones are discarded.
+func (store *Store) Changes(notefileID string, trackerID string, max int, purge bool) (notes map[string]note.Note, pending int, err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, err := store.lookup(notefileID)
+	if err != nil {
+		return
+	}
+
+	cursor := file.Trackers[trackerID]
+	noteIDs := changedSince(file, cursor)
+	if max > 0 && len(noteIDs) > max {
+		pending = len(noteIDs) - max
+		noteIDs = noteIDs[:max]
+	}
+
+	notes = map[string]note.Note{}
+	for _, noteID := range noteIDs {
+		n := file.Notes[noteID]
+		notes[noteID] = n.Dup()
+		if n.Change > cursor {
+			cursor = n.Change
+		}
+		if purge && (isQueue(notefileID) || n.Deleted) {
+			delete(file.Notes, noteID)
+		}
+	}
+	if trackerID != "" {
+		file.Trackers[trackerID] = cursor
+	}
+
+	if trackerID != "" || purge {
+		err = store.save(notefileID)
+	}
+	return
+}
+
+// DeleteTracker removes a change tracker from a notefile
+func (store *Store) DeleteTracker(notefileID string, trackerID string) (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	file, err := store.lookup(notefileID)
+	if err != nil {
+		return
+	}
+	if _, present := file.Trackers[trackerID]; !present {
+		return fmt.Errorf("tracker does not exist: %s %s", trackerID, note.ErrTrackerNoExist)
+	}
+	delete(file.Trackers, trackerID)
+	return store.save(notefileID)
+}
+
+// changedSince returns the IDs of notes modified after the cursor, in change order
+func changedSince(file *Notefile, cursor int64) (noteIDs []string) {
+	for noteID, n := range file.Notes {
+		if n.Change > cursor {
+			noteIDs = append(noteIDs, noteID)
+		}
+	}
+	sort.Slice(noteIDs, func(i, j int) bool {
+		return file.Notes[noteIDs[i]].Change < file.Notes[noteIDs[j]].Change
+	})
+	return
+}
+
+// isQueue determines whether a notefile has queue (as opposed to database) semantics
+func isQueue(notefileID string) bool {
+	t, err := note.ParseNotefileID(notefileID)
+	return err == nil && t.IsQueue()
+}
+
+// validateNotefileID rejects notefileIDs that the Notecard wouldn't accept
+func validateNotefileID(notefileID string) (err error) {
+	_, err = note.ParseNotefileID(notefileID)
+	return
+}
@@ -0,0 +1,89 @@
+package notefile
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notecard"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	requests []notecard.Request
+}
+
+func (r *recorder) TransactionRequest(req notecard.Request) (rsp notecard.Request, err error) {
+	r.requests = append(r.requests, req)
+	return
+}
+
+func TestQueueReplay(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+
+	_, err = store.AddNote("data.qo", "", map[string]interface{}{"temp": 1}, nil)
+	require.NoError(t, err)
+	_, err = store.AddNote("data.qo", "", map[string]interface{}{"temp": 2}, nil)
+	require.NoError(t, err)
+	require.Error(t, store.UpdateNote("data.qo", "1", nil, nil))
+
+	// Reopen to make sure that notes were persisted
+	store, err = Open(dir)
+	require.NoError(t, err)
+	info, err := store.Info("data.qo")
+	require.NoError(t, err)
+	require.Equal(t, 2, info.Total)
+
+	card := &recorder{}
+	sent, err := store.Replay(card, "data.qo")
+	require.NoError(t, err)
+	require.Equal(t, 2, sent)
+	require.Equal(t, notecard.ReqNoteAdd, card.requests[0].Req)
+	require.Equal(t, json.Number("1"), (*card.requests[0].Body)["temp"])
+
+	info, err = store.Info("data.qo")
+	require.NoError(t, err)
+	require.Equal(t, 0, info.Total)
+}
+
+func TestDatabaseChanges(t *testing.T) {
+	store, err := Open(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.UpdateNote("config.db", "a", map[string]interface{}{"v": 1}, nil))
+	require.NoError(t, store.UpdateNote("config.db", "b", map[string]interface{}{"v": 2}, nil))
+
+	// Updating a note doesn't change the history of a copy retrieved before
+	before, err := store.GetNote("config.db", "b")
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateNote("config.db", "b", map[string]interface{}{"v": 3}, nil))
+	require.Equal(t, int32(1), (*before.Histories)[0].Sequence)
+	after, err := store.GetNote("config.db", "b")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), (*after.Histories)[0].Sequence)
+
+	_, err = store.AddNote("config.db", "a", nil, nil)
+	require.True(t, note.ErrorContains(err, note.ErrNoteExists))
+
+	notes, pending, err := store.Changes("config.db", "t", 1, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, pending)
+	require.Contains(t, notes, "a")
+
+	require.NoError(t, store.DeleteNote("config.db", "a"))
+	notes, _, err = store.Changes("config.db", "t", 0, false)
+	require.NoError(t, err)
+	require.Len(t, notes, 2)
+	require.True(t, notes["a"].Deleted)
+
+	_, err = store.GetNote("config.db", "a")
+	require.True(t, note.ErrorContains(err, note.ErrNoteNoExist))
+
+	card := &recorder{}
+	_, err = store.Replay(card, "config.db")
+	require.NoError(t, err)
+	require.Equal(t, notecard.ReqNoteUpdate, card.requests[0].Req)
+	require.Equal(t, notecard.ReqNoteDelete, card.requests[1].Req)
+}
@@ -0,0 +1,248 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package analytics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/blues/note-go/note"
+)
+
+// RouteStats summarizes the attempts to route events by one route from one source
+type RouteStats struct {
+	RouteSerial int64              `json:"route"`
+	Source      note.RoutingSource `json:"source,omitempty"`
+	Attempts    int                `json:"attempts"`
+	Succeeded   int                `json:"succeeded"`
+	// Attention is the number of attempts flagged as needing attention, which are failures
+	Attention   int     `json:"attention,omitempty"`
+	SuccessRate float64 `json:"success_rate"`
+	// Percentiles of the duration in milliseconds of the attempts whose duration was recorded
+	P50Ms int64 `json:"p50_ms,omitempty"`
+	P95Ms int64 `json:"p95_ms,omitempty"`
+}
+
+// FailedEvent is an event whose latest attempt to be routed by a route failed
+type FailedEvent struct {
+	EventSerial int64  `json:"event"`
+	RouteSerial int64  `json:"route"`
+	Attempts    int    `json:"attempts"`
+	Status      string `json:"status,omitempty"`
+	Text        string `json:"text,omitempty"`
+	// Epoch seconds of the latest attempt
+	Latest int64 `json:"latest,omitempty"`
+}
+
+// RouteRetry lists the events to be rerouted by a route
+type RouteRetry struct {
+	RouteSerial  int64    `json:"route"`
+	EventSerials []string `json:"events"`
+}
+
+// RetryPlan lists the events to be manually rerouted, both by route and in total, with
+// event serials in the form expected by the notehub
+type RetryPlan struct {
+	Routes       []RouteRetry `json:"routes,omitempty"`
+	EventSerials []string     `json:"events,omitempty"`
+}
+
+// RouteReport is the result of analyzing route logs
+type RouteReport struct {
+	Routes []RouteStats  `json:"routes"`
+	Failed []FailedEvent `json:"failed,omitempty"`
+	Retry  RetryPlan     `json:"retry"`
+}
+
+// routeKey groups the attempts of a route from a source
+type routeKey struct {
+	route  int64
+	source note.RoutingSource
+}
+
+// attemptKey identifies the attempts to route an event by a route
+type attemptKey struct {
+	event int64
+	route int64
+}
+
+// attempts tracks the attempts to route an event by a route
+type attempts struct {
+	count  int
+	latest note.RouteLogEntry
+}
+
+// RouteAnalyzer accumulates route log entries
+type RouteAnalyzer struct {
+	durations map[routeKey][]int64
+	stats     map[routeKey]*RouteStats
+	attempts  map[attemptKey]*attempts
+}
+
+// NewRouteAnalyzer creates an empty analyzer
+func NewRouteAnalyzer() *RouteAnalyzer {
+	return &RouteAnalyzer{
+		durations: map[routeKey][]int64{},
+		stats:     map[routeKey]*RouteStats{},
+		attempts:  map[attemptKey]*attempts{},
+	}
+}
+
+// AddEntries incorporates route log entries into the analysis, in any order
+func (a *RouteAnalyzer) AddEntries(entries ...note.RouteLogEntry) {
+	for _, e := range entries {
+		key := routeKey{route: e.RouteSerial, source: e.Source}
+		s, present := a.stats[key]
+		if !present {
+			s = &RouteStats{RouteSerial: e.RouteSerial, Source: e.Source}
+			a.stats[key] = s
+		}
+		s.Attempts++
+		if e.Attn {
+			s.Attention++
+		} else {
+			s.Succeeded++
+		}
+		// A duration of 0 means that it wasn't recorded
+		if e.Duration > 0 {
+			a.durations[key] = append(a.durations[key], e.Duration)
+		}
+
+		ak := attemptKey{event: e.EventSerial, route: e.RouteSerial}
+		at, present := a.attempts[ak]
+		if !present {
+			at = &attempts{latest: e}
+			a.attempts[ak] = at
+		} else if e.Date.After(at.latest.Date) {
+			at.latest = e
+		}
+		at.count++
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Routes returns the statistics of each route and source, ordered by route and then source
+func (a *RouteAnalyzer) Routes() (routes []RouteStats) {
+	for key, s := range a.stats {
+		stats := *s
+		stats.SuccessRate = float64(s.Succeeded) / float64(s.Attempts)
+		durations := append([]int64{}, a.durations[key]...)
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		stats.P50Ms = percentile(durations, 50)
+		stats.P95Ms = percentile(durations, 95)
+		routes = append(routes, stats)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].RouteSerial != routes[j].RouteSerial {
+			return routes[i].RouteSerial < routes[j].RouteSerial
+		}
+		return routes[i].Source < routes[j].Source
+	})
+	return
+}
+
+// Failed returns the events whose latest attempt by a route failed, ordered by route
+// and then event, consistent with note.GetAggregateEventStatus
+func (a *RouteAnalyzer) Failed() (failed []FailedEvent) {
+	for key, at := range a.attempts {
+		if !at.latest.Attn {
+			continue
+		}
+		f := FailedEvent{
+			EventSerial: key.event,
+			RouteSerial: key.route,
+			Attempts:    at.count,
+			Status:      at.latest.Status,
+			Text:        at.latest.Text,
+		}
+		if !at.latest.Date.IsZero() {
+			f.Latest = at.latest.Date.Unix()
+		}
+		failed = append(failed, f)
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		if failed[i].RouteSerial != failed[j].RouteSerial {
+			return failed[i].RouteSerial < failed[j].RouteSerial
+		}
+		return failed[i].EventSerial < failed[j].EventSerial
+	})
+	return
+}
+
+// RetryPlan returns the events to be manually rerouted because their latest attempt
+// failed.  An event that failed on several routes appears once in the total.
+func (a *RouteAnalyzer) RetryPlan() (plan RetryPlan) {
+	var events []int64
+	seen := map[int64]bool{}
+	for _, f := range a.Failed() {
+		n := len(plan.Routes)
+		if n == 0 || plan.Routes[n-1].RouteSerial != f.RouteSerial {
+			plan.Routes = append(plan.Routes, RouteRetry{RouteSerial: f.RouteSerial})
+			n++
+		}
+		plan.Routes[n-1].EventSerials = append(plan.Routes[n-1].EventSerials, strconv.FormatInt(f.EventSerial, 10))
+		if !seen[f.EventSerial] {
+			seen[f.EventSerial] = true
+			events = append(events, f.EventSerial)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+	for _, event := range events {
+		plan.EventSerials = append(plan.EventSerials, strconv.FormatInt(event, 10))
+	}
+	return
+}
+
+// Report returns the complete analysis
+func (a *RouteAnalyzer) Report() RouteReport {
+	return RouteReport{Routes: a.Routes(), Failed: a.Failed(), Retry: a.RetryPlan()}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r RouteReport) WriteJSON(w io.Writer) error {
+	contents, err := note.JSONMarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(contents, '\n'))
+	return err
+}
+
+// WriteText writes the report as tables for display
+func (r RouteReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ROUTE\tSOURCE\tATTEMPTS\tSUCCESS\tATTN\tP50\tP95\n")
+	for _, s := range r.Routes {
+		source := s.Source.String()
+		if source == "" {
+			source = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%.1f%%\t%d\t%dms\t%dms\n",
+			s.RouteSerial, source, s.Attempts, s.SuccessRate*100, s.Attention, s.P50Ms, s.P95Ms)
+	}
+	if len(r.Failed) > 0 {
+		fmt.Fprintf(tw, "\nROUTE\tEVENT\tATTEMPTS\tSTATUS\tTEXT\n")
+		for _, f := range r.Failed {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%s\n", f.RouteSerial, f.EventSerial, f.Attempts, f.Status, f.Text)
+		}
+	}
+	fmt.Fprintf(tw, "\n%d events to reroute\n", len(r.Retry.EventSerials))
+	return tw.Flush()
+}
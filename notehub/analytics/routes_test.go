@@ -0,0 +1,57 @@
+package analytics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteAnalyzer(t *testing.T) {
+	at := func(secs int64) time.Time { return time.Unix(secs, 0) }
+	a := NewRouteAnalyzer()
+	a.AddEntries(
+		// Event 1 failed on route 10 and then succeeded when retried
+		note.RouteLogEntry{EventSerial: 1, RouteSerial: 10, Date: at(100), Attn: true, Status: "500", Source: note.RoutingSourceNormal, Duration: 40},
+		note.RouteLogEntry{EventSerial: 1, RouteSerial: 10, Date: at(200), Status: "200", Source: note.RoutingSourceRetry, Duration: 20},
+		// Event 2 succeeded on route 10 but its latest attempt on route 11 failed
+		note.RouteLogEntry{EventSerial: 2, RouteSerial: 10, Date: at(110), Status: "200", Source: note.RoutingSourceNormal, Duration: 10},
+		note.RouteLogEntry{EventSerial: 2, RouteSerial: 11, Date: at(310), Attn: true, Status: "503", Text: "unavailable", Source: note.RoutingSourceRetry},
+		note.RouteLogEntry{EventSerial: 2, RouteSerial: 11, Date: at(110), Attn: true, Status: "500", Source: note.RoutingSourceNormal, Duration: 30},
+		// Event 3 failed on both routes
+		note.RouteLogEntry{EventSerial: 3, RouteSerial: 10, Date: at(120), Attn: true, Status: "500", Source: note.RoutingSourceNormal, Duration: 1000},
+		note.RouteLogEntry{EventSerial: 3, RouteSerial: 11, Date: at(120), Attn: true, Status: "500", Source: note.RoutingSourceNormal},
+	)
+
+	routes := a.Routes()
+	require.Len(t, routes, 4)
+	require.Equal(t, RouteStats{RouteSerial: 10, Source: note.RoutingSourceNormal, Attempts: 3, Succeeded: 1, Attention: 2, SuccessRate: 1.0 / 3, P50Ms: 40, P95Ms: 1000}, routes[0])
+	require.Equal(t, RouteStats{RouteSerial: 10, Source: note.RoutingSourceRetry, Attempts: 1, Succeeded: 1, SuccessRate: 1, P50Ms: 20, P95Ms: 20}, routes[1])
+	require.Equal(t, int64(11), routes[2].RouteSerial)
+	require.Equal(t, note.RoutingSourceNormal, routes[2].Source)
+	require.Equal(t, note.RoutingSourceRetry, routes[3].Source)
+	require.Equal(t, int64(0), routes[3].P50Ms)
+
+	failed := a.Failed()
+	require.Len(t, failed, 3)
+	require.Equal(t, FailedEvent{EventSerial: 3, RouteSerial: 10, Attempts: 1, Status: "500", Latest: 120}, failed[0])
+	require.Equal(t, FailedEvent{EventSerial: 2, RouteSerial: 11, Attempts: 2, Status: "503", Text: "unavailable", Latest: 310}, failed[1])
+
+	plan := a.RetryPlan()
+	require.Equal(t, []string{"2", "3"}, plan.EventSerials)
+	require.Equal(t, []RouteRetry{{RouteSerial: 10, EventSerials: []string{"3"}}, {RouteSerial: 11, EventSerials: []string{"2", "3"}}}, plan.Routes)
+
+	var text bytes.Buffer
+	require.NoError(t, a.Report().WriteText(&text))
+	require.True(t, strings.HasPrefix(text.String(), "ROUTE  SOURCE          ATTEMPTS  SUCCESS  ATTN  P50   P95\n10     Normal Routing  3         33.3%    2     40ms  1000ms\n"), text.String())
+	require.Contains(t, text.String(), "2 events to reroute\n")
+
+	var js bytes.Buffer
+	require.NoError(t, a.Report().WriteJSON(&js))
+	var decoded RouteReport
+	require.NoError(t, note.JSONUnmarshal(js.Bytes(), &decoded))
+	require.Equal(t, plan, decoded.Retry)
+}
@@ -0,0 +1,405 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package analytics derives fleet-level insight from the events, sessions and route
+// logs retrieved from the notehub
+package analytics
+
+import (
+	"sort"
+
+	"github.com/blues/note-go/note"
+)
+
+// Session is a device session reconstructed from the event stream
+type Session struct {
+	SessionUID string   `json:"session"`
+	DeviceUID  string   `json:"device"`
+	ProductUID string   `json:"product,omitempty"`
+	FleetUIDs  []string `json:"fleets,omitempty"`
+	Transport  string   `json:"transport,omitempty"`
+	// Epoch seconds when the session began and ended
+	Began int64 `json:"began,omitempty"`
+	Ended int64 `json:"ended,omitempty"`
+	// Duration of the session in seconds, if it has ended
+	DurationSecs int64 `json:"duration,omitempty"`
+	// Number of user and platform events routed during the session
+	Events int64 `json:"events,omitempty"`
+	// Data transferred during the session, as seen by the notecard and by the notehub
+	Card note.DeviceUsage `json:"card,omitempty"`
+	Hub  note.DeviceUsage `json:"hub,omitempty"`
+	// Failed connection attempts made by the device prior to this session
+	FailedConnects uint32 `json:"failed_connects,omitempty"`
+	PenaltySecs    uint32 `json:"penalty_secs,omitempty"`
+	// Cumulative power consumption reported at the start of the session
+	PowerMahUsed float64 `json:"power_mah,omitempty"`
+}
+
+// Open returns true if the end of the session hasn't been seen
+func (s *Session) Open() bool {
+	return s.Ended == 0
+}
+
+// DeviceSummary aggregates the sessions of a single device
+type DeviceSummary struct {
+	DeviceUID      string   `json:"device"`
+	FleetUIDs      []string `json:"fleets,omitempty"`
+	Sessions       int      `json:"sessions"`
+	OpenSessions   int      `json:"open_sessions,omitempty"`
+	ConnectedSecs  int64    `json:"connected_secs,omitempty"`
+	Events         int64    `json:"events,omitempty"`
+	FailedConnects uint32   `json:"failed_connects,omitempty"`
+	PenaltySecs    uint32   `json:"penalty_secs,omitempty"`
+	// Fraction of connection attempts that failed
+	FailedConnectRate float64          `json:"failed_connect_rate,omitempty"`
+	Card              note.DeviceUsage `json:"card,omitempty"`
+	Hub               note.DeviceUsage `json:"hub,omitempty"`
+	// Rate of power consumption, estimated by a least-squares fit of the cumulative readings
+	PowerMahPerDay float64 `json:"power_mah_per_day,omitempty"`
+}
+
+// FleetSummary aggregates the device summaries of the devices in a fleet
+type FleetSummary struct {
+	FleetUID          string           `json:"fleet"`
+	Devices           int              `json:"devices"`
+	Sessions          int              `json:"sessions"`
+	ConnectedSecs     int64            `json:"connected_secs,omitempty"`
+	Events            int64            `json:"events,omitempty"`
+	FailedConnects    uint32           `json:"failed_connects,omitempty"`
+	FailedConnectRate float64          `json:"failed_connect_rate,omitempty"`
+	Card              note.DeviceUsage `json:"card,omitempty"`
+	Hub               note.DeviceUsage `json:"hub,omitempty"`
+	// Average rate of power consumption of the devices in the fleet
+	PowerMahPerDay float64 `json:"power_mah_per_day,omitempty"`
+}
+
+// cardCounters are the cumulative notecard counters reported at the end of a session
+type cardCounters struct {
+	rcvdBytes, sentBytes                   uint32
+	rcvdBytesSecondary, sentBytesSecondary uint32
+	tcpSessions, tlsSessions               uint32
+	rcvdNotes, sentNotes                   uint32
+}
+
+// device is the per-device state of the analyzer
+type device struct {
+	uid       string
+	fleetUIDs []string
+	sessions  []*Session
+	bySession map[string]*Session
+	counters  *cardCounters
+}
+
+// SessionAnalyzer reconstructs sessions from a time-ordered stream of events
+type SessionAnalyzer struct {
+	devices map[string]*device
+}
+
+// NewSessionAnalyzer creates an empty analyzer
+func NewSessionAnalyzer() *SessionAnalyzer {
+	return &SessionAnalyzer{devices: map[string]*device{}}
+}
+
+// device finds or creates the state for a device
+func (a *SessionAnalyzer) device(deviceUID string) *device {
+	d, present := a.devices[deviceUID]
+	if !present {
+		d = &device{uid: deviceUID, bySession: map[string]*Session{}}
+		a.devices[deviceUID] = d
+	}
+	return d
+}
+
+// session finds or creates a session of a device
+func (d *device) session(sessionUID string) *Session {
+	s, present := d.bySession[sessionUID]
+	if !present {
+		s = &Session{SessionUID: sessionUID, DeviceUID: d.uid}
+		d.bySession[sessionUID] = s
+		d.sessions = append(d.sessions, s)
+	}
+	return s
+}
+
+// delta returns the increase in a cumulative counter, treating a decrease as a reset
+func delta(previous uint32, current uint32) uint32 {
+	if current >= previous {
+		return current - previous
+	}
+	return current
+}
+
+// AddEvent incorporates an event into the analysis.  Events that aren't associated
+// with both a device and a session are ignored.
+func (a *SessionAnalyzer) AddEvent(e note.Event) {
+	if e.DeviceUID == "" || e.SessionUID == "" {
+		return
+	}
+	d := a.device(e.DeviceUID)
+	if e.FleetUIDs != nil {
+		d.fleetUIDs = *e.FleetUIDs
+	}
+	s := d.session(e.SessionUID)
+	if s.ProductUID == "" {
+		s.ProductUID = e.ProductUID
+	}
+	if e.FleetUIDs != nil {
+		s.FleetUIDs = *e.FleetUIDs
+	}
+	if s.Transport == "" {
+		s.Transport = e.Transport
+	}
+	if s.Began == 0 && e.SessionBegan != 0 {
+		s.Began = e.SessionBegan
+	}
+
+	switch e.Req {
+	case note.EventSessionBegin:
+		if s.Began == 0 {
+			s.Began = e.When
+		}
+		if e.PowerMahUsed != 0 {
+			s.PowerMahUsed = e.PowerMahUsed
+		}
+
+	case note.EventSessionEnd:
+		s.Ended = e.When
+		s.DurationSecs = e.NotehubDurationSecs
+		if s.DurationSecs == 0 && s.Began != 0 && s.Ended > s.Began {
+			s.DurationSecs = s.Ended - s.Began
+		}
+		if e.NotehubEventCount > s.Events {
+			s.Events = e.NotehubEventCount
+		}
+		s.Hub = note.DeviceUsage{
+			Since:        s.Began,
+			DurationSecs: uint32(s.DurationSecs),
+			RcvdBytes:    e.NotehubRcvdBytes,
+			SentBytes:    e.NotehubSentBytes,
+			TCPSessions:  e.NotehubTCPSessions,
+			TLSSessions:  e.NotehubTLSSessions,
+			RcvdNotes:    e.NotehubRcvdNotes,
+			SentNotes:    e.NotehubSentNotes,
+		}
+		current := cardCounters{
+			rcvdBytes:          e.NotecardRcvdBytes,
+			sentBytes:          e.NotecardSentBytes,
+			rcvdBytesSecondary: e.NotecardRcvdBytesSecondary,
+			sentBytesSecondary: e.NotecardSentBytesSecondary,
+			tcpSessions:        e.NotecardTCPSessions,
+			tlsSessions:        e.NotecardTLSSessions,
+			rcvdNotes:          e.NotecardRcvdNotes,
+			sentNotes:          e.NotecardSentNotes,
+		}
+		// The counters are cumulative over the lifetime of the notecard, so the first
+		// session end seen for a device only establishes a baseline for the next
+		if d.counters == nil {
+			d.counters = &current
+			break
+		}
+		previous := *d.counters
+		s.Card = note.DeviceUsage{
+			Since:              s.Began,
+			DurationSecs:       uint32(s.DurationSecs),
+			RcvdBytes:          delta(previous.rcvdBytes, current.rcvdBytes),
+			SentBytes:          delta(previous.sentBytes, current.sentBytes),
+			RcvdBytesSecondary: delta(previous.rcvdBytesSecondary, current.rcvdBytesSecondary),
+			SentBytesSecondary: delta(previous.sentBytesSecondary, current.sentBytesSecondary),
+			TCPSessions:        delta(previous.tcpSessions, current.tcpSessions),
+			TLSSessions:        delta(previous.tlsSessions, current.tlsSessions),
+			RcvdNotes:          delta(previous.rcvdNotes, current.rcvdNotes),
+			SentNotes:          delta(previous.sentNotes, current.sentNotes),
+		}
+		d.counters = &current
+
+	default:
+		if s.Ended == 0 {
+			s.Events++
+		}
+	}
+}
+
+// AddDeviceSession incorporates a session record retrieved from the notehub, which
+// carries connection failure and usage information that isn't present in events
+func (a *SessionAnalyzer) AddDeviceSession(ds note.DeviceSession) {
+	if ds.DeviceUID == "" || ds.SessionUID == "" {
+		return
+	}
+	d := a.device(ds.DeviceUID)
+	if len(ds.FleetUIDs) > 0 {
+		d.fleetUIDs = ds.FleetUIDs
+	}
+	s := d.session(ds.SessionUID)
+	if s.ProductUID == "" {
+		s.ProductUID = ds.ProductUID
+	}
+	if len(ds.FleetUIDs) > 0 {
+		s.FleetUIDs = ds.FleetUIDs
+	}
+	if s.Transport == "" {
+		s.Transport = ds.Transport
+	}
+	if s.Began == 0 {
+		s.Began = ds.SessionBegan
+	}
+	if s.Ended == 0 && ds.SessionEnded != 0 {
+		s.Ended = ds.SessionEnded
+		if s.Began != 0 && s.Ended > s.Began {
+			s.DurationSecs = s.Ended - s.Began
+		}
+	}
+	if ds.EventCount > s.Events {
+		s.Events = ds.EventCount
+	}
+	s.FailedConnects = ds.FailedConnects
+	s.PenaltySecs = ds.PenaltySecs
+	if ds.PowerMahUsed != 0 {
+		s.PowerMahUsed = ds.PowerMahUsed
+	}
+	if ds.PeriodPtr != nil && s.Card == (note.DeviceUsage{}) {
+		s.Card = *ds.PeriodPtr
+	}
+}
+
+// Sessions returns the sessions of a device in order of when they began
+func (a *SessionAnalyzer) Sessions(deviceUID string) (sessions []*Session) {
+	d, present := a.devices[deviceUID]
+	if !present {
+		return
+	}
+	sessions = append(sessions, d.sessions...)
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Began < sessions[j].Began })
+	return
+}
+
+// addUsage accumulates usage
+func addUsage(total *note.DeviceUsage, u note.DeviceUsage) {
+	if total.Since == 0 || (u.Since != 0 && u.Since < total.Since) {
+		total.Since = u.Since
+	}
+	total.DurationSecs += u.DurationSecs
+	total.RcvdBytes += u.RcvdBytes
+	total.SentBytes += u.SentBytes
+	total.RcvdBytesSecondary += u.RcvdBytesSecondary
+	total.SentBytesSecondary += u.SentBytesSecondary
+	total.TCPSessions += u.TCPSessions
+	total.TLSSessions += u.TLSSessions
+	total.PacketSessions += u.PacketSessions
+	total.WebhookSessions += u.WebhookSessions
+	total.RcvdNotes += u.RcvdNotes
+	total.SentNotes += u.SentNotes
+}
+
+// slopePerDay fits a line to (epoch seconds, value) points and returns its slope per day
+func slopePerDay(times []float64, values []float64) float64 {
+	n := float64(len(times))
+	if n < 2 {
+		return 0
+	}
+	var sumT, sumV, sumTT, sumTV float64
+	for i := range times {
+		t := times[i] / 86400
+		sumT += t
+		sumV += values[i]
+		sumTT += t * t
+		sumTV += t * values[i]
+	}
+	denominator := n*sumTT - sumT*sumT
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumTV - sumT*sumV) / denominator
+}
+
+// failureRate returns the fraction of connection attempts that failed
+func failureRate(failed uint32, sessions int) float64 {
+	attempts := float64(failed) + float64(sessions)
+	if attempts == 0 {
+		return 0
+	}
+	return float64(failed) / attempts
+}
+
+// Device summarizes the sessions of a single device
+func (a *SessionAnalyzer) Device(deviceUID string) (summary DeviceSummary) {
+	summary.DeviceUID = deviceUID
+	d, present := a.devices[deviceUID]
+	if !present {
+		return
+	}
+	summary.FleetUIDs = d.fleetUIDs
+	var times, power []float64
+	for _, s := range a.Sessions(deviceUID) {
+		summary.Sessions++
+		if s.Open() {
+			summary.OpenSessions++
+		}
+		summary.ConnectedSecs += s.DurationSecs
+		summary.Events += s.Events
+		summary.FailedConnects += s.FailedConnects
+		summary.PenaltySecs += s.PenaltySecs
+		addUsage(&summary.Card, s.Card)
+		addUsage(&summary.Hub, s.Hub)
+		if s.PowerMahUsed != 0 && s.Began != 0 {
+			times = append(times, float64(s.Began))
+			power = append(power, s.PowerMahUsed)
+		}
+	}
+	summary.FailedConnectRate = failureRate(summary.FailedConnects, summary.Sessions)
+	summary.PowerMahPerDay = slopePerDay(times, power)
+	return
+}
+
+// Devices summarizes every device seen, ordered by device UID
+func (a *SessionAnalyzer) Devices() (summaries []DeviceSummary) {
+	var deviceUIDs []string
+	for deviceUID := range a.devices {
+		deviceUIDs = append(deviceUIDs, deviceUID)
+	}
+	sort.Strings(deviceUIDs)
+	for _, deviceUID := range deviceUIDs {
+		summaries = append(summaries, a.Device(deviceUID))
+	}
+	return
+}
+
+// Fleets rolls device summaries up by fleet, ordered by fleet UID.  A device that is
+// in several fleets contributes to each of them, and devices in no fleet are rolled
+// up under an empty fleet UID.
+func (a *SessionAnalyzer) Fleets() (summaries []FleetSummary) {
+	byFleet := map[string]*FleetSummary{}
+	var fleetUIDs []string
+	for _, device := range a.Devices() {
+		memberOf := device.FleetUIDs
+		if len(memberOf) == 0 {
+			memberOf = []string{""}
+		}
+		for _, fleetUID := range memberOf {
+			f, present := byFleet[fleetUID]
+			if !present {
+				f = &FleetSummary{FleetUID: fleetUID}
+				byFleet[fleetUID] = f
+				fleetUIDs = append(fleetUIDs, fleetUID)
+			}
+			f.Devices++
+			f.Sessions += device.Sessions
+			f.ConnectedSecs += device.ConnectedSecs
+			f.Events += device.Events
+			f.FailedConnects += device.FailedConnects
+			addUsage(&f.Card, device.Card)
+			addUsage(&f.Hub, device.Hub)
+			f.PowerMahPerDay += device.PowerMahPerDay
+		}
+	}
+	sort.Strings(fleetUIDs)
+	for _, fleetUID := range fleetUIDs {
+		f := byFleet[fleetUID]
+		f.FailedConnectRate = failureRate(f.FailedConnects, f.Sessions)
+		if f.Devices > 0 {
+			f.PowerMahPerDay /= float64(f.Devices)
+		}
+		summaries = append(summaries, *f)
+	}
+	return
+}
@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func sessionEvents(session string, began int64, ended int64, rcvd uint32, sent uint32) []note.Event {
+	fleets := []string{"fleet:a"}
+	return []note.Event{
+		{DeviceUID: "dev:1", SessionUID: session, Req: note.EventSessionBegin, When: began, FleetUIDs: &fleets, Transport: "cell"},
+		{DeviceUID: "dev:1", SessionUID: session, Req: "note.add", When: began + 1},
+		{DeviceUID: "dev:1", SessionUID: session, Req: "note.add", When: began + 2},
+		{DeviceUID: "dev:1", SessionUID: session, Req: note.EventSessionEnd, When: ended,
+			NotehubRcvdBytes: 100, NotecardRcvdBytes: rcvd, NotecardSentBytes: sent},
+	}
+}
+
+func TestSessionReconstruction(t *testing.T) {
+	a := NewSessionAnalyzer()
+	for _, e := range sessionEvents("s1", 1000, 1060, 5000, 7000) {
+		a.AddEvent(e)
+	}
+	a.AddEvent(note.Event{DeviceUID: "dev:1", Req: "note.add"})
+
+	sessions := a.Sessions("dev:1")
+	require.Len(t, sessions, 1)
+	s := sessions[0]
+	require.False(t, s.Open())
+	require.Equal(t, int64(1000), s.Began)
+	require.Equal(t, int64(60), s.DurationSecs)
+	require.Equal(t, int64(2), s.Events)
+	require.Equal(t, "cell", s.Transport)
+	require.Equal(t, []string{"fleet:a"}, s.FleetUIDs)
+	require.Equal(t, uint32(100), s.Hub.RcvdBytes)
+
+	a.AddEvent(note.Event{DeviceUID: "dev:1", SessionUID: "s2", Req: note.EventSessionBegin, When: 2000})
+	summary := a.Device("dev:1")
+	require.Equal(t, 2, summary.Sessions)
+	require.Equal(t, 1, summary.OpenSessions)
+	require.Equal(t, int64(60), summary.ConnectedSecs)
+
+	fleets := a.Fleets()
+	require.Len(t, fleets, 1)
+	require.Equal(t, "fleet:a", fleets[0].FleetUID)
+	require.Equal(t, 1, fleets[0].Devices)
+}
+
+func TestSessionCardDeltas(t *testing.T) {
+	a := NewSessionAnalyzer()
+	for _, events := range [][]note.Event{
+		sessionEvents("s1", 1000, 1060, 5000, 7000),
+		sessionEvents("s2", 2000, 2060, 5300, 7100),
+		// The notecard was reset, so its counters started again from zero
+		sessionEvents("s3", 3000, 3060, 40, 20),
+	} {
+		for _, e := range events {
+			a.AddEvent(e)
+		}
+	}
+	sessions := a.Sessions("dev:1")
+	require.Len(t, sessions, 3)
+
+	// The first session only establishes a baseline, rather than being billed with the
+	// lifetime counters of the notecard
+	require.Equal(t, uint32(0), sessions[0].Card.RcvdBytes)
+	require.Equal(t, uint32(0), sessions[0].Card.SentBytes)
+	require.Equal(t, uint32(300), sessions[1].Card.RcvdBytes)
+	require.Equal(t, uint32(100), sessions[1].Card.SentBytes)
+	require.Equal(t, uint32(40), sessions[2].Card.RcvdBytes)
+	require.Equal(t, uint32(20), sessions[2].Card.SentBytes)
+
+	summary := a.Device("dev:1")
+	require.Equal(t, uint32(340), summary.Card.RcvdBytes)
+	require.Equal(t, uint32(120), summary.Card.SentBytes)
+}
+
+func TestDeviceSessionWithoutBegin(t *testing.T) {
+	a := NewSessionAnalyzer()
+	a.AddDeviceSession(note.DeviceSession{DeviceUID: "dev:1", SessionUID: "s1", SessionBegan: 1000, SessionEnded: 1060})
+	// Without a begin time the duration is unknown, rather than the end time itself
+	a.AddDeviceSession(note.DeviceSession{DeviceUID: "dev:1", SessionUID: "s2", SessionEnded: 1700000000})
+	sessions := a.Sessions("dev:1")
+	require.Len(t, sessions, 2)
+	require.Equal(t, "s2", sessions[0].SessionUID)
+	require.Equal(t, int64(0), sessions[0].DurationSecs)
+	require.Equal(t, int64(60), a.Device("dev:1").ConnectedSecs)
+}
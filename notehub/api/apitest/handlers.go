@@ -0,0 +1,583 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub/api"
+)
+
+// writeJSON responds with an object
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with a canonical error
+func writeError(w http.ResponseWriter, r *http.Request, e api.ErrorResponse) {
+	e = e.WithRequest(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Code)
+	json.NewEncoder(w).Encode(e)
+}
+
+// readJSON decodes the body of a request, responding with an error if it can't
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeError(w, r, api.ErrBadRequest().WithError(err))
+		return false
+	}
+	return true
+}
+
+// methodNotAllowed responds to a request whose method isn't supported by the endpoint
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, api.ErrMethodNotAllowed())
+}
+
+// pageOf computes the bounds of a page of a list of n items, given the 1-based page
+// number and page size in the query
+func pageOf(r *http.Request, n int) (start int, end int, hasMore bool) {
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize <= 0 {
+		pageSize = api.DefaultPageSize
+	}
+	pageNum, _ := strconv.Atoi(r.URL.Query().Get("pageNum"))
+	if pageNum <= 0 {
+		pageNum = 1
+	}
+	start = (pageNum - 1) * pageSize
+	if start > n {
+		start = n
+	}
+	end = start + pageSize
+	if end > n {
+		end = n
+	}
+	return start, end, end < n
+}
+
+func (s *Server) serveProjects(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) == 0 {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+		rsp := api.GetProjectsResponse{Projects: []api.GetAppResponse{}}
+		for _, p := range s.projects {
+			rsp.Projects = append(rsp.Projects, p.app)
+		}
+		sort.Slice(rsp.Projects, func(i, j int) bool { return rsp.Projects[i].UID < rsp.Projects[j].UID })
+		writeJSON(w, rsp)
+		return
+	}
+
+	p := s.projects[segments[0]]
+	if p == nil {
+		writeError(w, r, api.ErrNotFound().WithError(fmt.Errorf("project not found: %s", segments[0])))
+		return
+	}
+	if len(segments) == 1 {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+		writeJSON(w, p.app)
+		return
+	}
+
+	switch segments[1] {
+	case "environment_variables":
+		s.serveVars(w, r, p.env, segments[2:], func(vars map[string]string) { p.env = vars })
+	case "products":
+		s.serveProducts(w, r, p, segments[2:])
+	case "fleets":
+		s.serveFleets(w, r, p, segments[2:])
+	case "devices":
+		s.serveDevices(w, r, p, segments[2:])
+	case "events":
+		s.serveEvents(w, r, p)
+	case "events-cursor":
+		s.serveEventsCursor(w, r, p)
+	default:
+		writeError(w, r, api.ErrNotFound())
+	}
+}
+
+// serveVars serves a set of environment variables: GET and PUT on the collection, which
+// merges the supplied variables, and DELETE on a single variable.  Each responds with
+// the full set of variables.
+func (s *Server) serveVars(w http.ResponseWriter, r *http.Request, vars map[string]string, segments []string, set func(map[string]string)) {
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodGet:
+	case len(segments) == 0 && r.Method == http.MethodPut:
+		req := api.PutAppEnvironmentVariablesRequest{}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		vars = copyVars(vars)
+		for k, v := range req.EnvironmentVariables {
+			vars[k] = v
+		}
+		set(vars)
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		if _, present := vars[segments[0]]; !present {
+			writeError(w, r, api.ErrNotFound().WithError(fmt.Errorf("environment variable not found: %s", segments[0])))
+			return
+		}
+		vars = copyVars(vars)
+		delete(vars, segments[0])
+		set(vars)
+	case len(segments) > 1:
+		writeError(w, r, api.ErrNotFound())
+		return
+	default:
+		methodNotAllowed(w, r)
+		return
+	}
+	writeJSON(w, api.GetAppEnvironmentVariablesResponse{EnvironmentVariables: vars})
+}
+
+func (s *Server) serveProducts(w http.ResponseWriter, r *http.Request, p *project, segments []string) {
+	if len(segments) != 0 {
+		writeError(w, r, api.ErrNotFound())
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		rsp := api.GetProductsResponse{Products: []api.ProductResponse{}}
+		for _, product := range p.products {
+			rsp.Products = append(rsp.Products, product)
+		}
+		sort.Slice(rsp.Products, func(i, j int) bool { return rsp.Products[i].UID < rsp.Products[j].UID })
+		writeJSON(w, rsp)
+	case http.MethodPost:
+		req := api.PostProductRequest{}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.ProductUID == "" || req.Label == "" {
+			writeError(w, r, api.ErrBadRequest().WithError(fmt.Errorf("product_uid and label are required")))
+			return
+		}
+		if _, exists := p.products[req.ProductUID]; exists {
+			writeError(w, r, api.ErrConflict().WithError(fmt.Errorf("product already exists: %s", req.ProductUID)))
+			return
+		}
+		product := api.ProductResponse{UID: req.ProductUID, Label: req.Label, DisableDevicesByDefault: req.DisableDevicesByDefault}
+		if req.AutoProvisionFleets != nil {
+			fleets := append([]string{}, req.AutoProvisionFleets...)
+			product.AutoProvisionFleets = &fleets
+		}
+		p.products[product.UID] = product
+		writeJSON(w, product)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+// setDeviceFleets adds or removes a device from fleets
+func setDeviceFleets(d *device, fleetUIDs []string, add bool) {
+	for _, fleetUID := range fleetUIDs {
+		var remaining []string
+		present := false
+		for _, existing := range d.rsp.FleetUIDs {
+			if existing == fleetUID {
+				present = true
+				if !add {
+					continue
+				}
+			}
+			remaining = append(remaining, existing)
+		}
+		if add && !present {
+			remaining = append(remaining, fleetUID)
+		}
+		d.rsp.FleetUIDs = remaining
+	}
+}
+
+func (s *Server) serveFleets(w http.ResponseWriter, r *http.Request, p *project, segments []string) {
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, fleetList(p, nil))
+		case http.MethodPost:
+			req := api.PostFleetRequest{}
+			if !readJSON(w, r, &req) {
+				return
+			}
+			if req.Label == "" {
+				writeError(w, r, api.ErrBadRequest().WithError(fmt.Errorf("label is required")))
+				return
+			}
+			p.fleetsCreated++
+			fleet := &api.FleetResponse{
+				UID:                  fmt.Sprintf("fleet:%08d", p.fleetsCreated),
+				Label:                req.Label,
+				Created:              now(),
+				EnvironmentVariables: map[string]string{},
+				SmartRule:            req.SmartRule,
+				WatchdogMins:         req.WatchdogMins,
+			}
+			p.fleets[fleet.UID] = fleet
+			writeJSON(w, fleet)
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	fleet := p.fleets[segments[0]]
+	if fleet == nil {
+		writeError(w, r, api.ErrNotFound().WithError(fmt.Errorf("fleet not found: %s", segments[0])))
+		return
+	}
+	if len(segments) > 1 {
+		if segments[1] != "environment_variables" {
+			writeError(w, r, api.ErrNotFound())
+			return
+		}
+		s.serveVars(w, r, fleet.EnvironmentVariables, segments[2:], func(vars map[string]string) { fleet.EnvironmentVariables = vars })
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, fleet)
+	case http.MethodPut:
+		req := api.PutFleetRequest{}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		for _, deviceUID := range append(append([]string{}, req.AddDevices...), req.RemoveDevices...) {
+			if p.devices[deviceUID] == nil {
+				writeError(w, r, api.ErrNotFound().WithError(fmt.Errorf("device not found: %s", deviceUID)))
+				return
+			}
+		}
+		if req.Label != "" {
+			fleet.Label = req.Label
+		}
+		if req.SmartRule != "" {
+			fleet.SmartRule = req.SmartRule
+		}
+		if req.WatchdogMins != 0 {
+			fleet.WatchdogMins = req.WatchdogMins
+		}
+		for _, deviceUID := range req.AddDevices {
+			setDeviceFleets(p.devices[deviceUID], []string{fleet.UID}, true)
+		}
+		for _, deviceUID := range req.RemoveDevices {
+			setDeviceFleets(p.devices[deviceUID], []string{fleet.UID}, false)
+		}
+		writeJSON(w, fleet)
+	case http.MethodDelete:
+		for _, d := range p.devices {
+			setDeviceFleets(d, []string{fleet.UID}, false)
+		}
+		delete(p.fleets, fleet.UID)
+		writeJSON(w, struct{}{})
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+// fleetList returns the fleets of a project, or only those listed if not nil
+func fleetList(p *project, fleetUIDs []string) (rsp api.GetFleetsResponse) {
+	rsp.Fleets = []api.FleetResponse{}
+	for uid, fleet := range p.fleets {
+		if fleetUIDs != nil && !contains(fleetUIDs, uid) {
+			continue
+		}
+		rsp.Fleets = append(rsp.Fleets, *fleet)
+	}
+	sort.Slice(rsp.Fleets, func(i, j int) bool { return rsp.Fleets[i].UID < rsp.Fleets[j].UID })
+	return
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) serveDevices(w http.ResponseWriter, r *http.Request, p *project, segments []string) {
+	if len(segments) == 0 || segments[0] == "public-keys" {
+		if r.Method != http.MethodGet || len(segments) > 1 {
+			methodNotAllowed(w, r)
+			return
+		}
+		var uids []string
+		for uid := range p.devices {
+			uids = append(uids, uid)
+		}
+		sort.Strings(uids)
+		start, end, hasMore := pageOf(r, len(uids))
+		if len(segments) == 1 {
+			rsp := api.GetDevicesPublicKeysResponse{DevicePublicKeys: []api.DevicePublicKey{}, HasMore: hasMore}
+			for _, uid := range uids[start:end] {
+				rsp.DevicePublicKeys = append(rsp.DevicePublicKeys, api.DevicePublicKey{UID: uid, PublicKey: "-----BEGIN PUBLIC KEY-----\n" + uid + "\n-----END PUBLIC KEY-----"})
+			}
+			writeJSON(w, rsp)
+			return
+		}
+		rsp := api.GetDevicesResponse{Devices: []api.GetDeviceResponse{}, HasMore: hasMore}
+		for _, uid := range uids[start:end] {
+			rsp.Devices = append(rsp.Devices, p.devices[uid].rsp)
+		}
+		writeJSON(w, rsp)
+		return
+	}
+
+	deviceUID := segments[0]
+	d := p.devices[deviceUID]
+	if len(segments) == 2 && segments[1] == "provision" {
+		s.serveProvision(w, r, p, deviceUID)
+		return
+	}
+	if d == nil {
+		writeError(w, r, api.ErrNotFound().WithError(fmt.Errorf("device not found: %s", deviceUID)))
+		return
+	}
+
+	if len(segments) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, d.rsp)
+		case http.MethodDelete:
+			delete(p.devices, deviceUID)
+			if r.URL.Query().Get("purge") == "true" {
+				var events []note.Event
+				for _, event := range p.events {
+					if event.DeviceUID != deviceUID {
+						events = append(events, event)
+					}
+				}
+				p.events = events
+			}
+			writeJSON(w, struct{}{})
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	switch segments[1] {
+	case "enable", "disable":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+		d.rsp.Disabled = segments[1] == "disable"
+		writeJSON(w, struct{}{})
+	case "latest":
+		latest := map[string]note.Event{}
+		for _, event := range p.events {
+			if event.DeviceUID == deviceUID {
+				latest[event.NotefileID] = event
+			}
+		}
+		rsp := api.GetDeviceLatestResponse{LatestEvents: []note.Event{}}
+		for _, event := range latest {
+			rsp.LatestEvents = append(rsp.LatestEvents, event)
+		}
+		sort.Slice(rsp.LatestEvents, func(i, j int) bool { return rsp.LatestEvents[i].NotefileID < rsp.LatestEvents[j].NotefileID })
+		writeJSON(w, rsp)
+	case "health-log":
+		writeJSON(w, api.GetDeviceHealthLogResponse{HealthLog: append([]api.HealthLogEntry{}, d.healthLog...)})
+	case "sessions":
+		start, end, hasMore := pageOf(r, len(d.sessions))
+		writeJSON(w, api.GetDeviceSessionsResponse{Sessions: append([]note.DeviceSession{}, d.sessions[start:end]...), HasMore: hasMore})
+	case "fleets":
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodDelete:
+			req := api.PutDeviceFleetsRequest{}
+			if !readJSON(w, r, &req) {
+				return
+			}
+			for _, fleetUID := range req.FleetUIDs {
+				if p.fleets[fleetUID] == nil {
+					writeError(w, r, api.ErrNotFound().WithError(fmt.Errorf("fleet not found: %s", fleetUID)))
+					return
+				}
+			}
+			setDeviceFleets(d, req.FleetUIDs, r.Method == http.MethodPut)
+		default:
+			methodNotAllowed(w, r)
+			return
+		}
+		writeJSON(w, fleetList(p, append([]string{}, d.rsp.FleetUIDs...)))
+	case "environment_variables":
+		if len(segments) == 2 && r.Method == http.MethodGet {
+			writeJSON(w, api.GetDeviceEnvironmentVariablesResponse{
+				EnvironmentVariables:           d.env,
+				EnvironmentVariablesEnvDefault: d.envDefault,
+				EnvironmentVariablesEffective:  s.effective(p, d),
+			})
+			return
+		}
+		s.serveVars(w, r, d.env, segments[2:], func(vars map[string]string) { d.env = vars })
+	default:
+		writeError(w, r, api.ErrNotFound())
+	}
+}
+
+func (s *Server) serveProvision(w http.ResponseWriter, r *http.Request, p *project, deviceUID string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	req := api.ProvisionDeviceRequest{}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if _, exists := p.products[req.ProductUID]; !exists {
+		writeError(w, r, api.ErrNotFound().WithError(fmt.Errorf("product not found: %s", req.ProductUID)))
+		return
+	}
+	if p.devices[deviceUID] != nil {
+		writeError(w, r, api.ErrConflict().WithError(fmt.Errorf("device already provisioned: %s", deviceUID)))
+		return
+	}
+	d := &device{
+		rsp:        api.GetDeviceResponse{UID: deviceUID, SerialNumber: req.DeviceSN, ProductUID: req.ProductUID, Provisioned: now()},
+		env:        map[string]string{},
+		envDefault: map[string]string{},
+	}
+	if req.FleetUIDs != nil {
+		setDeviceFleets(d, *req.FleetUIDs, true)
+	}
+	p.devices[deviceUID] = d
+	writeJSON(w, struct{}{})
+}
+
+// filterEvents returns the events of a project matching the query of a request
+func filterEvents(r *http.Request, p *project) (events []note.Event) {
+	query := r.URL.Query()
+	deviceUIDs := query["deviceUID"]
+	var files []string
+	if query.Get("files") != "" {
+		files = strings.Split(query.Get("files"), ",")
+	}
+	startDate, _ := strconv.ParseFloat(query.Get("startDate"), 64)
+	endDate, _ := strconv.ParseFloat(query.Get("endDate"), 64)
+	for _, event := range p.events {
+		if len(deviceUIDs) > 0 && !contains(deviceUIDs, event.DeviceUID) {
+			continue
+		}
+		if len(files) > 0 && !contains(files, event.NotefileID) {
+			continue
+		}
+		if (startDate != 0 && event.Received < startDate) || (endDate != 0 && event.Received > endDate) {
+			continue
+		}
+		events = append(events, event)
+	}
+	if query.Get("sortOrder") == "desc" {
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+	}
+	return
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request, p *project) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	events := filterEvents(r, p)
+	start, end, hasMore := pageOf(r, len(events))
+	rsp := api.GetEventsResponse{Events: append([]note.Event{}, events[start:end]...), HasMore: hasMore}
+	if end > start {
+		rsp.Through = events[end-1].EventUID
+	}
+	writeJSON(w, rsp)
+}
+
+// serveEventsCursor serves events by cursor, where the cursor is simply the position
+// within the filtered list at which the next page begins
+func (s *Server) serveEventsCursor(w http.ResponseWriter, r *http.Request, p *project) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	events := filterEvents(r, p)
+	start := 0
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		var err error
+		start, err = strconv.Atoi(cursor)
+		if err != nil || start < 0 || start > len(events) {
+			writeError(w, r, api.ErrBadRequest().WithError(fmt.Errorf("invalid cursor: %s", cursor)))
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = api.DefaultPageSize
+	}
+	end := start + limit
+	if end > len(events) {
+		end = len(events)
+	}
+	writeJSON(w, api.GetEventsByCursorResponse{
+		Events:     append([]note.Event{}, events[start:end]...),
+		NextCursor: strconv.Itoa(end),
+		HasMore:    end < len(events),
+	})
+}
+
+// serveWithPIN serves the device environment variables that may be accessed with a PIN
+func (s *Server) serveWithPIN(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) != 5 || segments[2] != "devices" || segments[4] != "environment_variables_with_pin" {
+		writeError(w, r, api.ErrNotFound())
+		return
+	}
+	productUID, deviceUID := segments[1], segments[3]
+	var p *project
+	var d *device
+	for _, candidate := range s.projects {
+		if dev := candidate.devices[deviceUID]; dev != nil && dev.rsp.ProductUID == productUID {
+			p, d = candidate, dev
+		}
+	}
+	if d == nil {
+		writeError(w, r, api.ErrNotFound().WithError(fmt.Errorf("device not found: %s", deviceUID)))
+		return
+	}
+	if d.pin == "" || r.Header.Get(api.PINHeader) != d.pin {
+		writeError(w, r, api.ErrUnauthorized())
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, api.GetDeviceEnvironmentVariablesWithPINResponse{EnvironmentVariables: s.effective(p, d), EnvironmentVariablesEnvDefault: d.envDefault})
+	case http.MethodPut:
+		req := api.PutDeviceEnvironmentVariablesWithPINRequest{}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		d.env = copyVars(d.env)
+		for k, v := range req.EnvironmentVariables {
+			d.env[k] = v
+		}
+		writeJSON(w, api.PutDeviceEnvironmentVariablesWithPINResponse{EnvironmentVariables: d.env})
+	default:
+		methodNotAllowed(w, r)
+	}
+}
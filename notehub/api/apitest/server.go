@@ -0,0 +1,262 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package apitest provides an in-memory fake of the Notehub API for tests.  It serves
+// the endpoints whose request and response shapes are defined in notehub/api, keeps
+// its state in memory, and fails with the canonical errors of error_defaults.go.
+package apitest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub/api"
+	"github.com/blues/note-go/notehub/env"
+)
+
+// Server is a fake Notehub API listening on a local address.  Seed it with the Add
+// methods, point an api.Client at its URL, and Close it when done.
+type Server struct {
+	*httptest.Server
+
+	// Token, if not empty, is the bearer token that requests must present
+	Token string
+
+	lock            sync.Mutex
+	projects        map[string]*project
+	billingAccounts []api.GetBillingAccountResponse
+}
+
+type project struct {
+	app      api.GetAppResponse
+	env      map[string]string
+	products map[string]api.ProductResponse
+	fleets   map[string]*api.FleetResponse
+	devices  map[string]*device
+	events   []note.Event
+	// Number of fleets created through the API, used to generate fleet UIDs
+	fleetsCreated int
+}
+
+type device struct {
+	rsp        api.GetDeviceResponse
+	env        map[string]string
+	envDefault map[string]string
+	pin        string
+	sessions   []note.DeviceSession
+	healthLog  []api.HealthLogEntry
+}
+
+// NewServer starts a fake Notehub with no projects
+func NewServer() *Server {
+	s := &Server{projects: map[string]*project{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// now returns the current time in the format used by the API
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// AddProject creates a project
+func (s *Server) AddProject(projectUID string, label string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.projects[projectUID] = &project{
+		app:      api.GetAppResponse{UID: projectUID, Label: label, Created: now()},
+		env:      map[string]string{},
+		products: map[string]api.ProductResponse{},
+		fleets:   map[string]*api.FleetResponse{},
+		devices:  map[string]*device{},
+	}
+}
+
+// AddBillingAccount adds a billing account visible to the caller
+func (s *Server) AddBillingAccount(account api.GetBillingAccountResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.billingAccounts = append(s.billingAccounts, account)
+}
+
+// mustProject returns a project, panicking if it doesn't exist, for use while seeding
+func (s *Server) mustProject(projectUID string) *project {
+	p := s.projects[projectUID]
+	if p == nil {
+		panic(fmt.Sprintf("apitest: no such project: %s", projectUID))
+	}
+	return p
+}
+
+// mustDevice returns a device, panicking if it doesn't exist, for use while seeding
+func (s *Server) mustDevice(projectUID string, deviceUID string) *device {
+	d := s.mustProject(projectUID).devices[deviceUID]
+	if d == nil {
+		panic(fmt.Sprintf("apitest: no such device: %s", deviceUID))
+	}
+	return d
+}
+
+// AddProduct adds a product to a project
+func (s *Server) AddProduct(projectUID string, product api.ProductResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mustProject(projectUID).products[product.UID] = product
+}
+
+// AddFleet adds a fleet to a project
+func (s *Server) AddFleet(projectUID string, fleet api.FleetResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if fleet.Created == "" {
+		fleet.Created = now()
+	}
+	if fleet.EnvironmentVariables == nil {
+		fleet.EnvironmentVariables = map[string]string{}
+	}
+	s.mustProject(projectUID).fleets[fleet.UID] = &fleet
+}
+
+// AddDevice adds a device to a project
+func (s *Server) AddDevice(projectUID string, dev api.GetDeviceResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if dev.Provisioned == "" {
+		dev.Provisioned = now()
+	}
+	s.mustProject(projectUID).devices[dev.UID] = &device{rsp: dev, env: map[string]string{}, envDefault: map[string]string{}}
+}
+
+// SetDeviceEnvDefaults sets the environment variable defaults reported by a device itself
+func (s *Server) SetDeviceEnvDefaults(projectUID string, deviceUID string, vars map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mustDevice(projectUID, deviceUID).envDefault = copyVars(vars)
+}
+
+// SetDevicePIN sets the PIN that authorizes access to a device's environment variables
+func (s *Server) SetDevicePIN(projectUID string, deviceUID string, pin string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mustDevice(projectUID, deviceUID).pin = pin
+}
+
+// AddSession records a session of a device
+func (s *Server) AddSession(projectUID string, session note.DeviceSession) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	d := s.mustDevice(projectUID, session.DeviceUID)
+	d.sessions = append(d.sessions, session)
+	sort.SliceStable(d.sessions, func(i, j int) bool { return d.sessions[i].When > d.sessions[j].When })
+}
+
+// AddHealthLogEntry adds an entry to a device's health log
+func (s *Server) AddHealthLogEntry(projectUID string, deviceUID string, entry api.HealthLogEntry) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	d := s.mustDevice(projectUID, deviceUID)
+	d.healthLog = append(d.healthLog, entry)
+}
+
+// AddEvent records an event routed within a project, in the order received
+func (s *Server) AddEvent(projectUID string, event note.Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p := s.mustProject(projectUID)
+	if event.AppUID == "" {
+		event.AppUID = projectUID
+	}
+	if event.EventUID == "" {
+		event.EventUID = fmt.Sprintf("event-%d", len(p.events)+1)
+	}
+	if event.Received == 0 {
+		event.Received = float64(time.Now().UnixNano()) / 1e9
+	}
+	p.events = append(p.events, event)
+}
+
+// Device returns the current state of a device, for assertions
+func (s *Server) Device(projectUID string, deviceUID string) (dev api.GetDeviceResponse, found bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p := s.projects[projectUID]
+	if p == nil || p.devices[deviceUID] == nil {
+		return
+	}
+	return p.devices[deviceUID].rsp, true
+}
+
+// EffectiveEnvironment returns the environment variables in effect on a device, layered
+// from lowest to highest precedence: the device's own defaults, the project, the
+// device's fleets in order of fleet UID, and finally the device itself
+func (s *Server) EffectiveEnvironment(projectUID string, deviceUID string) map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.effective(s.mustProject(projectUID), s.mustDevice(projectUID, deviceUID))
+}
+
+func (s *Server) effective(p *project, d *device) (vars map[string]string) {
+	layers := env.Layers{
+		EnvDefault: d.envDefault,
+		Project:    p.env,
+		Fleets:     map[string]map[string]string{},
+		Device:     d.env,
+	}
+	for _, fleetUID := range d.rsp.FleetUIDs {
+		if fleet := p.fleets[fleetUID]; fleet != nil {
+			layers.Fleets[fleetUID] = fleet.EnvironmentVariables
+		}
+	}
+	return layers.Resolve().Vars()
+}
+
+func copyVars(vars map[string]string) map[string]string {
+	c := map[string]string{}
+	for k, v := range vars {
+		c[k] = v
+	}
+	return c
+}
+
+// serveHTTP authenticates a request and dispatches it by path
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "v1" {
+		writeError(w, r, api.ErrNotFound())
+		return
+	}
+	segments = segments[1:]
+
+	// Device environment variables may be accessed with a PIN in lieu of a token
+	if segments[0] == "products" {
+		s.serveWithPIN(w, r, segments)
+		return
+	}
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, r, api.ErrUnauthorized())
+		return
+	}
+
+	switch segments[0] {
+	case "billing-accounts":
+		if r.Method != http.MethodGet {
+			writeError(w, r, api.ErrMethodNotAllowed())
+			return
+		}
+		writeJSON(w, api.GetBillingAccountsResponse{BillingAccounts: append([]api.GetBillingAccountResponse{}, s.billingAccounts...)})
+	case "projects":
+		s.serveProjects(w, r, segments[1:])
+	default:
+		writeError(w, r, api.ErrNotFound())
+	}
+}
@@ -0,0 +1,79 @@
+package apitest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Token = "tok"
+	s.AddProject("app:1", "Test")
+	s.AddProduct("app:1", api.ProductResponse{UID: "com.example:test", Label: "Test"})
+	s.AddDevice("app:1", api.GetDeviceResponse{UID: "dev:1", ProductUID: "com.example:test"})
+	s.SetDeviceEnvDefaults("app:1", "dev:1", map[string]string{"a": "default", "b": "default"})
+	for _, file := range []string{"data.qo", "data.qo", "track.qo"} {
+		s.AddEvent("app:1", note.Event{DeviceUID: "dev:1", NotefileID: file})
+	}
+
+	ctx := context.Background()
+	c := api.NewClient(s.URL, "tok")
+
+	_, err := api.NewClient(s.URL, "wrong").GetProjects(ctx)
+	require.Equal(t, http.StatusUnauthorized, api.StatusCode(err))
+
+	fleet, err := c.CreateFleet(ctx, "app:1", api.PostFleetRequest{Label: "Field"})
+	require.NoError(t, err)
+	_, err = c.AddDeviceToFleets(ctx, "app:1", "dev:1", api.PutDeviceFleetsRequest{FleetUIDs: []string{fleet.UID}})
+	require.NoError(t, err)
+	_, err = c.AddDeviceToFleets(ctx, "app:1", "dev:1", api.PutDeviceFleetsRequest{FleetUIDs: []string{"fleet:nope"}})
+	require.True(t, api.IsNotFound(err))
+
+	// Layering: device > fleet > project > device defaults
+	_, err = c.PutAppEnvironmentVariables(ctx, "app:1", api.PutAppEnvironmentVariablesRequest{EnvironmentVariables: map[string]string{"a": "project", "b": "project"}})
+	require.NoError(t, err)
+	_, err = c.PutFleetEnvironmentVariables(ctx, "app:1", fleet.UID, api.PutFleetEnvironmentVariablesRequest{EnvironmentVariables: map[string]string{"b": "fleet"}})
+	require.NoError(t, err)
+	_, err = c.PutDeviceEnvironmentVariables(ctx, "app:1", "dev:1", api.PutDeviceEnvironmentVariablesRequest{EnvironmentVariables: map[string]string{"c": "device"}})
+	require.NoError(t, err)
+	vars, err := c.GetDeviceEnvironmentVariables(ctx, "app:1", "dev:1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": "project", "b": "fleet", "c": "device"}, vars.EnvironmentVariablesEffective)
+	require.Equal(t, "default", vars.EnvironmentVariablesEnvDefault["a"])
+
+	_, err = c.DeleteAppEnvironmentVariable(ctx, "app:1", "zzz")
+	require.True(t, api.IsNotFound(err))
+
+	s.SetDevicePIN("app:1", "dev:1", "1234")
+	pinVars, err := api.NewClient(s.URL, "").GetDeviceEnvironmentVariablesWithPIN(ctx, "com.example:test", "dev:1", "1234")
+	require.NoError(t, err)
+	require.Equal(t, "fleet", pinVars.EnvironmentVariables["b"])
+
+	_, err = c.CreateProduct(ctx, "app:1", api.PostProductRequest{ProductUID: "com.example:test", Label: "Again"})
+	require.Equal(t, http.StatusConflict, api.StatusCode(err))
+
+	it := c.IterateEvents(ctx, "app:1", api.EventsQuery{Files: []string{"data.qo"}, Limit: 1})
+	count := 0
+	for it.Next() {
+		count++
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, 2, count)
+
+	latest, err := c.GetDeviceLatest(ctx, "app:1", "dev:1")
+	require.NoError(t, err)
+	require.Len(t, latest.LatestEvents, 2)
+
+	require.NoError(t, c.DisableDevice(ctx, "app:1", "dev:1"))
+	dev, _ := s.Device("app:1", "dev:1")
+	require.True(t, dev.Disabled)
+
+	require.NoError(t, c.DeleteFleet(ctx, "app:1", fleet.UID))
+	require.Equal(t, map[string]string{"a": "project", "b": "project", "c": "device"}, s.EffectiveEnvironment("app:1", "dev:1"))
+}
@@ -0,0 +1,241 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+)
+
+// DefaultBaseURL is the base URL of the Notehub API
+const DefaultBaseURL = "https://api.notefile.net"
+
+// DefaultPageSize is the number of items requested per page when iterating
+const DefaultPageSize = 50
+
+// Client is a client of the Notehub REST API.  Its fields may be changed after it is
+// created but not while requests are in progress.
+type Client struct {
+	// BaseURL is the scheme and host of the API, such as https://api.notefile.net
+	BaseURL string
+
+	// Token is an OAuth access token or personal access token, sent as a bearer token
+	Token string
+
+	// SessionToken is a legacy session token, sent only if Token is empty
+	SessionToken string
+
+	// HTTPClient performs the requests.  If nil, a client with a 60s timeout is used.
+	HTTPClient *http.Client
+
+	// UserAgent is sent with every request, if not empty
+	UserAgent string
+}
+
+// NewClient returns a client of the API at the specified base URL, or at the default
+// URL if empty, authenticated with a bearer token
+func NewClient(baseURL string, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// ResponseError is returned when the API responds with an error status.  The error
+// response is decoded from the body when present, and is otherwise synthesized from
+// the status.
+type ResponseError struct {
+	Response ErrorResponse
+}
+
+// Error returns the error message along with the status
+func (e *ResponseError) Error() string {
+	msg := e.Response.Error
+	if msg == "" {
+		msg = e.Response.Status
+	}
+	if e.Response.Request != "" {
+		return fmt.Sprintf("%s (%d %s)", msg, e.Response.Code, e.Response.Request)
+	}
+	return fmt.Sprintf("%s (%d)", msg, e.Response.Code)
+}
+
+// Is matches any error with the same code, so that errors.Is(err, &ResponseError{Response: ErrNotFound()})
+// determines whether the API returned 404
+func (e *ResponseError) Is(target error) bool {
+	t, ok := target.(*ResponseError)
+	return ok && t.Response.Code == e.Response.Code
+}
+
+// AsErrorResponse extracts the API's error response from an error returned by the client
+func AsErrorResponse(err error) (rsp ErrorResponse, ok bool) {
+	var rerr *ResponseError
+	if errors.As(err, &rerr) {
+		return rerr.Response, true
+	}
+	return
+}
+
+// StatusCode returns the HTTP status of an error returned by the client, or 0 if the
+// error wasn't returned by the API
+func StatusCode(err error) int {
+	rsp, ok := AsErrorResponse(err)
+	if !ok {
+		return 0
+	}
+	return rsp.Code
+}
+
+// IsNotFound determines whether an error indicates that the requested resource doesn't exist
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// pathEscape joins path segments, escaping each
+func pathEscape(segments ...string) string {
+	var escaped []string
+	for _, s := range segments {
+		escaped = append(escaped, url.PathEscape(s))
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// do performs a request, encoding the request object (if any) as JSON and decoding the
+// response into the response object (if any)
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, header http.Header, in interface{}, out interface{}) (err error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		var reqJSON []byte
+		reqJSON, err = note.JSONMarshal(in)
+		if err != nil {
+			return
+		}
+		body = bytes.NewReader(reqJSON)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.SessionToken != "" {
+		req.Header.Set("X-Session-Token", c.SessionToken)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	rsp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer rsp.Body.Close()
+	rspJSON, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return
+	}
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		errRsp := ErrorResponse{}
+		if note.JSONUnmarshal(rspJSON, &errRsp) != nil || (errRsp.Error == "" && errRsp.Code == 0) {
+			errRsp = ErrorResponse{Error: strings.TrimSpace(string(rspJSON))}
+		}
+		if errRsp.Code == 0 {
+			errRsp.Code = rsp.StatusCode
+		}
+		if errRsp.Status == "" {
+			errRsp.Status = http.StatusText(rsp.StatusCode)
+		}
+		if errRsp.Request == "" {
+			errRsp.Request = method + " " + path
+		}
+		return &ResponseError{Response: errRsp}
+	}
+
+	if out == nil || len(bytes.TrimSpace(rspJSON)) == 0 {
+		return
+	}
+	err = note.JSONUnmarshal(rspJSON, out)
+	if err != nil {
+		return fmt.Errorf("cannot decode response to %s %s: %w", method, path, err)
+	}
+	return
+}
+
+// ListOptions selects a page of a paginated list
+type ListOptions struct {
+	PageSize int
+	PageNum  int
+}
+
+// query converts list options to query parameters
+func (opts ListOptions) query() url.Values {
+	query := url.Values{}
+	if opts.PageSize > 0 {
+		query.Set("pageSize", fmt.Sprintf("%d", opts.PageSize))
+	}
+	if opts.PageNum > 0 {
+		query.Set("pageNum", fmt.Sprintf("%d", opts.PageNum))
+	}
+	return query
+}
+
+// pager tracks the position of an iterator over a paginated list
+type pager struct {
+	opts ListOptions
+	done bool
+	err  error
+}
+
+func newPager(pageSize int) pager {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return pager{opts: ListOptions{PageSize: pageSize, PageNum: 0}}
+}
+
+// next advances to the next page, returning false when there are no more pages
+func (p *pager) next() (opts ListOptions, more bool) {
+	if p.done || p.err != nil {
+		return opts, false
+	}
+	p.opts.PageNum++
+	return p.opts, true
+}
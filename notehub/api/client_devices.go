@@ -0,0 +1,182 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/blues/note-go/note"
+)
+
+// GetDevices returns a page of the devices in a project
+func (c *Client) GetDevices(ctx context.Context, projectUID string, opts ListOptions) (rsp GetDevicesResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "devices"), opts.query(), nil, nil, &rsp)
+	return
+}
+
+// GetDevice returns a device
+func (c *Client) GetDevice(ctx context.Context, projectUID string, deviceUID string) (rsp GetDeviceResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "devices", deviceUID), nil, nil, nil, &rsp)
+	return
+}
+
+// DeleteDevice removes a device from a project, optionally purging its data
+func (c *Client) DeleteDevice(ctx context.Context, projectUID string, deviceUID string, purge bool) (err error) {
+	query := url.Values{}
+	if purge {
+		query.Set("purge", "true")
+	}
+	return c.do(ctx, http.MethodDelete, pathEscape("v1", "projects", projectUID, "devices", deviceUID), query, nil, nil, nil)
+}
+
+// EnableDevice allows a disabled device to connect to the notehub again
+func (c *Client) EnableDevice(ctx context.Context, projectUID string, deviceUID string) (err error) {
+	return c.do(ctx, http.MethodPost, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "enable"), nil, nil, nil, nil)
+}
+
+// DisableDevice prevents a device from connecting to the notehub
+func (c *Client) DisableDevice(ctx context.Context, projectUID string, deviceUID string) (err error) {
+	return c.do(ctx, http.MethodPost, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "disable"), nil, nil, nil, nil)
+}
+
+// ProvisionDevice provisions a device to a product within a project
+func (c *Client) ProvisionDevice(ctx context.Context, projectUID string, deviceUID string, req ProvisionDeviceRequest) (err error) {
+	return c.do(ctx, http.MethodPost, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "provision"), nil, nil, req, nil)
+}
+
+// GetDeviceLatest returns the latest event in each notefile of a device
+func (c *Client) GetDeviceLatest(ctx context.Context, projectUID string, deviceUID string) (rsp GetDeviceLatestResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "latest"), nil, nil, nil, &rsp)
+	return
+}
+
+// GetDeviceHealthLog returns the health log of a device
+func (c *Client) GetDeviceHealthLog(ctx context.Context, projectUID string, deviceUID string) (rsp GetDeviceHealthLogResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "health-log"), nil, nil, nil, &rsp)
+	return
+}
+
+// GetDevicesPublicKeys returns a page of the public keys of the devices in a project
+func (c *Client) GetDevicesPublicKeys(ctx context.Context, projectUID string, opts ListOptions) (rsp GetDevicesPublicKeysResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "devices", "public-keys"), opts.query(), nil, nil, &rsp)
+	return
+}
+
+// GetDeviceSessions returns a page of the sessions of a device, most recent first
+func (c *Client) GetDeviceSessions(ctx context.Context, projectUID string, deviceUID string, opts ListOptions) (rsp GetDeviceSessionsResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "sessions"), opts.query(), nil, nil, &rsp)
+	return
+}
+
+// GetDeviceFleets returns the fleets to which a device belongs
+func (c *Client) GetDeviceFleets(ctx context.Context, projectUID string, deviceUID string) (rsp GetFleetsResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "fleets"), nil, nil, nil, &rsp)
+	return
+}
+
+// AddDeviceToFleets adds a device to fleets, returning the fleets to which it now belongs
+func (c *Client) AddDeviceToFleets(ctx context.Context, projectUID string, deviceUID string, req PutDeviceFleetsRequest) (rsp GetFleetsResponse, err error) {
+	err = c.do(ctx, http.MethodPut, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "fleets"), nil, nil, req, &rsp)
+	return
+}
+
+// RemoveDeviceFromFleets removes a device from fleets, returning the fleets to which it still belongs
+func (c *Client) RemoveDeviceFromFleets(ctx context.Context, projectUID string, deviceUID string, req DeleteDeviceFleetsRequest) (rsp GetFleetsResponse, err error) {
+	err = c.do(ctx, http.MethodDelete, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "fleets"), nil, nil, req, &rsp)
+	return
+}
+
+// DeviceIterator iterates over the devices of a project, fetching pages as needed
+type DeviceIterator struct {
+	client     *Client
+	ctx        context.Context
+	projectUID string
+	pager      pager
+	page       []GetDeviceResponse
+	current    GetDeviceResponse
+}
+
+// IterateDevices returns an iterator over every device in a project
+func (c *Client) IterateDevices(ctx context.Context, projectUID string, pageSize int) *DeviceIterator {
+	return &DeviceIterator{client: c, ctx: ctx, projectUID: projectUID, pager: newPager(pageSize)}
+}
+
+// Next advances to the next device, returning false at the end or upon error
+func (it *DeviceIterator) Next() bool {
+	for len(it.page) == 0 {
+		opts, more := it.pager.next()
+		if !more {
+			return false
+		}
+		rsp, err := it.client.GetDevices(it.ctx, it.projectUID, opts)
+		if err != nil {
+			it.pager.err = err
+			return false
+		}
+		it.page = rsp.Devices
+		it.pager.done = !rsp.HasMore || len(rsp.Devices) == 0
+	}
+	it.current = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// Device returns the current device
+func (it *DeviceIterator) Device() GetDeviceResponse {
+	return it.current
+}
+
+// Err returns the error, if any, that ended the iteration
+func (it *DeviceIterator) Err() error {
+	return it.pager.err
+}
+
+// SessionIterator iterates over the sessions of a device, fetching pages as needed
+type SessionIterator struct {
+	client     *Client
+	ctx        context.Context
+	projectUID string
+	deviceUID  string
+	pager      pager
+	page       []note.DeviceSession
+	current    note.DeviceSession
+}
+
+// IterateDeviceSessions returns an iterator over every session of a device, most recent first
+func (c *Client) IterateDeviceSessions(ctx context.Context, projectUID string, deviceUID string, pageSize int) *SessionIterator {
+	return &SessionIterator{client: c, ctx: ctx, projectUID: projectUID, deviceUID: deviceUID, pager: newPager(pageSize)}
+}
+
+// Next advances to the next session, returning false at the end or upon error
+func (it *SessionIterator) Next() bool {
+	for len(it.page) == 0 {
+		opts, more := it.pager.next()
+		if !more {
+			return false
+		}
+		rsp, err := it.client.GetDeviceSessions(it.ctx, it.projectUID, it.deviceUID, opts)
+		if err != nil {
+			it.pager.err = err
+			return false
+		}
+		it.page = rsp.Sessions
+		it.pager.done = !rsp.HasMore || len(rsp.Sessions) == 0
+	}
+	it.current = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// Session returns the current session
+func (it *SessionIterator) Session() note.DeviceSession {
+	return it.current
+}
+
+// Err returns the error, if any, that ended the iteration
+func (it *SessionIterator) Err() error {
+	return it.pager.err
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+// PINHeader is the header carrying the PIN that authorizes access to a device's
+// environment variables without a token
+const PINHeader = "X-Auth-Token"
+
+// GetAppEnvironmentVariables returns the environment variables of a project
+func (c *Client) GetAppEnvironmentVariables(ctx context.Context, projectUID string) (rsp GetAppEnvironmentVariablesResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "environment_variables"), nil, nil, nil, &rsp)
+	return
+}
+
+// PutAppEnvironmentVariables sets environment variables of a project, leaving others unchanged
+func (c *Client) PutAppEnvironmentVariables(ctx context.Context, projectUID string, req PutAppEnvironmentVariablesRequest) (rsp PutAppEnvironmentVariablesResponse, err error) {
+	err = c.do(ctx, http.MethodPut, pathEscape("v1", "projects", projectUID, "environment_variables"), nil, nil, req, &rsp)
+	return
+}
+
+// DeleteAppEnvironmentVariable removes an environment variable from a project
+func (c *Client) DeleteAppEnvironmentVariable(ctx context.Context, projectUID string, key string) (rsp DeleteAppEnvironmentVariableResponse, err error) {
+	err = c.do(ctx, http.MethodDelete, pathEscape("v1", "projects", projectUID, "environment_variables", key), nil, nil, nil, &rsp)
+	return
+}
+
+// GetFleetEnvironmentVariables returns the environment variables of a fleet
+func (c *Client) GetFleetEnvironmentVariables(ctx context.Context, projectUID string, fleetUID string) (rsp GetFleetEnvironmentVariablesResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "fleets", fleetUID, "environment_variables"), nil, nil, nil, &rsp)
+	return
+}
+
+// PutFleetEnvironmentVariables sets environment variables of a fleet, leaving others unchanged
+func (c *Client) PutFleetEnvironmentVariables(ctx context.Context, projectUID string, fleetUID string, req PutFleetEnvironmentVariablesRequest) (rsp PutFleetEnvironmentVariablesResponse, err error) {
+	err = c.do(ctx, http.MethodPut, pathEscape("v1", "projects", projectUID, "fleets", fleetUID, "environment_variables"), nil, nil, req, &rsp)
+	return
+}
+
+// DeleteFleetEnvironmentVariable removes an environment variable from a fleet
+func (c *Client) DeleteFleetEnvironmentVariable(ctx context.Context, projectUID string, fleetUID string, key string) (rsp DeleteFleetEnvironmentVariableResponse, err error) {
+	err = c.do(ctx, http.MethodDelete, pathEscape("v1", "projects", projectUID, "fleets", fleetUID, "environment_variables", key), nil, nil, nil, &rsp)
+	return
+}
+
+// GetDeviceEnvironmentVariables returns the environment variables of a device, along
+// with the defaults set by the device itself and the effective values
+func (c *Client) GetDeviceEnvironmentVariables(ctx context.Context, projectUID string, deviceUID string) (rsp GetDeviceEnvironmentVariablesResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "environment_variables"), nil, nil, nil, &rsp)
+	return
+}
+
+// PutDeviceEnvironmentVariables sets environment variables of a device, leaving others unchanged
+func (c *Client) PutDeviceEnvironmentVariables(ctx context.Context, projectUID string, deviceUID string, req PutDeviceEnvironmentVariablesRequest) (rsp PutDeviceEnvironmentVariablesResponse, err error) {
+	err = c.do(ctx, http.MethodPut, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "environment_variables"), nil, nil, req, &rsp)
+	return
+}
+
+// DeleteDeviceEnvironmentVariable removes an environment variable from a device
+func (c *Client) DeleteDeviceEnvironmentVariable(ctx context.Context, projectUID string, deviceUID string, key string) (rsp DeleteDeviceEnvironmentVariableResponse, err error) {
+	err = c.do(ctx, http.MethodDelete, pathEscape("v1", "projects", projectUID, "devices", deviceUID, "environment_variables", key), nil, nil, nil, &rsp)
+	return
+}
+
+// pinHeader returns the header authorizing access with a PIN
+func pinHeader(pin string) http.Header {
+	header := http.Header{}
+	header.Set(PINHeader, pin)
+	return header
+}
+
+// GetDeviceEnvironmentVariablesWithPIN returns the environment variables of a device
+// within a product, authorized by the device's PIN rather than by a token
+func (c *Client) GetDeviceEnvironmentVariablesWithPIN(ctx context.Context, productUID string, deviceUID string, pin string) (rsp GetDeviceEnvironmentVariablesWithPINResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "products", productUID, "devices", deviceUID, "environment_variables_with_pin"), nil, pinHeader(pin), nil, &rsp)
+	return
+}
+
+// PutDeviceEnvironmentVariablesWithPIN sets environment variables of a device within
+// a product, authorized by the device's PIN rather than by a token
+func (c *Client) PutDeviceEnvironmentVariablesWithPIN(ctx context.Context, productUID string, deviceUID string, pin string, req PutDeviceEnvironmentVariablesWithPINRequest) (rsp PutDeviceEnvironmentVariablesWithPINResponse, err error) {
+	err = c.do(ctx, http.MethodPut, pathEscape("v1", "products", productUID, "devices", deviceUID, "environment_variables_with_pin"), nil, pinHeader(pin), req, &rsp)
+	return
+}
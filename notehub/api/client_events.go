@@ -0,0 +1,145 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blues/note-go/note"
+)
+
+// EventsQuery filters the events of a project.  Zero values are omitted.
+type EventsQuery struct {
+	DeviceUIDs   []string
+	FleetUIDs    []string
+	Files        []string
+	SelectFields []string
+	// Epoch seconds bounding the time at which events were received
+	StartDate int64
+	EndDate   int64
+	// "asc" or "desc"
+	SortOrder string
+	SortBy    string
+	// Used by GetEvents
+	PageSize int
+	PageNum  int
+	// Used by GetEventsByCursor
+	Limit  int
+	Cursor string
+}
+
+// query converts the query to query parameters
+func (q EventsQuery) query() url.Values {
+	query := url.Values{}
+	for _, deviceUID := range q.DeviceUIDs {
+		query.Add("deviceUID", deviceUID)
+	}
+	for _, fleetUID := range q.FleetUIDs {
+		query.Add("fleetUID", fleetUID)
+	}
+	if len(q.Files) > 0 {
+		query.Set("files", strings.Join(q.Files, ","))
+	}
+	if len(q.SelectFields) > 0 {
+		query.Set("select-fields", strings.Join(q.SelectFields, ","))
+	}
+	if q.StartDate != 0 {
+		query.Set("startDate", fmt.Sprintf("%d", q.StartDate))
+	}
+	if q.EndDate != 0 {
+		query.Set("endDate", fmt.Sprintf("%d", q.EndDate))
+	}
+	if q.SortOrder != "" {
+		query.Set("sortOrder", q.SortOrder)
+	}
+	if q.SortBy != "" {
+		query.Set("sortBy", q.SortBy)
+	}
+	if q.PageSize > 0 {
+		query.Set("pageSize", fmt.Sprintf("%d", q.PageSize))
+	}
+	if q.PageNum > 0 {
+		query.Set("pageNum", fmt.Sprintf("%d", q.PageNum))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", fmt.Sprintf("%d", q.Limit))
+	}
+	if q.Cursor != "" {
+		query.Set("cursor", q.Cursor)
+	}
+	return query
+}
+
+// GetEvents returns a page of the events of a project
+func (c *Client) GetEvents(ctx context.Context, projectUID string, q EventsQuery) (rsp GetEventsResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "events"), q.query(), nil, nil, &rsp)
+	return
+}
+
+// GetEventsByCursor returns the events of a project following a cursor
+func (c *Client) GetEventsByCursor(ctx context.Context, projectUID string, q EventsQuery) (rsp GetEventsByCursorResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "events-cursor"), q.query(), nil, nil, &rsp)
+	return
+}
+
+// EventIterator iterates over the events of a project by cursor, fetching pages as needed
+type EventIterator struct {
+	client     *Client
+	ctx        context.Context
+	projectUID string
+	query      EventsQuery
+	pager      pager
+	page       []note.Event
+	current    note.Event
+}
+
+// IterateEvents returns an iterator over every event of a project matching a query,
+// starting at the query's cursor if any
+func (c *Client) IterateEvents(ctx context.Context, projectUID string, q EventsQuery) *EventIterator {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	return &EventIterator{client: c, ctx: ctx, projectUID: projectUID, query: q}
+}
+
+// Next advances to the next event, returning false at the end or upon error
+func (it *EventIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.pager.done || it.pager.err != nil {
+			return false
+		}
+		rsp, err := it.client.GetEventsByCursor(it.ctx, it.projectUID, it.query)
+		if err != nil {
+			it.pager.err = err
+			return false
+		}
+		it.page = rsp.Events
+		it.query.Cursor = rsp.NextCursor
+		it.pager.done = !rsp.HasMore || rsp.NextCursor == "" || len(rsp.Events) == 0
+	}
+	it.current = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// Event returns the current event
+func (it *EventIterator) Event() note.Event {
+	return it.current
+}
+
+// Cursor returns the cursor from which iteration may later be resumed after the
+// events already fetched
+func (it *EventIterator) Cursor() string {
+	return it.query.Cursor
+}
+
+// Err returns the error, if any, that ended the iteration
+func (it *EventIterator) Err() error {
+	return it.pager.err
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+// GetProjectsResponse v1
+//
+// The response object for getting the projects accessible to the caller.
+type GetProjectsResponse struct {
+	Projects []GetAppResponse `json:"projects"`
+}
+
+// GetProjects returns the projects accessible to the caller
+func (c *Client) GetProjects(ctx context.Context) (rsp GetProjectsResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects"), nil, nil, nil, &rsp)
+	return
+}
+
+// GetProject returns a project
+func (c *Client) GetProject(ctx context.Context, projectUID string) (rsp GetAppResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID), nil, nil, nil, &rsp)
+	return
+}
+
+// GetProducts returns the products of a project
+func (c *Client) GetProducts(ctx context.Context, projectUID string) (rsp GetProductsResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "products"), nil, nil, nil, &rsp)
+	return
+}
+
+// CreateProduct adds a product to a project
+func (c *Client) CreateProduct(ctx context.Context, projectUID string, req PostProductRequest) (rsp ProductResponse, err error) {
+	err = c.do(ctx, http.MethodPost, pathEscape("v1", "projects", projectUID, "products"), nil, nil, req, &rsp)
+	return
+}
+
+// GetFleets returns the fleets of a project
+func (c *Client) GetFleets(ctx context.Context, projectUID string) (rsp GetFleetsResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "fleets"), nil, nil, nil, &rsp)
+	return
+}
+
+// GetFleet returns a fleet
+func (c *Client) GetFleet(ctx context.Context, projectUID string, fleetUID string) (rsp FleetResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "projects", projectUID, "fleets", fleetUID), nil, nil, nil, &rsp)
+	return
+}
+
+// CreateFleet adds a fleet to a project
+func (c *Client) CreateFleet(ctx context.Context, projectUID string, req PostFleetRequest) (rsp FleetResponse, err error) {
+	err = c.do(ctx, http.MethodPost, pathEscape("v1", "projects", projectUID, "fleets"), nil, nil, req, &rsp)
+	return
+}
+
+// UpdateFleet changes a fleet, and adds and removes devices
+func (c *Client) UpdateFleet(ctx context.Context, projectUID string, fleetUID string, req PutFleetRequest) (rsp FleetResponse, err error) {
+	err = c.do(ctx, http.MethodPut, pathEscape("v1", "projects", projectUID, "fleets", fleetUID), nil, nil, req, &rsp)
+	return
+}
+
+// DeleteFleet removes a fleet from a project
+func (c *Client) DeleteFleet(ctx context.Context, projectUID string, fleetUID string) (err error) {
+	return c.do(ctx, http.MethodDelete, pathEscape("v1", "projects", projectUID, "fleets", fleetUID), nil, nil, nil, nil)
+}
+
+// GetBillingAccounts returns the billing accounts accessible to the caller
+func (c *Client) GetBillingAccounts(ctx context.Context) (rsp GetBillingAccountsResponse, err error) {
+	err = c.do(ctx, http.MethodGet, pathEscape("v1", "billing-accounts"), nil, nil, nil, &rsp)
+	return
+}
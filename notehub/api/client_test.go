@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientDevices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/projects/app:1/devices":
+			require.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+			pageNum, _ := strconv.Atoi(r.URL.Query().Get("pageNum"))
+			rsp := GetDevicesResponse{HasMore: pageNum < 3}
+			rsp.Devices = append(rsp.Devices, GetDeviceResponse{UID: fmt.Sprintf("dev:%d", pageNum)})
+			json.NewEncoder(w).Encode(rsp)
+		case "/v1/projects/app:1/devices/dev:404":
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrNotFound().WithError(errors.New("device not found")))
+		case "/v1/products/prod:1/devices/dev:1/environment_variables_with_pin":
+			require.Equal(t, "1234", r.Header.Get(PINHeader))
+			require.Empty(t, r.Header.Get("Authorization"))
+			var req PutDeviceEnvironmentVariablesWithPINRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			json.NewEncoder(w).Encode(PutDeviceEnvironmentVariablesWithPINResponse(req))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("upstream unavailable"))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	c := NewClient(server.URL, "tok")
+
+	var uids []string
+	it := c.IterateDevices(ctx, "app:1", 1)
+	for it.Next() {
+		uids = append(uids, it.Device().UID)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []string{"dev:1", "dev:2", "dev:3"}, uids)
+
+	_, err := c.GetDevice(ctx, "app:1", "dev:404")
+	require.True(t, IsNotFound(err))
+	require.True(t, errors.Is(err, &ResponseError{Response: ErrNotFound()}))
+	rsp, ok := AsErrorResponse(err)
+	require.True(t, ok)
+	require.Equal(t, "device not found", rsp.Error)
+
+	_, err = c.GetFleets(ctx, "app:1")
+	require.Equal(t, http.StatusBadGateway, StatusCode(err))
+	require.Contains(t, err.Error(), "upstream unavailable")
+
+	pinClient := NewClient(server.URL, "")
+	vars, err := pinClient.PutDeviceEnvironmentVariablesWithPIN(ctx, "prod:1", "dev:1", "1234",
+		PutDeviceEnvironmentVariablesWithPINRequest{EnvironmentVariables: map[string]string{"a": "1"}})
+	require.NoError(t, err)
+	require.Equal(t, "1", vars.EnvironmentVariables["a"])
+}
+
+func TestClientEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/projects/app:1/events-cursor", r.URL.Path)
+		require.Equal(t, "data.qo", r.URL.Query().Get("files"))
+		rsp := GetEventsByCursorResponse{}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			rsp.Events = []note.Event{{EventUID: "e1"}, {EventUID: "e2"}}
+			rsp.NextCursor = "c1"
+			rsp.HasMore = true
+		case "c1":
+			rsp.Events = []note.Event{{EventUID: "e3"}}
+			rsp.NextCursor = "c2"
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "tok")
+	it := c.IterateEvents(context.Background(), "app:1", EventsQuery{Files: []string{"data.qo"}})
+	var uids []string
+	for it.Next() {
+		uids = append(uids, it.Event().EventUID)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []string{"e1", "e2", "e3"}, uids)
+	require.Equal(t, "c2", it.Cursor())
+}
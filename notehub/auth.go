@@ -2,14 +2,13 @@ package notehub
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -26,6 +25,8 @@ type AccessToken struct {
 	Email       string
 	AccessToken string
 	ExpiresAt   time.Time
+	// RefreshToken, if present, may be exchanged for a new access token once this one expires
+	RefreshToken string `json:",omitempty"`
 }
 
 // open opens the specified URL in the default browser of the user.
@@ -51,19 +52,20 @@ func listenOnAny(ports []int) (net.Listener, int, error) {
 	for _, p := range ports {
 		ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", p))
 		if err == nil {
-			return ln, p, nil
+			return ln, ln.Addr().(*net.TCPAddr).Port, nil
 		}
 	}
 	return nil, 0, errors.New("no ports available")
 }
 
-func randString(n int) string {
-	letterRunes := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+// randomString returns a URL-safe string encoding n cryptographically random bytes
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("generating random string: %w", err)
 	}
-	return string(b)
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
 func RevokeAccessToken(hub, token string) error {
@@ -100,241 +102,222 @@ func RevokeAccessToken(hub, token string) error {
 	return nil
 }
 
+// LoginOptions configures the browser-based login flow.  The zero value of each field
+// selects the behavior of InitiateBrowserBasedLogin.
+type LoginOptions struct {
+	// ClientID is the OAuth client, which must be configured for the authorization code
+	// flow with redirect URIs for each of the callback ports
+	ClientID string
+	// Ports are tried in order until one can be bound for the callback.  A port of 0
+	// binds any available port.
+	Ports []int
+	// Scheme is the scheme of the Notehub's API and UI hosts
+	Scheme string
+	// UIHost is the host serving the OAuth endpoints, derived from the API host if empty
+	UIHost string
+	// Open opens the authorization URL in the user's browser
+	Open func(url string) error
+	// HTTPClient is used to exchange the authorization code and fetch user info
+	HTTPClient *http.Client
+	// Context cancels the login while waiting for the user to authorize
+	Context context.Context
+	// Output receives progress messages for the user
+	Output io.Writer
+	// OfflineAccess requests a refresh token along with the access token, so that the
+	// token can be refreshed with RefreshAccessToken rather than by signing in again
+	OfflineAccess bool
+}
+
+// defaultLoginClientID is the hard-coded OAuth client ID that's persisted in Hydra
+const defaultLoginClientID = oauthClientID
+
+// defaultLoginPorts are randomly chosen and hard-coded into the OAuth client in Hydra
+// within Notehub (in the redirect_uris field)
+var defaultLoginPorts = []int{58766, 58767, 58768, 58769, 42100, 42101, 42102, 42103}
+
+// withDefaults returns the options with empty fields replaced by their defaults
+func (opts LoginOptions) withDefaults() LoginOptions {
+	if opts.ClientID == "" {
+		opts.ClientID = defaultLoginClientID
+	}
+	if len(opts.Ports) == 0 {
+		opts.Ports = defaultLoginPorts
+	}
+	if opts.Scheme == "" {
+		opts.Scheme = "https"
+	}
+	if opts.Open == nil {
+		opts.Open = open
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+	return opts
+}
+
 // InitiateBrowserBasedLogin starts the OAuth2 login flow by opening the user's browser.
 // the `hub` parameter is the hostname of Notehub where it is assumed that an OAuth2 client
-// with client ID `notehub_cli` is configured for authorization code flow.
+// with client ID `notehub_cli` is configured for authorization code flow.  The login is
+// cancelled if the process is interrupted.
 func InitiateBrowserBasedLogin(notehubApiHost string) (*AccessToken, error) {
-	// this is the hard-coded OAuth client ID that's persisted in Hydra
-	clientId := "notehub_cli"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return BrowserBasedLogin(notehubApiHost, LoginOptions{Context: ctx})
+}
 
-	if !strings.HasPrefix(notehubApiHost, "api.") {
-		notehubApiHost = "api." + notehubApiHost
+// BrowserBasedLogin runs the OAuth2 authorization code flow with PKCE, opening the
+// authorization page in the user's browser and waiting for the Notehub to redirect it
+// to a local callback server with the authorization code.
+func BrowserBasedLogin(notehubApiHost string, opts LoginOptions) (*AccessToken, error) {
+	opts = opts.withDefaults()
+	notehubApiHost, notehubUiHost := notehubHosts(notehubApiHost)
+	if opts.UIHost != "" {
+		notehubUiHost = opts.UIHost
 	}
+	apiBaseURL := opts.Scheme + "://" + notehubApiHost
+	uiBaseURL := opts.Scheme + "://" + notehubUiHost
 
-	var notehubUiHost string
-	if notehubApiHost == "api.notefile.net" {
-		notehubUiHost = "notehub.io"
-	} else {
-		notehubUiHost = strings.TrimPrefix(notehubApiHost, "api.")
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 7636 requires 43 to 128 characters, which 32 bytes encode as 43
+	codeVerifier, err := randomString(32)
+	if err != nil {
+		return nil, err
 	}
-
-	// Try these ports in order until one is available:
-	//
-	// these ports are randomly chosen and hard-coded into
-	// the OAuth client in Hydra within Notehub (in the redirect_uris field)
-	ports := []int{58766, 58767, 58768, 58769, 42100, 42101, 42102, 42103}
-
-	// Return values
-	var accessToken *AccessToken
-	var accessTokenErr error
-
-	state := randString(16)
-	codeVerifier := randString(50) // must be at least 43 characters
 	hash := sha256.Sum256([]byte(codeVerifier))
 	codeChallenge := base64.RawURLEncoding.EncodeToString(hash[:])
 
-	done := make(chan bool, 1)
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	defer signal.Reset(os.Interrupt)
-
-	router := http.NewServeMux()
+	// Pick first available port and get a listener
+	listener, port, err := listenOnAny(opts.Ports)
+	if err != nil {
+		return nil, fmt.Errorf("could not bind any callback port: %w", err)
+	}
+	redirectURI := fmt.Sprintf("http://localhost:%d", port)
 
-	// We'll fill this after we pick a port but declare it now so the handler can close over it.
-	chosenPort := 0
+	type result struct {
+		token *AccessToken
+		err   error
+	}
+	results := make(chan result, 1)
+	finish := func(token *AccessToken, err error) {
+		select {
+		case results <- result{token, err}:
+		default:
+		}
+	}
 
 	// The browser will be redirected to this endpoint with an authorization code
 	// and then this endpoint will exchange that authorization code for an access token
+	router := http.NewServeMux()
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		authorizationCode := r.URL.Query().Get("code")
-		callbackState := r.URL.Query().Get("state")
-
 		errHandler := func(msg string) {
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(w, "error: %s", msg)
-			fmt.Printf("error: %s\n", msg)
-			accessTokenErr = errors.New(msg)
-		}
-
-		if callbackState != state {
-			errHandler("state mismatch")
-			return
-		}
-
-		///////////////////////////////////////////
-		// Exchange code for access token
-		///////////////////////////////////////////
-
-		tokenResp, err := http.Post(
-			(&url.URL{
-				Scheme: "https",
-				Host:   notehubUiHost,
-				Path:   "/oauth2/token",
-			}).String(),
-			"application/x-www-form-urlencoded",
-			strings.NewReader(url.Values{
-				"client_id":     {clientId},
-				"code":          {authorizationCode},
-				"code_verifier": {codeVerifier},
-				"grant_type":    {"authorization_code"},
-				"redirect_uri":  {fmt.Sprintf("http://localhost:%d", chosenPort)},
-			}.Encode()),
-		)
-		if err != nil {
-			errHandler("error on /oauth2/token: " + err.Error())
-			return
-		}
-		defer tokenResp.Body.Close()
-
-		body, err := io.ReadAll(tokenResp.Body)
-		if err != nil {
-			errHandler("could not read body from /oauth2/token: " + err.Error())
-			return
-		}
-
-		var tokenData map[string]interface{}
-		if err := json.Unmarshal(body, &tokenData); err != nil {
-			errHandler("could not unmarshal body from /oauth2/token: " + err.Error())
-			return
+			fmt.Fprintf(opts.Output, "error: %s\n", msg)
+			finish(nil, errors.New(msg))
 		}
 
-		if errCode, ok := tokenData["error"].(string); ok {
-			if errDescription, ok2 := tokenData["error_description"].(string); ok2 {
-				errHandler(fmt.Sprintf("%s: %s", errCode, errDescription))
-			} else {
-				errHandler(errCode)
-			}
+		// A request without the state isn't the redirect from the authorization, and may
+		// be from anything that can reach the port, so it mustn't end the login
+		if r.URL.Query().Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
 			return
 		}
-
-		accessTokenString, ok := tokenData["access_token"].(string)
-		if !ok {
-			errHandler("unexpected error: no access token returned")
+		if errCode := r.URL.Query().Get("error"); errCode != "" {
+			errHandler(fmt.Sprintf("%s: %s", errCode, r.URL.Query().Get("error_description")))
 			return
 		}
 
-		// be defensive about type
-		var expiresIn time.Duration
-		switch v := tokenData["expires_in"].(type) {
-		case float64:
-			expiresIn = time.Duration(v) * time.Second
-		case int:
-			expiresIn = time.Duration(v) * time.Second
-		default:
-			expiresIn = 0
+		tokenRsp, err := postForm(r.Context(), opts.HTTPClient, uiBaseURL+"/oauth2/token", url.Values{
+			"client_id":     {opts.ClientID},
+			"code":          {r.URL.Query().Get("code")},
+			"code_verifier": {codeVerifier},
+			"grant_type":    {"authorization_code"},
+			"redirect_uri":  {redirectURI},
+		}, "", "")
+		if err == nil {
+			err = tokenRsp.err()
 		}
-
-		///////////////////////////////////////////
-		// Get user's information (specifically email)
-		///////////////////////////////////////////
-
-		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/userinfo", notehubApiHost), nil)
 		if err != nil {
-			errHandler("could not create request for /userinfo: " + err.Error())
+			errHandler("error on /oauth2/token: " + err.Error())
 			return
 		}
-		req.Header.Set("Authorization", "Bearer "+accessTokenString)
-		userinfoResp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			errHandler("could not get userinfo: " + err.Error())
+		if tokenRsp.AccessToken == "" {
+			errHandler("unexpected error: no access token returned")
 			return
 		}
-		defer userinfoResp.Body.Close()
 
-		userinfoBody, err := io.ReadAll(userinfoResp.Body)
+		email, err := fetchEmail(r.Context(), opts.HTTPClient, apiBaseURL, tokenRsp.AccessToken)
 		if err != nil {
-			errHandler("could not read body from /userinfo: " + err.Error())
-			return
-		}
-
-		var userinfoData map[string]interface{}
-		if err := json.Unmarshal(userinfoBody, &userinfoData); err != nil {
-			errHandler("could not unmarshal body from /userinfo: " + err.Error())
-			return
-		}
-
-		email, ok := userinfoData["email"].(string)
-		if !ok {
-			errHandler("could not retrieve email")
+			errHandler(err.Error())
 			return
 		}
 
-		///////////////////////////////////////////
-		// Build the access token response
-		///////////////////////////////////////////
-
-		accessToken = &AccessToken{
-			Host:        notehubApiHost,
-			Email:       email,
-			AccessToken: accessTokenString,
-			ExpiresAt:   time.Now().Add(expiresIn),
-		}
-
-		///////////////////////////////////////////
-		// respond to the browser and quit
-		///////////////////////////////////////////
-
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "<p>Token exchange completed successfully</p><p>You may now close this window and return to the CLI application</p>")
-
-		quit <- os.Interrupt
+		finish(newAccessToken(notehubApiHost, email, tokenRsp), nil)
 	})
 
-	// Pick first available port and get a listener
-	listener, port, err := listenOnAny(ports)
-	if err != nil {
-		return nil, fmt.Errorf("could not bind any callback port: %w", err)
-	}
-	chosenPort = port
-
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", chosenPort),
-		Handler: router,
-	}
-
-	// Wait for OAuth callback to be hit, then shutdown HTTP server
+	server := &http.Server{Handler: router}
 	go func() {
-		<-quit
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("error: %v", err)
+		}
+	}()
+	defer func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 		server.SetKeepAlivesEnabled(false)
 		if err := server.Shutdown(ctx); err != nil {
 			log.Printf("error: %v", err)
 		}
-		close(done)
-	}()
-
-	// Start HTTP server waiting for OAuth callback
-	go func() {
-		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
-			log.Printf("error: %v", err)
-		}
 	}()
 
-	// Build the authorize URL using the chosen port
-	authorizeUrl := url.URL{
-		Scheme: "https",
-		Host:   notehubUiHost,
-		Path:   "/oauth2/auth",
-		RawQuery: url.Values{
-			"client_id":             {clientId},
-			"code_challenge":        {codeChallenge},
-			"code_challenge_method": {"S256"},
-			"redirect_uri":          {fmt.Sprintf("http://localhost:%d", chosenPort)},
-			"response_type":         {"code"},
-			"scope":                 {"openid email"},
-			"state":                 {state},
-		}.Encode(),
+	scope := "openid email"
+	if opts.OfflineAccess {
+		scope += " offline_access"
 	}
+	authorizeUrl := uiBaseURL + "/oauth2/auth?" + url.Values{
+		"client_id":             {opts.ClientID},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {scope},
+		"state":                 {state},
+	}.Encode()
 
 	// Open web browser to authorize
-	fmt.Printf("Opening web browser to initiate authentication (redirect port %d)...\n", chosenPort)
-	if err := open(authorizeUrl.String()); err != nil {
-		fmt.Printf("error opening web browser: %v", err)
+	fmt.Fprintf(opts.Output, "Opening web browser to initiate authentication (redirect port %d)...\n", port)
+	if err := opts.Open(authorizeUrl); err != nil {
+		fmt.Fprintf(opts.Output, "error opening web browser: %v\n", err)
+		fmt.Fprintf(opts.Output, "Open this URL to continue: %s\n", authorizeUrl)
 	}
 
 	// Wait for exchange to finish
-	<-done
-	return accessToken, accessTokenErr
+	select {
+	case r := <-results:
+		return r.token, r.err
+	case <-opts.Context.Done():
+		return nil, opts.Context.Err()
+	}
 }
@@ -0,0 +1,100 @@
+package notehub
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrowserBasedLogin(t *testing.T) {
+	var challenge, scope string
+	stray := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			require.Equal(t, "test_client", r.FormValue("client_id"))
+			require.Equal(t, "authorization_code", r.FormValue("grant_type"))
+			require.Equal(t, "the-code", r.FormValue("code"))
+			hash := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+			require.Equal(t, challenge, base64.RawURLEncoding.EncodeToString(hash[:]))
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "at", "refresh_token": "rt", "expires_in": 3600})
+		case "/userinfo":
+			require.Equal(t, "Bearer at", r.Header.Get("Authorization"))
+			json.NewEncoder(w).Encode(map[string]string{"email": "a@example.com"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// The fake browser approves the request by following the redirect with a code
+	browse := func(authorizeURL string) error {
+		u, err := url.Parse(authorizeURL)
+		require.NoError(t, err)
+		require.Equal(t, "/oauth2/auth", u.Path)
+		q := u.Query()
+		require.Equal(t, "S256", q.Get("code_challenge_method"))
+		require.GreaterOrEqual(t, len(q.Get("state")), 16)
+		challenge = q.Get("code_challenge")
+		scope = q.Get("scope")
+		go func() {
+			// A stray request, such as for a favicon, is rejected without ending the login
+			rsp, err := http.Get(q.Get("redirect_uri") + "/favicon.ico")
+			if err == nil {
+				stray = rsp.StatusCode
+				rsp.Body.Close()
+			}
+			rsp, err = http.Get(q.Get("redirect_uri") + "/?" + url.Values{"code": {"the-code"}, "state": {q.Get("state")}}.Encode())
+			if err == nil {
+				rsp.Body.Close()
+			}
+		}()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	token, err := BrowserBasedLogin("api.example.com", LoginOptions{
+		ClientID: "test_client",
+		Ports:    []int{0},
+		Scheme:   "http",
+		UIHost:   host,
+		Open:     browse,
+		Context:  ctx,
+		Output:   ioutil.Discard,
+		// Only a login that asks for offline access is issued a refresh token
+		OfflineAccess: true,
+		HTTPClient: &http.Client{Transport: &http.Transport{
+			// Route the API host to the fake server too
+			Proxy: func(r *http.Request) (*url.URL, error) { return url.Parse(server.URL) },
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "api.example.com", token.Host)
+	require.Equal(t, "a@example.com", token.Email)
+	require.Equal(t, "at", token.AccessToken)
+	require.Equal(t, "rt", token.RefreshToken)
+	require.Equal(t, "openid email offline_access", scope)
+	require.Equal(t, http.StatusBadRequest, stray)
+
+	// A login nobody completes ends when its context is cancelled
+	ctx, cancel = context.WithCancel(context.Background())
+	_, err = BrowserBasedLogin("api.example.com", LoginOptions{
+		Ports:   []int{0},
+		Open:    func(string) error { cancel(); return nil },
+		Context: ctx,
+		Output:  ioutil.Discard,
+	})
+	require.Equal(t, context.Canceled, err)
+}
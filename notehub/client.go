@@ -0,0 +1,187 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package notehub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notecard"
+)
+
+// RequestPath is the path of the endpoint to which HubRequests are posted
+const RequestPath = "/req"
+
+// SessionTokenHeader is the header carrying a legacy session token
+const SessionTokenHeader = "X-Session-Token"
+
+// Client sends HubRequests to a Notehub.  Its fields may be changed after it is
+// created but not while requests are in progress.
+type Client struct {
+	// BaseURL is the scheme and host of the Notehub API, such as https://api.notefile.net
+	BaseURL string
+
+	// Token is an OAuth access token or personal access token, sent as a bearer token
+	Token string
+
+	// SessionToken is a legacy session token, sent only if Token is empty
+	SessionToken string
+
+	// Compress is the mode in which request payloads are compressed, if not specified
+	// by the request itself.  Payloads are decompressed before being returned whatever
+	// the mode.
+	Compress string
+
+	// HTTPClient performs the requests.  If nil, a client with a 60s timeout is used.
+	HTTPClient *http.Client
+
+	// UserAgent is sent with every request, if not empty
+	UserAgent string
+}
+
+// NewClient returns a client of the Notehub at the specified host or base URL, or of
+// the default Notehub if empty, authenticated with a bearer token
+func NewClient(host string, token string) *Client {
+	if host == "" {
+		host = DefaultAPIService
+	}
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(host, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// NewClientWithToken returns a client of the Notehub that issued an access token
+func NewClientWithToken(token *AccessToken) *Client {
+	return NewClient(token.Host, token.AccessToken)
+}
+
+// Request sends a request and returns the response
+func (c *Client) Request(req HubRequest) (rsp HubRequest, err error) {
+	return c.RequestContext(context.Background(), req)
+}
+
+// RequestContext sends a request and returns the response.  An error is returned if
+// the request couldn't be performed or if the response has an Err, which is returned as
+// a *note.Error so that its keywords may be tested with errors.Is or note.ErrorContains.
+func (c *Client) RequestContext(ctx context.Context, req HubRequest) (rsp HubRequest, err error) {
+	if req.Compress == "" && req.Payload != nil && len(*req.Payload) > 0 {
+		req.Compress = c.Compress
+	}
+	if req.Payload != nil && req.Compress != "" {
+		var payload []byte
+		payload, err = compressPayload(req.Compress, *req.Payload)
+		if err != nil {
+			return
+		}
+		req.Payload = &payload
+	}
+
+	body, err := note.JSONMarshal(req)
+	if err != nil {
+		return
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+RequestPath, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.SessionToken != "" {
+		httpReq.Header.Set(SessionTokenHeader, c.SessionToken)
+	}
+	if c.UserAgent != "" {
+		httpReq.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	httpRsp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return rsp, fmt.Errorf("%s: %w %s", req.Req, err, note.ErrNetwork)
+	}
+	defer httpRsp.Body.Close()
+	rspBody, err := io.ReadAll(httpRsp.Body)
+	if err != nil {
+		return rsp, fmt.Errorf("%s: %w %s", req.Req, err, note.ErrNetwork)
+	}
+
+	err = note.JSONUnmarshal(rspBody, &rsp)
+	if err != nil {
+		msg := strings.TrimSpace(string(rspBody))
+		if msg == "" {
+			msg = http.StatusText(httpRsp.StatusCode)
+		}
+		return rsp, note.NewError(req.Req, msg+statusKeyword(httpRsp.StatusCode))
+	}
+	if rsp.Err == "" && (httpRsp.StatusCode < 200 || httpRsp.StatusCode > 299) {
+		rsp.Err = http.StatusText(httpRsp.StatusCode) + statusKeyword(httpRsp.StatusCode)
+	}
+	if rsp.Err != "" {
+		return rsp, note.NewError(req.Req, rsp.Err)
+	}
+
+	if rsp.Payload != nil && rsp.Compress != "" {
+		var payload []byte
+		payload, err = decompressPayload(rsp.Compress, *rsp.Payload)
+		if err != nil {
+			return
+		}
+		rsp.Payload = &payload
+		rsp.Compress = ""
+	}
+	return
+}
+
+// statusKeyword returns the note error keyword, preceded by a space, that corresponds
+// to an HTTP status, if any
+func statusKeyword(status int) string {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return " " + note.ErrAuth
+	case http.StatusRequestEntityTooLarge:
+		return " " + note.ErrTooBig
+	case http.StatusGatewayTimeout:
+		return " " + note.ErrTimeout
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return " " + note.ErrNetwork
+	}
+	return ""
+}
+
+// compressPayload compresses a payload in the specified mode
+func compressPayload(mode string, payload []byte) ([]byte, error) {
+	switch mode {
+	case HubCompressModeSnappy:
+		return snappyEncode(payload), nil
+	case HubCompressModeCobs:
+		return notecard.CobsEncode(payload, 0)
+	}
+	return nil, fmt.Errorf("unrecognized compression mode: %s %s", mode, note.ErrSyntax)
+}
+
+// decompressPayload reverses compressPayload
+func decompressPayload(mode string, payload []byte) ([]byte, error) {
+	switch mode {
+	case HubCompressModeSnappy:
+		return snappyDecode(payload)
+	case HubCompressModeCobs:
+		return notecard.CobsDecode(payload, 0)
+	}
+	return nil, fmt.Errorf("unrecognized compression mode: %s %s", mode, note.ErrSyntax)
+}
@@ -0,0 +1,291 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package notehub
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notecard"
+)
+
+// newHubRequest returns a request of the specified type for a project
+func newHubRequest(reqType string, appUID string) HubRequest {
+	return HubRequest{Request: notecard.Request{Req: reqType}, AppUID: appUID}
+}
+
+// appOr returns the project-scoped request type if a project is specified, and
+// otherwise the request type that isn't scoped to a project
+func appOr(appUID string, appReqType string, reqType string) string {
+	if appUID != "" {
+		return appReqType
+	}
+	return reqType
+}
+
+// DeviceContact sets the contact information of a device
+func (c *Client) DeviceContact(appUID string, deviceUID string, contact note.Contact) (err error) {
+	req := newHubRequest(HubDeviceContact, appUID)
+	req.DeviceUID = deviceUID
+	req.Contact = &contact
+	_, err = c.Request(req)
+	return
+}
+
+// DeviceSessionBegin records the beginning of a session of a device
+func (c *Client) DeviceSessionBegin(appUID string, deviceUID string) (err error) {
+	req := newHubRequest(HubDeviceSessionBegin, appUID)
+	req.DeviceUID = deviceUID
+	_, err = c.Request(req)
+	return
+}
+
+// DeviceSessionUsage records the data used so far by a session of a device
+func (c *Client) DeviceSessionUsage(appUID string, deviceUID string, bytesSent uint32, bytesReceived uint32) (err error) {
+	req := newHubRequest(HubDeviceSessionUsage, appUID)
+	req.DeviceUID = deviceUID
+	req.BytesSent = bytesSent
+	req.BytesReceived = bytesReceived
+	_, err = c.Request(req)
+	return
+}
+
+// DeviceSessionEnd records the end of a session of a device
+func (c *Client) DeviceSessionEnd(appUID string, deviceUID string) (err error) {
+	req := newHubRequest(HubDeviceSessionEnd, appUID)
+	req.DeviceUID = deviceUID
+	_, err = c.Request(req)
+	return
+}
+
+// GetSchemas returns the schemas of the notefiles of a project
+func (c *Client) GetSchemas(appUID string) (schemas map[string]interface{}, err error) {
+	rsp, err := c.Request(newHubRequest(HubAppGetSchemas, appUID))
+	if err == nil && rsp.Body != nil {
+		schemas = *rsp.Body
+	}
+	return
+}
+
+// Query performs a query of the notes in a project's database
+func (c *Client) Query(appUID string, query DbQuery) (rsp HubRequest, err error) {
+	req := newHubRequest(HubQuery, appUID)
+	req.DbQuery = &query
+	return c.Request(req)
+}
+
+// EventQuery performs a query of the events of a project
+func (c *Client) EventQuery(appUID string, query DbQuery) (rsp HubRequest, err error) {
+	req := newHubRequest(HubEventQuery, appUID)
+	req.DbQuery = &query
+	return c.Request(req)
+}
+
+// SessionQuery performs a query of the device sessions of a project
+func (c *Client) SessionQuery(appUID string, query DbQuery) (rsp HubRequest, err error) {
+	req := newHubRequest(HubSessionQuery, appUID)
+	req.DbQuery = &query
+	return c.Request(req)
+}
+
+// Upload uploads a file to a project, or to the Notehub itself if appUID is empty,
+// returning the metadata of the uploaded file
+func (c *Client) Upload(appUID string, name string, fileType UploadType, contents []byte, tags string, notes string) (metadata UploadMetadata, err error) {
+	req := newHubRequest(appOr(appUID, HubAppUpload, HubUpload), appUID)
+	req.Name = name
+	req.FileType = fileType
+	req.FileTags = tags
+	req.FileNotes = notes
+	req.Payload = &contents
+	sum := md5.Sum(contents)
+	req.MD5 = hex.EncodeToString(sum[:])
+	rsp, err := c.Request(req)
+	if err == nil && len(rsp.Uploads) > 0 {
+		metadata = rsp.Uploads[0]
+	}
+	return
+}
+
+// Uploads returns the files uploaded to a project, or to the Notehub itself if appUID
+// is empty, optionally filtered by type and by comma-separated tags
+func (c *Client) Uploads(appUID string, fileType UploadType, tags string) (uploads []UploadMetadata, err error) {
+	req := newHubRequest(appOr(appUID, HubAppUploads, HubUploads), appUID)
+	req.FileType = fileType
+	req.FileTags = tags
+	rsp, err := c.Request(req)
+	return rsp.Uploads, err
+}
+
+// SetUploadMetadata replaces the tags and notes of an uploaded file
+func (c *Client) SetUploadMetadata(appUID string, name string, tags string, notes string) (err error) {
+	req := newHubRequest(appOr(appUID, HubAppUploadSet, HubUploadSet), appUID)
+	req.Name = name
+	req.FileTags = tags
+	req.FileNotes = notes
+	_, err = c.Request(req)
+	return
+}
+
+// DeleteUpload deletes an uploaded file
+func (c *Client) DeleteUpload(appUID string, name string) (err error) {
+	req := newHubRequest(appOr(appUID, HubAppUploadDelete, HubUploadDelete), appUID)
+	req.Name = name
+	_, err = c.Request(req)
+	return
+}
+
+// ReadUpload reads length bytes of an uploaded file starting at offset, or the whole
+// file if length is 0
+func (c *Client) ReadUpload(appUID string, name string, offset int32, length int32) (contents []byte, err error) {
+	req := newHubRequest(appOr(appUID, HubAppUploadRead, HubUploadRead), appUID)
+	req.Name = name
+	req.Offset = offset
+	req.Length = length
+	rsp, err := c.Request(req)
+	if err == nil && rsp.Payload != nil {
+		contents = *rsp.Payload
+	}
+	return
+}
+
+// SubmitJob runs a job that has been uploaded to a project, without changing any
+// devices if dryRun is set
+func (c *Client) SubmitJob(appUID string, jobName string, dryRun bool) (rsp HubRequest, err error) {
+	req := newHubRequest(HubAppJobSubmit, appUID)
+	req.Name = jobName
+	req.DryRun = dryRun
+	return c.Request(req)
+}
+
+// GetJob returns the definition of a job
+func (c *Client) GetJob(appUID string, jobName string) (job []byte, err error) {
+	req := newHubRequest(HubAppJobGet, appUID)
+	req.Name = jobName
+	rsp, err := c.Request(req)
+	if err == nil && rsp.Payload != nil {
+		job = *rsp.Payload
+	}
+	return
+}
+
+// PutJob creates or replaces the definition of a job
+func (c *Client) PutJob(appUID string, jobName string, job []byte) (err error) {
+	req := newHubRequest(HubAppJobPut, appUID)
+	req.Name = jobName
+	req.Payload = &job
+	_, err = c.Request(req)
+	return
+}
+
+// DeleteJob deletes a job
+func (c *Client) DeleteJob(appUID string, jobName string) (err error) {
+	req := newHubRequest(HubAppJobDelete, appUID)
+	req.Name = jobName
+	_, err = c.Request(req)
+	return
+}
+
+// GetJobs returns the metadata of the jobs of a project
+func (c *Client) GetJobs(appUID string) (jobs []UploadMetadata, err error) {
+	rsp, err := c.Request(newHubRequest(HubAppJobsGet, appUID))
+	return rsp.Uploads, err
+}
+
+// GetReport returns a report of a run of a job
+func (c *Client) GetReport(appUID string, jobName string, reportID string) (report []byte, err error) {
+	req := newHubRequest(HubAppReportGet, appUID)
+	req.Name = jobName
+	req.NoteID = reportID
+	rsp, err := c.Request(req)
+	if err == nil && rsp.Payload != nil {
+		report = *rsp.Payload
+	}
+	return
+}
+
+// DeleteReport deletes a report of a run of a job
+func (c *Client) DeleteReport(appUID string, jobName string, reportID string) (err error) {
+	req := newHubRequest(HubAppReportDelete, appUID)
+	req.Name = jobName
+	req.NoteID = reportID
+	_, err = c.Request(req)
+	return
+}
+
+// CancelReport cancels a run of a job that is still in progress
+func (c *Client) CancelReport(appUID string, jobName string, reportID string) (err error) {
+	req := newHubRequest(HubAppReportCancel, appUID)
+	req.Name = jobName
+	req.NoteID = reportID
+	_, err = c.Request(req)
+	return
+}
+
+// GetReports returns the metadata of the reports of the runs of a job, or of all
+// jobs if jobName is empty
+func (c *Client) GetReports(appUID string, jobName string) (reports []UploadMetadata, err error) {
+	req := newHubRequest(HubAppReportsGet, appUID)
+	req.Name = jobName
+	rsp, err := c.Request(req)
+	return rsp.Uploads, err
+}
+
+// SetTransform sets the transform applied to the events of a notefile in a project
+func (c *Client) SetTransform(appUID string, notefileID string, transform string) (err error) {
+	req := newHubRequest(HubAppSetTransform, appUID)
+	req.NotefileID = notefileID
+	req.Text = transform
+	_, err = c.Request(req)
+	return
+}
+
+// GetTransform returns the transform applied to the events of a notefile in a project
+func (c *Client) GetTransform(appUID string, notefileID string) (transform string, err error) {
+	req := newHubRequest(HubAppGetTransform, appUID)
+	req.NotefileID = notefileID
+	rsp, err := c.Request(req)
+	return rsp.Text, err
+}
+
+// envRequest returns an environment variable request for the entity identified by uid
+// within a scope: a project, fleet or device, or the fleets of a device
+func envRequest(reqType string, appUID string, scope string, uid string) HubRequest {
+	req := newHubRequest(reqType, appUID)
+	req.Scope = scope
+	switch scope {
+	case HubEnvScopeFleet:
+		req.FleetUID = uid
+	case HubEnvScopeDevice, HubEnvScopeFleets:
+		req.DeviceUID = uid
+	}
+	return req
+}
+
+// EnvSet sets the environment variables of a project, fleet or device
+func (c *Client) EnvSet(appUID string, scope string, uid string, env map[string]string) (err error) {
+	req := envRequest(HubEnvSet, appUID, scope, uid)
+	req.Env = &env
+	_, err = c.Request(req)
+	return
+}
+
+// EnvGet returns the environment variables of a project, fleet or device
+func (c *Client) EnvGet(appUID string, scope string, uid string) (env map[string]string, err error) {
+	rsp, err := c.Request(envRequest(HubEnvGet, appUID, scope, uid))
+	if err == nil && rsp.Env != nil {
+		env = *rsp.Env
+	}
+	return
+}
+
+// EnvGetFleets returns the environment variables of every fleet of a device, by fleet UID
+func (c *Client) EnvGetFleets(appUID string, deviceUID string) (fleetEnv map[string]map[string]string, err error) {
+	rsp, err := c.Request(envRequest(HubEnvGet, appUID, HubEnvScopeFleets, deviceUID))
+	if err == nil && rsp.FleetEnv != nil {
+		fleetEnv = *rsp.FleetEnv
+	}
+	return
+}
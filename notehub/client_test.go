@@ -0,0 +1,93 @@
+package notehub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnappy(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("hello, hello, hello, hello world"),
+		bytes.Repeat([]byte("0123456789abcdef"), 5000),
+		bytes.Repeat([]byte{0}, 70000),
+	}
+	for _, in := range inputs {
+		enc := snappyEncode(in)
+		out, err := snappyDecode(enc)
+		require.NoError(t, err)
+		require.Equal(t, len(in), len(out))
+		require.True(t, bytes.Equal(in, out))
+	}
+	require.Less(t, len(snappyEncode(inputs[3])), len(inputs[3])/10)
+
+	// A stream produced by the reference implementation
+	out, err := snappyDecode([]byte{0x0b, 0x08, 'a', 'b', 'c', 0x11, 0x03})
+	require.NoError(t, err)
+	require.Equal(t, "abcabcabcab", string(out))
+
+	_, err = snappyDecode([]byte{0x05, 0x08, 'a', 'b'})
+	require.Error(t, err)
+}
+
+func TestClientRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, RequestPath, r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req HubRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		rsp := HubRequest{}
+		switch req.Req {
+		case HubAppJobPut:
+			// Echo the payload back, compressed the same way
+			rsp.Payload = req.Payload
+			rsp.Compress = req.Compress
+		case HubEnvGet:
+			require.Equal(t, "dev:1", req.DeviceUID)
+			env := map[string]string{"a": "1"}
+			rsp.Env = &env
+		default:
+			rsp.Err = "app not found " + note.ErrAppNotFound
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "tok")
+	payload := bytes.Repeat([]byte("job "), 100)
+	for _, mode := range []string{"", HubCompressModeSnappy, HubCompressModeCobs} {
+		c.Compress = mode
+		req := newHubRequest(HubAppJobPut, "app:1")
+		req.Payload = &payload
+		rsp, err := c.Request(req)
+		require.NoError(t, err)
+		require.Equal(t, payload, *rsp.Payload)
+		require.Empty(t, rsp.Compress)
+	}
+
+	env, err := c.EnvGet("app:1", HubEnvScopeDevice, "dev:1")
+	require.NoError(t, err)
+	require.Equal(t, "1", env["a"])
+
+	_, err = c.GetJobs("app:2")
+	require.True(t, note.ErrorContains(err, note.ErrAppNotFound))
+	require.True(t, errors.Is(err, note.ErrAppNotFoundSentinel))
+	require.Equal(t, HubAppJobsGet, note.ErrorFrom(err).Request)
+	require.Equal(t, "app not found", note.ErrorFrom(err).Message)
+
+	c.Token = "wrong"
+	_, err = c.Request(newHubRequest(HubAppJobsGet, "app:1"))
+	require.True(t, note.ErrorContains(err, note.ErrAuth))
+	require.True(t, errors.Is(err, note.ErrAuthSentinel))
+}
@@ -0,0 +1,340 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package dfu follows firmware updates across a fleet by ingesting the successive DFU
+// snapshots reported by each device, validating phase transitions, detecting devices
+// whose updates have stalled, and estimating when updates in progress will complete.
+package dfu
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub/api"
+)
+
+// Target is the firmware image being updated
+type Target string
+
+// Targets of a DFU, matching the fields of note.DFUEnv
+const (
+	TargetCard  Target = "card"
+	TargetUser  Target = "user"
+	TargetModem Target = "modem"
+	TargetStar  Target = "star"
+)
+
+// DefaultStallSecs is the time after which an update that hasn't progressed is stalled
+const DefaultStallSecs = 30 * 60
+
+// legalTransitions lists the phases that may follow each phase within a single update.
+// Any phase may remain unchanged, and an update may be cancelled back to idle at any
+// time.  Transitions out of the unknown phase are always legal, as is any transition
+// into downloading or sideloading when a new update begins.
+var legalTransitions = map[note.DfuPhase][]note.DfuPhase{
+	note.DfuPhaseIdle:        {note.DfuPhaseDownloading, note.DfuPhaseSideloading, note.DfuPhaseError},
+	note.DfuPhaseDownloading: {note.DfuPhaseReady, note.DfuPhaseError},
+	note.DfuPhaseSideloading: {note.DfuPhaseReady, note.DfuPhaseError},
+	note.DfuPhaseReady:       {note.DfuPhaseUpdating, note.DfuPhaseReadyRetry, note.DfuPhaseError},
+	note.DfuPhaseReadyRetry:  {note.DfuPhaseReady, note.DfuPhaseUpdating, note.DfuPhaseError},
+	note.DfuPhaseUpdating:    {note.DfuPhaseCompleted, note.DfuPhaseReadyRetry, note.DfuPhaseError},
+	note.DfuPhaseCompleted:   {note.DfuPhaseDownloading, note.DfuPhaseSideloading},
+	note.DfuPhaseError:       {note.DfuPhaseDownloading, note.DfuPhaseSideloading, note.DfuPhaseReadyRetry},
+}
+
+// ValidTransition determines whether a DFU may move directly from one phase to another
+func ValidTransition(from note.DfuPhase, to note.DfuPhase) bool {
+	if from == to || from == note.DfuPhaseUnknown || to == note.DfuPhaseIdle {
+		return true
+	}
+	for _, phase := range legalTransitions[from] {
+		if phase == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertKind classifies an alert raised by the tracker
+type AlertKind string
+
+// Kinds of alert
+const (
+	AlertIllegalTransition AlertKind = "illegal-transition"
+	AlertStalled           AlertKind = "stalled"
+	AlertErrorsRising      AlertKind = "errors-rising"
+	AlertFailed            AlertKind = "failed"
+)
+
+// Alert describes a device whose update needs attention
+type Alert struct {
+	DeviceUID string        `json:"device"`
+	Target    Target        `json:"target"`
+	Kind      AlertKind     `json:"kind"`
+	Phase     note.DfuPhase `json:"phase,omitempty"`
+	When      int64         `json:"when,omitempty"`
+	Message   string        `json:"message,omitempty"`
+}
+
+// Progress is the tracker's view of a single update
+type Progress struct {
+	DeviceUID string        `json:"device"`
+	Target    Target        `json:"target"`
+	Phase     note.DfuPhase `json:"phase"`
+	File      string        `json:"file,omitempty"`
+	Version   string        `json:"version,omitempty"`
+	// Fraction of the image that has been transferred to the device, from 0 to 1
+	Fraction float64 `json:"fraction"`
+	// Observed transfer rate, and the time remaining at that rate if it is known
+	BytesPerSec   float64 `json:"bytes_per_sec,omitempty"`
+	RemainingSecs int64   `json:"remaining_secs,omitempty"`
+	// Epoch seconds when the update was last seen to make progress
+	ProgressedAt int64 `json:"progressed,omitempty"`
+	Terminal     bool  `json:"terminal,omitempty"`
+	Stalled      bool  `json:"stalled,omitempty"`
+}
+
+// status is the per-device, per-target state retained between snapshots
+type status struct {
+	state        note.DFUState
+	phase        note.DfuPhase
+	progressedAt int64
+	bytesPerSec  float64
+	stalled      bool
+}
+
+// Tracker ingests DFU snapshots for any number of devices.  It is safe for concurrent use.
+type Tracker struct {
+	// StallSecs is the time without progress after which a non-terminal update is
+	// considered to be stalled
+	StallSecs int64
+
+	lock    sync.Mutex
+	devices map[string]map[Target]*status
+}
+
+// NewTracker returns a tracker using the default stall threshold
+func NewTracker() *Tracker {
+	return &Tracker{
+		StallSecs: DefaultStallSecs,
+		devices:   map[string]map[Target]*status{},
+	}
+}
+
+// targets returns the non-nil states within a DFU env, keyed by target
+func targets(env note.DFUEnv) (states map[Target]*note.DFUState) {
+	states = map[Target]*note.DFUState{}
+	if env.Card != nil {
+		states[TargetCard] = env.Card
+	}
+	if env.User != nil {
+		states[TargetUser] = env.User
+	}
+	if env.Modem != nil {
+		states[TargetModem] = env.Modem
+	}
+	if env.Star != nil {
+		states[TargetStar] = env.Star
+	}
+	return
+}
+
+// Ingest records a DFU snapshot received from a device at the specified epoch time,
+// returning any alerts raised by the change from the previous snapshot
+func (t *Tracker) Ingest(deviceUID string, when int64, env note.DFUEnv) (alerts []Alert) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	device := t.devices[deviceUID]
+	if device == nil {
+		device = map[Target]*status{}
+		t.devices[deviceUID] = device
+	}
+
+	states := targets(env)
+	for _, target := range []Target{TargetCard, TargetUser, TargetModem, TargetStar} {
+		state := states[target]
+		if state == nil {
+			continue
+		}
+		phase := api.ParseDfuPhase(state.Phase)
+		alert := func(kind AlertKind, message string) {
+			alerts = append(alerts, Alert{DeviceUID: deviceUID, Target: target, Kind: kind, Phase: phase, When: when, Message: message})
+		}
+
+		prev := device[target]
+		if prev == nil {
+			device[target] = &status{state: *state, phase: phase, progressedAt: when}
+			if phase == note.DfuPhaseError {
+				alert(AlertFailed, state.Status)
+			}
+			continue
+		}
+
+		// A change in start time or count means that a new update has begun, in which
+		// case the transition is judged against a fresh start rather than the old phase
+		newUpdate := state.BeganSecs != prev.state.BeganSecs || state.DFUStartCount > prev.state.DFUStartCount
+		from := prev.phase
+		if newUpdate && (phase == note.DfuPhaseDownloading || phase == note.DfuPhaseSideloading) {
+			from = note.DfuPhaseIdle
+		}
+		if !ValidTransition(from, phase) {
+			alert(AlertIllegalTransition, fmt.Sprintf("%s to %s", prev.phase, phase))
+		}
+		if phase == note.DfuPhaseError && prev.phase != note.DfuPhaseError {
+			alert(AlertFailed, state.Status)
+		}
+		if state.ConsecutiveErrors > prev.state.ConsecutiveErrors && !newUpdate {
+			alert(AlertErrorsRising, fmt.Sprintf("%d consecutive errors", state.ConsecutiveErrors))
+		}
+
+		// Progress is any change in phase, transfer, or the device's own update time
+		progressed := newUpdate || phase != prev.phase ||
+			state.ReadFromService != prev.state.ReadFromService ||
+			state.UpdatedSecs != prev.state.UpdatedSecs
+		if progressed {
+			if !newUpdate && state.ReadFromService > prev.state.ReadFromService {
+				elapsed := int64(state.UpdatedSecs) - int64(prev.state.UpdatedSecs)
+				if elapsed <= 0 {
+					elapsed = when - prev.progressedAt
+				}
+				if elapsed > 0 {
+					prev.bytesPerSec = float64(state.ReadFromService-prev.state.ReadFromService) / float64(elapsed)
+				}
+			}
+			if newUpdate {
+				prev.bytesPerSec = 0
+			}
+			prev.progressedAt = when
+			prev.stalled = false
+		} else if t.stalled(prev, phase, when) {
+			prev.stalled = true
+			alert(AlertStalled, fmt.Sprintf("no progress for %d seconds", when-prev.progressedAt))
+		}
+		prev.state = *state
+		prev.phase = phase
+	}
+	return
+}
+
+// stalled determines whether an update has newly become stalled as of the specified time
+func (t *Tracker) stalled(s *status, phase note.DfuPhase, now int64) bool {
+	return !s.stalled && !api.IsDfuTerminal(phase) && phase != note.DfuPhaseUnknown &&
+		now-s.progressedAt >= t.StallSecs
+}
+
+// Check raises stall alerts for updates that have not progressed as of the specified
+// epoch time, including those on devices that have stopped reporting altogether.  Each
+// stall is reported once, until the update progresses again.
+func (t *Tracker) Check(now int64) (alerts []Alert) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for deviceUID, device := range t.devices {
+		for target, s := range device {
+			if t.stalled(s, s.phase, now) {
+				s.stalled = true
+				alerts = append(alerts, Alert{
+					DeviceUID: deviceUID,
+					Target:    target,
+					Kind:      AlertStalled,
+					Phase:     s.phase,
+					When:      now,
+					Message:   fmt.Sprintf("no progress for %d seconds", now-s.progressedAt),
+				})
+			}
+		}
+	}
+	sortAlerts(alerts)
+	return
+}
+
+// progress computes the progress of an update from its retained status
+func progress(deviceUID string, target Target, s *status) (p Progress) {
+	p = Progress{
+		DeviceUID:    deviceUID,
+		Target:       target,
+		Phase:        s.phase,
+		File:         s.state.File,
+		Version:      s.state.Version,
+		BytesPerSec:  s.bytesPerSec,
+		ProgressedAt: s.progressedAt,
+		Terminal:     api.IsDfuTerminal(s.phase),
+		Stalled:      s.stalled,
+	}
+	switch s.phase {
+	case note.DfuPhaseReady, note.DfuPhaseReadyRetry, note.DfuPhaseUpdating, note.DfuPhaseCompleted:
+		p.Fraction = 1
+	default:
+		if s.state.DownloadComplete {
+			p.Fraction = 1
+		} else if s.state.Length > 0 {
+			p.Fraction = float64(s.state.ReadFromService) / float64(s.state.Length)
+			if p.Fraction > 1 {
+				p.Fraction = 1
+			}
+		}
+	}
+	if p.Fraction < 1 && s.bytesPerSec > 0 && s.state.Length > s.state.ReadFromService {
+		p.RemainingSecs = int64(float64(s.state.Length-s.state.ReadFromService) / s.bytesPerSec)
+	}
+	return
+}
+
+// Progress returns the progress of a device's update of the specified target
+func (t *Tracker) Progress(deviceUID string, target Target) (p Progress, found bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	s := t.devices[deviceUID][target]
+	if s == nil {
+		return
+	}
+	return progress(deviceUID, target, s), true
+}
+
+// All returns the progress of every update known to the tracker, ordered by device and target
+func (t *Tracker) All() (all []Progress) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for deviceUID, device := range t.devices {
+		for target, s := range device {
+			all = append(all, progress(deviceUID, target, s))
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].DeviceUID != all[j].DeviceUID {
+			return all[i].DeviceUID < all[j].DeviceUID
+		}
+		return all[i].Target < all[j].Target
+	})
+	return
+}
+
+// Stuck returns the progress of every update that is stalled or has failed
+func (t *Tracker) Stuck() (stuck []Progress) {
+	for _, p := range t.All() {
+		if p.Stalled || p.Phase == note.DfuPhaseError {
+			stuck = append(stuck, p)
+		}
+	}
+	return
+}
+
+// Forget discards everything known about a device
+func (t *Tracker) Forget(deviceUID string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.devices, deviceUID)
+}
+
+// sortAlerts orders alerts by device and target
+func sortAlerts(alerts []Alert) {
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].DeviceUID != alerts[j].DeviceUID {
+			return alerts[i].DeviceUID < alerts[j].DeviceUID
+		}
+		return alerts[i].Target < alerts[j].Target
+	})
+}
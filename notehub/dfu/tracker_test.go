@@ -0,0 +1,49 @@
+package dfu
+
+import (
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	tr.StallSecs = 600
+
+	state := note.DFUState{Phase: "downloading", BeganSecs: 1000, Length: 10000, ReadFromService: 1000, UpdatedSecs: 1000}
+	require.Empty(t, tr.Ingest("dev:1", 1000, note.DFUEnv{User: &state}))
+
+	state.ReadFromService = 3000
+	state.UpdatedSecs = 1100
+	require.Empty(t, tr.Ingest("dev:1", 1100, note.DFUEnv{User: &state}))
+	p, found := tr.Progress("dev:1", TargetUser)
+	require.True(t, found)
+	require.InDelta(t, 0.3, p.Fraction, 0.001)
+	require.InDelta(t, 20, p.BytesPerSec, 0.001)
+	require.Equal(t, int64(350), p.RemainingSecs)
+
+	// No progress for longer than the threshold
+	alerts := tr.Ingest("dev:1", 1800, note.DFUEnv{User: &state})
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStalled, alerts[0].Kind)
+	require.Len(t, tr.Stuck(), 1)
+	require.Empty(t, tr.Check(2000))
+
+	// Skipping straight to completed is illegal, and clears the stall
+	state.Phase = "completed"
+	alerts = tr.Ingest("dev:1", 1900, note.DFUEnv{User: &state})
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertIllegalTransition, alerts[0].Kind)
+	require.Empty(t, tr.Stuck())
+
+	// A device that stops reporting is caught by Check
+	modem := note.DFUState{Phase: "ready", BeganSecs: 5}
+	tr.Ingest("dev:2", 2000, note.DFUEnv{Modem: &modem})
+	alerts = tr.Check(2600)
+	require.Len(t, alerts, 1)
+	require.Equal(t, "dev:2", alerts[0].DeviceUID)
+
+	require.True(t, ValidTransition(note.DfuPhaseUpdating, note.DfuPhaseIdle))
+	require.False(t, ValidTransition(note.DfuPhaseIdle, note.DfuPhaseUpdating))
+}
@@ -0,0 +1,231 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package env resolves the environment variables in effect on a device from the layers
+// in which they may be set, with the same precedence as Notehub.  From lowest to highest
+// precedence, the layers are the defaults set by the device itself with env.default, the
+// project, the fleets of which the device is a member in order of fleet UID, and finally
+// the device.
+package env
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blues/note-go/notehub"
+	"github.com/blues/note-go/notehub/api"
+)
+
+// LayerEnvDefault is the layer of defaults set by the device with env.default
+const LayerEnvDefault = "env.default"
+
+// LayerProject is the layer of variables set on the project
+const LayerProject = notehub.HubEnvScopeProject
+
+// LayerFleet is the layer of variables set on a fleet
+const LayerFleet = notehub.HubEnvScopeFleet
+
+// LayerDevice is the layer of variables set on the device through the API or UI
+const LayerDevice = notehub.HubEnvScopeDevice
+
+// Source identifies the layer in which a value was set
+type Source struct {
+	Layer string
+	// FleetUID is the fleet, if the layer is LayerFleet
+	FleetUID string
+}
+
+// String returns the layer, followed by the fleet UID for a fleet
+func (s Source) String() string {
+	if s.Layer == LayerFleet {
+		return s.Layer + " " + s.FleetUID
+	}
+	return s.Layer
+}
+
+// Layers are the variables set in each layer for a device
+type Layers struct {
+	EnvDefault map[string]string
+	Project    map[string]string
+	// Fleets are the variables of each fleet of which the device is a member, by fleet UID
+	Fleets map[string]map[string]string
+	Device map[string]string
+}
+
+// Setting is a value set for a variable in one layer
+type Setting struct {
+	Value  string
+	Source Source
+}
+
+// Value is the effective value of a variable, along with where it came from
+type Value struct {
+	Name  string
+	Value string
+	// Source is the layer whose value is in effect
+	Source Source
+	// Overridden are the values set in lower layers, from highest to lowest precedence
+	Overridden []Setting
+}
+
+// Resolved are the effective variables of a device, by name
+type Resolved map[string]Value
+
+// ordered returns the layers from lowest to highest precedence
+func (l Layers) ordered() (sources []Source, vars []map[string]string) {
+	sources = append(sources, Source{Layer: LayerEnvDefault}, Source{Layer: LayerProject})
+	vars = append(vars, l.EnvDefault, l.Project)
+	fleetUIDs := make([]string, 0, len(l.Fleets))
+	for fleetUID := range l.Fleets {
+		fleetUIDs = append(fleetUIDs, fleetUID)
+	}
+	sort.Strings(fleetUIDs)
+	for _, fleetUID := range fleetUIDs {
+		sources = append(sources, Source{Layer: LayerFleet, FleetUID: fleetUID})
+		vars = append(vars, l.Fleets[fleetUID])
+	}
+	sources = append(sources, Source{Layer: LayerDevice})
+	vars = append(vars, l.Device)
+	return
+}
+
+// Resolve returns the effective variables of the device
+func (l Layers) Resolve() Resolved {
+	resolved := Resolved{}
+	sources, vars := l.ordered()
+	for i, layer := range vars {
+		for name, value := range layer {
+			v, present := resolved[name]
+			if present {
+				v.Overridden = append([]Setting{{Value: v.Value, Source: v.Source}}, v.Overridden...)
+			}
+			v.Name = name
+			v.Value = value
+			v.Source = sources[i]
+			resolved[name] = v
+		}
+	}
+	return resolved
+}
+
+// Vars returns the effective values of the variables
+func (r Resolved) Vars() map[string]string {
+	vars := map[string]string{}
+	for name, v := range r {
+		vars[name] = v.Value
+	}
+	return vars
+}
+
+// Names returns the names of the variables, sorted
+func (r Resolved) Names() (names []string) {
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Explain describes where the value of a variable came from and what it overrode
+func (r Resolved) Explain(name string) string {
+	v, present := r[name]
+	if !present {
+		return fmt.Sprintf("%s is not set", name)
+	}
+	s := fmt.Sprintf("%s=%q from %s", name, v.Value, v.Source)
+	if len(v.Overridden) > 0 {
+		overridden := make([]string, len(v.Overridden))
+		for i, o := range v.Overridden {
+			overridden[i] = fmt.Sprintf("%q from %s", o.Value, o.Source)
+		}
+		s += " (overrides " + strings.Join(overridden, ", ") + ")"
+	}
+	return s
+}
+
+// LayersFromAPI fetches the layers of a device's variables from the API
+func LayersFromAPI(ctx context.Context, client *api.Client, projectUID string, deviceUID string) (layers Layers, err error) {
+	device, err := client.GetDevice(ctx, projectUID, deviceUID)
+	if err != nil {
+		return
+	}
+	app, err := client.GetAppEnvironmentVariables(ctx, projectUID)
+	if err != nil {
+		return
+	}
+	deviceVars, err := client.GetDeviceEnvironmentVariables(ctx, projectUID, deviceUID)
+	if err != nil {
+		return
+	}
+	layers = Layers{
+		EnvDefault: deviceVars.EnvironmentVariablesEnvDefault,
+		Project:    app.EnvironmentVariables,
+		Fleets:     map[string]map[string]string{},
+		Device:     deviceVars.EnvironmentVariables,
+	}
+	for _, fleetUID := range device.FleetUIDs {
+		var fleet api.GetFleetEnvironmentVariablesResponse
+		fleet, err = client.GetFleetEnvironmentVariables(ctx, projectUID, fleetUID)
+		if err != nil {
+			return
+		}
+		layers.Fleets[fleetUID] = fleet.EnvironmentVariables
+	}
+	return
+}
+
+// Drift is a variable whose effective value on a device differs from its intended value
+type Drift struct {
+	DeviceUID string
+	Name      string
+	Intended  string
+	// Effective is empty if the variable isn't set
+	Effective string
+	// Source is the layer whose value is in effect, if the variable is set
+	Source *Source
+}
+
+// String describes the drift
+func (d Drift) String() string {
+	if d.Source == nil {
+		return fmt.Sprintf("%s: %s is not set, intended %q", d.DeviceUID, d.Name, d.Intended)
+	}
+	return fmt.Sprintf("%s: %s=%q from %s, intended %q", d.DeviceUID, d.Name, d.Effective, d.Source, d.Intended)
+}
+
+// DiffFleet compares the intended values of variables with their effective values on
+// each of a set of devices, such as the members of a fleet, keyed by device UID.  The
+// drift is ordered by device and then by variable.
+func DiffFleet(intended map[string]string, devices map[string]Layers) (drift []Drift) {
+	deviceUIDs := make([]string, 0, len(devices))
+	for deviceUID := range devices {
+		deviceUIDs = append(deviceUIDs, deviceUID)
+	}
+	sort.Strings(deviceUIDs)
+	names := make([]string, 0, len(intended))
+	for name := range intended {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, deviceUID := range deviceUIDs {
+		resolved := devices[deviceUID].Resolve()
+		for _, name := range names {
+			v, present := resolved[name]
+			if present && v.Value == intended[name] {
+				continue
+			}
+			d := Drift{DeviceUID: deviceUID, Name: name, Intended: intended[name]}
+			if present {
+				source := v.Source
+				d.Effective = v.Value
+				d.Source = &source
+			}
+			drift = append(drift, d)
+		}
+	}
+	return
+}
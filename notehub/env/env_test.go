@@ -0,0 +1,46 @@
+package env_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blues/note-go/notehub/api"
+	"github.com/blues/note-go/notehub/api/apitest"
+	"github.com/blues/note-go/notehub/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	server := apitest.NewServer()
+	defer server.Close()
+	server.AddProject("app:1", "test")
+	server.AddFleet("app:1", api.FleetResponse{UID: "fleet:b", EnvironmentVariables: map[string]string{"interval": "60", "mode": "b"}})
+	server.AddFleet("app:1", api.FleetResponse{UID: "fleet:a", EnvironmentVariables: map[string]string{"mode": "a"}})
+	server.AddDevice("app:1", api.GetDeviceResponse{UID: "dev:1", FleetUIDs: []string{"fleet:b", "fleet:a"}})
+	server.AddDevice("app:1", api.GetDeviceResponse{UID: "dev:2"})
+	server.SetDeviceEnvDefaults("app:1", "dev:1", map[string]string{"interval": "10", "debug": "0"})
+
+	ctx := context.Background()
+	client := api.NewClient(server.URL, "")
+	_, err := client.PutAppEnvironmentVariables(ctx, "app:1", api.PutAppEnvironmentVariablesRequest{EnvironmentVariables: map[string]string{"interval": "30"}})
+	require.NoError(t, err)
+	_, err = client.PutDeviceEnvironmentVariables(ctx, "app:1", "dev:1", api.PutDeviceEnvironmentVariablesRequest{EnvironmentVariables: map[string]string{"debug": "1"}})
+	require.NoError(t, err)
+
+	layers, err := env.LayersFromAPI(ctx, client, "app:1", "dev:1")
+	require.NoError(t, err)
+	resolved := layers.Resolve()
+	require.Equal(t, map[string]string{"interval": "60", "mode": "b", "debug": "1"}, resolved.Vars())
+	require.Equal(t, server.EffectiveEnvironment("app:1", "dev:1"), resolved.Vars())
+	require.Equal(t, env.Source{Layer: env.LayerFleet, FleetUID: "fleet:b"}, resolved["mode"].Source)
+	require.Equal(t, `interval="60" from fleet fleet:b (overrides "30" from project, "10" from env.default)`, resolved.Explain("interval"))
+	require.Equal(t, `debug="1" from device (overrides "0" from env.default)`, resolved.Explain("debug"))
+	require.Equal(t, "other is not set", resolved.Explain("other"))
+
+	other, err := env.LayersFromAPI(ctx, client, "app:1", "dev:2")
+	require.NoError(t, err)
+	drift := env.DiffFleet(map[string]string{"interval": "60", "mode": "b"}, map[string]env.Layers{"dev:1": layers, "dev:2": other})
+	require.Len(t, drift, 2)
+	require.Equal(t, `dev:2: interval="30" from project, intended "60"`, drift[0].String())
+	require.Equal(t, `dev:2: mode is not set, intended "b"`, drift[1].String())
+}
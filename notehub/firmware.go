@@ -0,0 +1,230 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package notehub
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+)
+
+// FirmwareSignature precedes the JSON object describing the firmware within a firmware
+// image, which the firmware's build embeds as a string constant
+const FirmwareSignature = "firmware::info:"
+
+// DefaultUploadChunkSize is the number of bytes of a file uploaded per request
+const DefaultUploadChunkSize = 256 * 1024
+
+// DefaultUploadRetries is the number of times a chunk is retried after a transient failure
+const DefaultUploadRetries = 3
+
+// ExtractFirmwareInfo finds the firmware signature within a firmware image and returns
+// the firmware metadata that follows it
+func ExtractFirmwareInfo(image []byte) (info *HubRequestFileFirmware, err error) {
+	i := bytes.Index(image, []byte(FirmwareSignature))
+	if i < 0 {
+		return nil, fmt.Errorf("firmware image has no firmware signature %s", note.ErrIncompatible)
+	}
+	object, err := firmwareObject(image[i+len(FirmwareSignature):])
+	if err != nil {
+		return nil, err
+	}
+	info = &HubRequestFileFirmware{}
+	err = note.JSONUnmarshal(object, info)
+	if err != nil {
+		return nil, fmt.Errorf("firmware signature is not followed by valid metadata: %w %s", err, note.ErrIncompatible)
+	}
+	return info, nil
+}
+
+// firmwareObject returns the JSON object at the start of a buffer, which ends at the
+// brace that balances its opening brace.  The object is a C string in the image, so it
+// may not contain a NUL.
+func firmwareObject(buf []byte) ([]byte, error) {
+	if len(buf) == 0 || buf[0] != '{' {
+		return nil, fmt.Errorf("firmware signature is not followed by an object %s", note.ErrIncompatible)
+	}
+	depth := 0
+	quoted := false
+	escaped := false
+	for i, c := range buf {
+		switch {
+		case c == 0:
+			return nil, fmt.Errorf("firmware metadata is not terminated %s", note.ErrIncompatible)
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return buf[:i+1], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("firmware metadata is not terminated %s", note.ErrIncompatible)
+}
+
+// NormalizeTags returns comma-separated tags in the form in which they are stored:
+// lowercase, without spaces or duplicates, and sorted
+func NormalizeTags(tags ...string) string {
+	present := map[string]bool{}
+	var normalized []string
+	for _, list := range tags {
+		for _, tag := range strings.Split(list, ",") {
+			tag = strings.ToLower(strings.Join(strings.Fields(tag), ""))
+			if tag != "" && !present[tag] {
+				present[tag] = true
+				normalized = append(normalized, tag)
+			}
+		}
+	}
+	sort.Strings(normalized)
+	return strings.Join(normalized, ",")
+}
+
+// NewUploadMetadata describes a file to be uploaded, including the firmware metadata
+// embedded in it if it's a firmware image.  Files of unknown type that contain firmware
+// metadata are taken to be host firmware.
+func NewUploadMetadata(name string, fileType UploadType, contents []byte) (metadata UploadMetadata, err error) {
+	sum := md5.Sum(contents)
+	metadata = UploadMetadata{
+		Name:     name,
+		Length:   len(contents),
+		MD5:      hex.EncodeToString(sum[:]),
+		CRC32:    crc32.ChecksumIEEE(contents),
+		Created:  time.Now().Unix(),
+		FileType: fileType,
+	}
+	switch fileType {
+	case UploadTypeUnknown, UploadTypeHostFirmware, UploadTypeNotecardFirmware, UploadTypeModemFirmware, UploadTypeStarnoteFirmware:
+		info, infoErr := ExtractFirmwareInfo(contents)
+		if infoErr != nil {
+			if fileType == UploadTypeHostFirmware || fileType == UploadTypeNotecardFirmware {
+				return metadata, infoErr
+			}
+			break
+		}
+		metadata.Firmware = info
+		if metadata.FileType == UploadTypeUnknown {
+			metadata.FileType = UploadTypeHostFirmware
+		}
+	}
+	return
+}
+
+// UploadOptions controls how a file is uploaded
+type UploadOptions struct {
+	// Tags are comma-separated tags of the file
+	Tags string
+	// Publish tags the file so that it is shown in the Notehub UI
+	Publish bool
+	// Notes describe the file
+	Notes string
+	// Version is a user-specified version string recorded with the upload, separately
+	// from the version in the firmware metadata
+	Version string
+	// ChunkSize is the number of bytes uploaded per request
+	ChunkSize int
+	// Retries is the number of times a chunk is retried after a transient failure
+	Retries int
+	// NoRetry disables retries, so that a chunk that fails fails the upload
+	NoRetry bool
+	// RetryDelay is the delay before the first retry, which doubles with each retry
+	RetryDelay time.Duration
+}
+
+// UploadFirmware uploads a firmware image or other file to a project, in chunks that
+// are each retried after transient failures, and returns the metadata of the upload
+func (c *Client) UploadFirmware(ctx context.Context, appUID string, name string, fileType UploadType, contents []byte, opts UploadOptions) (metadata UploadMetadata, err error) {
+	metadata, err = NewUploadMetadata(name, fileType, contents)
+	if err != nil {
+		return
+	}
+	tags := opts.Tags
+	if opts.Publish {
+		tags = tags + "," + HubRequestFileTagPublish
+	}
+	metadata.Tags = NormalizeTags(tags)
+	metadata.Notes = opts.Notes
+	metadata.Version = opts.Version
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultUploadChunkSize
+	}
+	if opts.NoRetry {
+		opts.Retries = 0
+	} else if opts.Retries <= 0 {
+		opts.Retries = DefaultUploadRetries
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Second
+	}
+
+	for offset := 0; offset == 0 || offset < len(contents); offset += opts.ChunkSize {
+		end := offset + opts.ChunkSize
+		if end > len(contents) {
+			end = len(contents)
+		}
+		chunk := contents[offset:end]
+		req := newHubRequest(appOr(appUID, HubAppUpload, HubUpload), appUID)
+		req.Name = name
+		req.FileType = metadata.FileType
+		req.FileTags = metadata.Tags
+		req.FileNotes = metadata.Notes
+		req.MD5 = metadata.MD5
+		req.Offset = int32(offset)
+		req.Length = int32(len(chunk))
+		req.Total = int32(len(contents))
+		req.Payload = &chunk
+		if offset == 0 {
+			req.Uploads = []UploadMetadata{metadata}
+		}
+		var rsp HubRequest
+		rsp, err = c.requestWithRetry(ctx, req, opts.Retries, opts.RetryDelay)
+		if err != nil {
+			return metadata, fmt.Errorf("uploading %s at offset %d: %w", name, offset, err)
+		}
+		if end == len(contents) && len(rsp.Uploads) > 0 {
+			metadata = rsp.Uploads[0]
+		}
+	}
+	return
+}
+
+// requestWithRetry performs a request, retrying it after network errors and timeouts
+func (c *Client) requestWithRetry(ctx context.Context, req HubRequest, retries int, delay time.Duration) (rsp HubRequest, err error) {
+	for attempt := 0; ; attempt++ {
+		rsp, err = c.RequestContext(ctx, req)
+		if err == nil || attempt >= retries || !retryable(err) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return rsp, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// retryable returns true if a request failed in a way that retrying it may remedy
+func retryable(err error) bool {
+	return note.ErrorContains(err, note.ErrNetwork) ||
+		note.ErrorContains(err, note.ErrTimeout) ||
+		note.ErrorContains(err, note.ErrInternalTimeout)
+}
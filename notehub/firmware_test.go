@@ -0,0 +1,77 @@
+package notehub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirmwareUpload(t *testing.T) {
+	image := append(bytes.Repeat([]byte{0xff, 0x00}, 1000), []byte(FirmwareSignature+`{"org":"Blues","product":"Sensor {v2}","ver_major":1,"ver_minor":2,"ver_patch":3,"ver_build":45,"builder":"ci"}`)...)
+	image = append(image, 0, 0x12, 0x34)
+
+	info, err := ExtractFirmwareInfo(image)
+	require.NoError(t, err)
+	require.Equal(t, "Sensor {v2}", info.Product)
+	require.Equal(t, "1.2.3.45", info.VersionString())
+
+	_, err = ExtractFirmwareInfo([]byte("no signature here"))
+	require.Error(t, err)
+	_, err = NewUploadMetadata("app.bin", UploadTypeHostFirmware, []byte(FirmwareSignature+"{\"org\":"))
+	require.Error(t, err)
+
+	var received []byte
+	var first HubRequest
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req HubRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, HubAppUpload, req.Req)
+		if req.Offset == 1000 && failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		require.Equal(t, int(req.Offset), len(received))
+		if req.Offset == 0 {
+			first = req
+		}
+		received = append(received, *req.Payload...)
+		rsp := HubRequest{}
+		if len(received) == int(req.Total) {
+			rsp.Uploads = first.Uploads
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "tok")
+	metadata, err := c.UploadFirmware(context.Background(), "app:1", "sensor.bin", UploadTypeUnknown, image,
+		UploadOptions{Tags: "Beta, sensor", Publish: true, Version: "1.2.3-rc1", ChunkSize: 500, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, image, received)
+	require.Equal(t, 0, failures)
+	require.Equal(t, UploadTypeHostFirmware, metadata.FileType)
+	require.Equal(t, "beta,publish,sensor", metadata.Tags)
+	require.True(t, metadata.IsPublished())
+	require.Equal(t, "Blues", metadata.Firmware.Organization)
+	require.Equal(t, len(image), metadata.Length)
+	require.NotZero(t, metadata.CRC32)
+	require.Equal(t, "1.2.3-rc1", metadata.Version)
+
+	// Without a version none is sent, and a chunk isn't retried if retries are disabled
+	received = nil
+	failures = 1
+	_, err = c.UploadFirmware(context.Background(), "app:1", "sensor.bin", UploadTypeUnknown, image,
+		UploadOptions{ChunkSize: 500, NoRetry: true, RetryDelay: time.Millisecond})
+	require.True(t, note.ErrorContains(err, note.ErrNetwork))
+	require.Equal(t, 0, failures)
+	require.Equal(t, "", first.Uploads[0].Version)
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub"
+	"github.com/blues/note-go/notehub/api"
+)
+
+// DefaultPollInterval is how often the report of a running job is polled
+const DefaultPollInterval = 5 * time.Second
+
+// Run is a submitted run of a job, whose progress is recorded in a report
+type Run struct {
+	client   *notehub.Client
+	AppUID   string
+	JobName  string
+	ReportID string
+	// PollInterval is how often Wait polls the report
+	PollInterval time.Duration
+}
+
+// Submit runs a job that has been uploaded to a project, or reports what it would do
+// without changing any devices if dryRun is set
+func Submit(client *notehub.Client, appUID string, jobName string, dryRun bool) (run *Run, err error) {
+	rsp, err := client.SubmitJob(appUID, jobName, dryRun)
+	if err != nil {
+		return
+	}
+	if rsp.NoteID == "" {
+		return nil, note.ErrorFrom(fmt.Errorf("no report was returned for job %s %s", jobName, note.ErrIncompatible))
+	}
+	return &Run{client: client, AppUID: appUID, JobName: jobName, ReportID: rsp.NoteID, PollInterval: DefaultPollInterval}, nil
+}
+
+// Upload uploads a reconciliation job to a project under its name, after validating it
+func Upload(client *notehub.Client, appUID string, job api.HubJobReconciliation) (err error) {
+	if job.Header.Name == "" {
+		return fmt.Errorf("job has no name %s", note.ErrSyntax)
+	}
+	err = Validate(job)
+	if err != nil {
+		return
+	}
+	contents, err := note.JSONMarshal(job)
+	if err != nil {
+		return
+	}
+	return client.PutJob(appUID, job.Header.Name, contents)
+}
+
+// Report returns the current report of the run
+func (run *Run) Report() (report api.HubReportReconciliation, err error) {
+	contents, err := run.client.GetReport(run.AppUID, run.JobName, run.ReportID)
+	if err != nil {
+		return
+	}
+	err = note.JSONUnmarshal(contents, &report)
+	if err != nil {
+		return report, fmt.Errorf("cannot parse report %s of job %s: %w %s", run.ReportID, run.JobName, err, note.ErrSyntax)
+	}
+	return
+}
+
+// Done returns true if a report is of a run that has completed or been cancelled
+func Done(report api.HubReportReconciliation) bool {
+	return report.Header.Completed != 0 || report.Header.Status == api.HubJobStatusCancelled
+}
+
+// Wait polls the report of the run until the run completes or is cancelled, or until
+// the context is done, and returns the last report received
+func (run *Run) Wait(ctx context.Context) (report api.HubReportReconciliation, err error) {
+	interval := run.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for {
+		report, err = run.Report()
+		if err != nil || Done(report) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Cancel cancels the run if it is still in progress
+func (run *Run) Cancel() error {
+	return run.client.CancelReport(run.AppUID, run.JobName, run.ReportID)
+}
+
+// Delete deletes the report of the run
+func (run *Run) Delete() error {
+	return run.client.DeleteReport(run.AppUID, run.JobName, run.ReportID)
+}
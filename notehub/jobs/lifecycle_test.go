@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub"
+	"github.com/blues/note-go/notehub/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	polls := 0
+	cancelled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req notehub.HubRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		rsp := notehub.HubRequest{}
+		switch req.Req {
+		case notehub.HubAppJobSubmit:
+			if req.Name == "rollout" {
+				rsp.NoteID = "report-1"
+			}
+		case notehub.HubAppReportCancel:
+			cancelled = true
+		case notehub.HubAppReportGet:
+			require.Equal(t, "report-1", req.NoteID)
+			polls++
+			report := api.HubReportReconciliation{Header: api.HubJobReport{JobName: "rollout", JobId: "job-1", Status: "running", Submitted: 1700000000}}
+			if polls == 2 {
+				report.Header.Status = "completed"
+				report.Header.Completed = 1700000042
+				report.Status = api.HubJobReconciliationReportStatus{
+					DeviceCount: 3,
+					Actions:     map[string]string{"dev:2": "joined fleet:b", "dev:1": "set sn"},
+					Errors:      map[string]string{"dev:3": "device disabled {device-disabled}"},
+				}
+			}
+			payload, _ := note.JSONMarshal(report)
+			rsp.Payload = &payload
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer server.Close()
+
+	_, err := Submit(notehub.NewClient(server.URL, "tok"), "app:1", "other", false)
+	require.True(t, errors.Is(err, note.ErrIncompatibleSentinel))
+
+	run, err := Submit(notehub.NewClient(server.URL, "tok"), "app:1", "rollout", false)
+	require.NoError(t, err)
+	run.PollInterval = time.Millisecond
+	report, err := run.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, polls)
+	require.NoError(t, run.Cancel())
+	require.True(t, cancelled)
+
+	s := Summarize(report)
+	require.Equal(t, 2, s.Succeeded)
+	require.Equal(t, 1, s.Failed)
+	require.Equal(t, "dev:1", s.Devices[0].DeviceUID)
+
+	var b bytes.Buffer
+	require.NoError(t, s.WriteTable(&b))
+	require.Contains(t, b.String(), "3 devices, 2 succeeded, 1 failed")
+	require.Contains(t, b.String(), "dev:3   failed  device disabled")
+
+	b.Reset()
+	require.NoError(t, s.WriteCSV(&b))
+	require.Equal(t, `job,job_id,dry_run,device,result,action,error
+rollout,job-1,false,dev:1,ok,set sn,
+rollout,job-1,false,dev:2,ok,joined fleet:b,
+rollout,job-1,false,dev:3,failed,,device disabled {device-disabled}
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, s.WriteJUnit(&b))
+	require.Contains(t, b.String(), `<testsuite name="rollout" tests="3" failures="1" errors="0" timestamp="2023-11-14T22:13:20Z" time="42">`)
+	require.Contains(t, b.String(), `<failure message="device disabled {device-disabled}">`)
+}
@@ -0,0 +1,331 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub"
+	"github.com/blues/note-go/notehub/api"
+)
+
+// ClearValue is the value of a serial number or variable that clears it.  Notehub gives
+// it the same meaning in the sn_to_set and vars_to_set requests of a job as in its other
+// APIs, so a removal in a Diff is submitted as ClearValue.  Variable defaults have no
+// such value, so they can't be cleared by a job.
+const ClearValue = "-"
+
+// Change is a change to a device planned by a job
+type Change struct {
+	DeviceUID string
+	// Field is what changes, such as "sn", "fleet" or "var NAME"
+	Field string
+	// From is empty if the field is being added
+	From string
+	// To is empty if the field is being removed
+	To string
+}
+
+// Diff is the set of changes planned by a job, ordered by device
+type Diff []Change
+
+// Plan compares the desired state of devices with their current state, and returns a
+// reconciliation job that requests only the changes needed, along with those changes.
+// Devices that need no changes are left out of the job, so there is nothing to submit
+// if there are no changes.
+func Plan(desired DesiredState, current State) (job api.HubJobReconciliation, diff Diff, err error) {
+	job.Header = api.HubJob{
+		Type:    api.HubJobTypeReconciliation,
+		Version: fmt.Sprintf("%d.%d", api.HubJobReconciliationMajorVersion, api.HubJobReconciliationMinorVersion),
+		Name:    desired.Name,
+		Comment: desired.Comment,
+		Created: time.Now().Unix(),
+	}
+	job.Comment = desired.Comment
+	job.DeviceRequests = map[string]api.HubJobReconciliationRequests{}
+	job.ReportOptions = api.HubJobReconciliationReportOptions{DeviceInfo: true, DeviceVars: true}
+
+	for _, deviceUID := range desired.DeviceUIDs() {
+		want := desired.device(deviceUID)
+		have, exists := current[deviceUID]
+		if !exists && want.Provision == "" {
+			return job, diff, fmt.Errorf("device %s is not provisioned and no product is specified %s", deviceUID, note.ErrDeviceNotFound)
+		}
+		for name, value := range want.DefaultVars {
+			if value == ClearValue {
+				return job, diff, fmt.Errorf("default var %s of device %s cannot be cleared %s", name, deviceUID, note.ErrSyntax)
+			}
+		}
+		req, changes := planDevice(deviceUID, want, have, exists)
+		if len(changes) == 0 {
+			continue
+		}
+		job.DeviceRequests[deviceUID] = req
+		job.Select.Devices = append(job.Select.Devices, deviceUID)
+		diff = append(diff, changes...)
+	}
+
+	if len(diff) > 0 {
+		err = Validate(job)
+	}
+	return
+}
+
+// planDevice returns the requests that bring a device from its current state to its
+// desired state, along with the changes that they make
+func planDevice(deviceUID string, want DesiredDevice, have DeviceState, exists bool) (req api.HubJobReconciliationRequests, changes Diff) {
+	change := func(field string, from string, to string) {
+		changes = append(changes, Change{DeviceUID: deviceUID, Field: field, From: from, To: to})
+	}
+
+	if !exists {
+		req.ProvisionProductUID = want.Provision
+		change("provisioned", "", want.Provision)
+	}
+
+	if want.SerialNumber != nil {
+		sn := *want.SerialNumber
+		if sn == ClearValue {
+			sn = ""
+		}
+		if sn != have.SerialNumber {
+			// Clearing the serial number is requested by setting it to ClearValue
+			req.SnToSet = sn
+			if sn == "" {
+				req.SnToSet = ClearValue
+			}
+			change("sn", have.SerialNumber, sn)
+		}
+	}
+
+	if want.Enabled != nil && *want.Enabled == have.Disabled {
+		req.Enable = *want.Enabled
+		req.Disable = !*want.Enabled
+		change("enabled", fmt.Sprint(!have.Disabled), fmt.Sprint(*want.Enabled))
+	}
+
+	if want.ConnectivityAssurance != nil &&
+		(have.ConnectivityAssurance == nil || *have.ConnectivityAssurance != *want.ConnectivityAssurance) {
+		req.CaEnable = *want.ConnectivityAssurance
+		req.CaDisable = !*want.ConnectivityAssurance
+		from := ""
+		if have.ConnectivityAssurance != nil {
+			from = fmt.Sprint(*have.ConnectivityAssurance)
+		}
+		change("connectivity_assurance", from, fmt.Sprint(*want.ConnectivityAssurance))
+	}
+
+	member := map[string]bool{}
+	for _, fleetUID := range have.Fleets {
+		member[fleetUID] = true
+	}
+	join := map[string]bool{}
+	leave := map[string]bool{}
+	if want.Fleets != nil {
+		wanted := map[string]bool{}
+		for _, fleetUID := range *want.Fleets {
+			wanted[fleetUID] = true
+			join[fleetUID] = true
+		}
+		for _, fleetUID := range have.Fleets {
+			if !wanted[fleetUID] {
+				leave[fleetUID] = true
+			}
+		}
+	}
+	for _, fleetUID := range want.JoinFleets {
+		join[fleetUID] = true
+	}
+	for _, fleetUID := range want.LeaveFleets {
+		leave[fleetUID] = true
+	}
+	for _, fleetUID := range sortedKeys(join) {
+		if !member[fleetUID] && !leave[fleetUID] {
+			req.FleetsToJoin = append(req.FleetsToJoin, fleetUID)
+			change("fleet", "", fleetUID)
+		}
+	}
+	for _, fleetUID := range sortedKeys(leave) {
+		if member[fleetUID] {
+			req.FleetsToLeave = append(req.FleetsToLeave, fleetUID)
+			change("fleet", fleetUID, "")
+		}
+	}
+
+	req.VarsToSet = planVars(want.Vars, have.Vars, "var", change)
+	req.VarsToDefault = planVars(want.DefaultVars, have.DefaultVars, "default var", change)
+	return
+}
+
+// planVars returns the variables that must be set to bring the current variables to the
+// desired variables, recording a change for each.  A variable that is present but should
+// not be is removed by setting it to ClearValue.
+func planVars(want map[string]string, have map[string]string, kind string, change func(field string, from string, to string)) (vars map[string]string) {
+	for _, name := range sortedKeys(want) {
+		value := want[name]
+		current, present := have[name]
+		to := value
+		if value == ClearValue {
+			if !present {
+				continue
+			}
+			to = ""
+		} else if present && current == value {
+			continue
+		}
+		if vars == nil {
+			vars = map[string]string{}
+		}
+		vars[name] = value
+		change(kind+" "+name, current, to)
+	}
+	return
+}
+
+func sortedKeys(m interface{}) (keys []string) {
+	switch m := m.(type) {
+	case map[string]bool:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
+
+// Validate checks that a reconciliation job is well-formed and that none of its requests
+// contradict each other, returning an error describing every problem found
+func Validate(job api.HubJobReconciliation) error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if job.Header.Type != api.HubJobTypeReconciliation {
+		problem("job type is %q rather than %q", job.Header.Type, api.HubJobTypeReconciliation)
+	}
+	if job.Header.Version != "" {
+		var major, minor int
+		_, err := fmt.Sscanf(job.Header.Version, "%d.%d", &major, &minor)
+		if err != nil || major != api.HubJobReconciliationMajorVersion {
+			problem("unsupported job version %s", job.Header.Version)
+		}
+	}
+	sel := job.Select
+	if !sel.AllDevices && len(sel.Devices) == 0 && len(sel.DevicesInFleets) == 0 && len(sel.DevicesBySn) == 0 {
+		problem("no devices are selected")
+	}
+	selected := map[string]bool{}
+	for _, deviceUID := range sel.Devices {
+		selected[deviceUID] = true
+	}
+
+	validateRequests("default requests", job.DefaultRequests, problem)
+	deviceUIDs := []string{}
+	for deviceUID := range job.DeviceRequests {
+		deviceUIDs = append(deviceUIDs, deviceUID)
+	}
+	sort.Strings(deviceUIDs)
+	for _, deviceUID := range deviceUIDs {
+		if !strings.HasPrefix(deviceUID, "dev:") {
+			problem("%s is not a device UID", deviceUID)
+		}
+		if !sel.AllDevices && len(sel.DevicesInFleets) == 0 && len(sel.DevicesBySn) == 0 && !selected[deviceUID] {
+			problem("%s has requests but is not selected", deviceUID)
+		}
+		validateRequests(deviceUID, job.DeviceRequests[deviceUID], problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid job: %s %s", strings.Join(problems, "; "), note.ErrSyntax)
+	}
+	return nil
+}
+
+func validateRequests(what string, req api.HubJobReconciliationRequests, problem func(format string, args ...interface{})) {
+	if req.Enable && req.Disable {
+		problem("%s both enable and disable", what)
+	}
+	if req.CaEnable && req.CaDisable {
+		problem("%s both enable and disable connectivity assurance", what)
+	}
+	if req.SnToSet != "" && req.SnToDefault != "" {
+		problem("%s both set and default the serial number", what)
+	}
+	fleets := map[string]string{}
+	for _, list := range []struct {
+		verb      string
+		fleetUIDs []string
+	}{{"join", req.FleetsToJoin}, {"leave", req.FleetsToLeave}, {"default", req.FleetsToDefault}} {
+		for _, fleetUID := range list.fleetUIDs {
+			if !strings.HasPrefix(fleetUID, "fleet:") {
+				problem("%s: %s is not a fleet UID", what, fleetUID)
+			}
+			if verb, present := fleets[fleetUID]; present {
+				problem("%s both %s and %s %s", what, verb, list.verb, fleetUID)
+			}
+			fleets[fleetUID] = list.verb
+		}
+	}
+	for name := range req.VarsToSet {
+		if _, present := req.VarsToDefault[name]; present {
+			problem("%s both set and default variable %s", what, name)
+		}
+	}
+	for _, vars := range []map[string]string{req.VarsToSet, req.VarsToDefault} {
+		for name := range vars {
+			if strings.TrimSpace(name) == "" {
+				problem("%s has a variable with no name", what)
+			}
+		}
+	}
+}
+
+// String renders the changes as a human-readable dry-run diff, grouped by device, in
+// which + marks something added, - something removed (by submitting ClearValue for a
+// serial number or variable), and ~ something changed
+func (diff Diff) String() string {
+	if len(diff) == 0 {
+		return "no changes\n"
+	}
+	var b strings.Builder
+	devices := 0
+	last := ""
+	for _, c := range diff {
+		if c.DeviceUID != last {
+			fmt.Fprintf(&b, "%s\n", c.DeviceUID)
+			last = c.DeviceUID
+			devices++
+		}
+		switch {
+		case c.From == "":
+			fmt.Fprintf(&b, "  + %s %q\n", c.Field, c.To)
+		case c.To == "":
+			fmt.Fprintf(&b, "  - %s %q\n", c.Field, c.From)
+		default:
+			fmt.Fprintf(&b, "  ~ %s %q -> %q\n", c.Field, c.From, c.To)
+		}
+	}
+	fmt.Fprintf(&b, "%d changes to %d devices\n", len(diff), devices)
+	return b.String()
+}
+
+// DryRun uploads a job to a project under its name and submits it as a dry run, which
+// reports what the job would do without changing any devices
+func DryRun(client *notehub.Client, appUID string, job api.HubJobReconciliation) (rsp notehub.HubRequest, err error) {
+	err = Upload(client, appUID, job)
+	if err != nil {
+		return
+	}
+	return client.SubmitJob(appUID, job.Header.Name, true)
+}
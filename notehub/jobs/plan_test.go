@@ -0,0 +1,117 @@
+package jobs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub"
+	"github.com/blues/note-go/notehub/api"
+	"github.com/stretchr/testify/require"
+)
+
+const desiredYAML = `
+name: rollout
+defaults:
+  join_fleets: [fleet:all]
+  vars:
+    interval: "60"
+devices:
+  dev:1:
+    sn: gateway-1
+    fleets: [fleet:all, fleet:b]
+    vars:
+      legacy: "-"
+  dev:2:
+    enabled: true
+  dev:3:
+    provision: product:com.example:sensor
+`
+
+func TestPlan(t *testing.T) {
+	desired, err := ParseDesiredState([]byte(desiredYAML))
+	require.NoError(t, err)
+	current := StateFromReport(&api.HubJobReconciliationReport{Devices: map[string]api.HubJobReconciliationDeviceReport{
+		"dev:1": {
+			Info: &api.GetDeviceResponse{UID: "dev:1", FleetUIDs: []string{"fleet:a", "fleet:all"}},
+			Vars: &api.GetDeviceEnvironmentVariablesResponse{EnvironmentVariables: map[string]string{"interval": "30", "legacy": "x"}},
+		},
+		"dev:2": {
+			Info: &api.GetDeviceResponse{UID: "dev:2", FleetUIDs: []string{"fleet:all"}},
+			Vars: &api.GetDeviceEnvironmentVariablesResponse{EnvironmentVariables: map[string]string{"interval": "60"}},
+		},
+	}})
+
+	job, diff, err := Plan(desired, current)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev:1", "dev:3"}, job.Select.Devices)
+	req := job.DeviceRequests["dev:1"]
+	require.Equal(t, "gateway-1", req.SnToSet)
+	require.Equal(t, []string{"fleet:b"}, req.FleetsToJoin)
+	require.Equal(t, []string{"fleet:a"}, req.FleetsToLeave)
+	require.Equal(t, map[string]string{"interval": "60", "legacy": "-"}, req.VarsToSet)
+	require.Equal(t, "product:com.example:sensor", job.DeviceRequests["dev:3"].ProvisionProductUID)
+	require.Equal(t, `dev:1
+  + sn "gateway-1"
+  + fleet "fleet:b"
+  - fleet "fleet:a"
+  ~ var interval "30" -> "60"
+  - var legacy "x"
+dev:3
+  + provisioned "product:com.example:sensor"
+  + fleet "fleet:all"
+  + var interval "60"
+8 changes to 2 devices
+`, diff.String())
+
+	// Nothing changes once the devices are in their desired state
+	same, err := ParseDesiredState([]byte(`{"devices":{"dev:2":{"enabled":true,"vars":{"interval":"60"}}}}`))
+	require.NoError(t, err)
+	_, diff, err = Plan(same, current)
+	require.NoError(t, err)
+	require.Empty(t, diff)
+
+	_, _, err = Plan(DesiredState{Devices: map[string]DesiredDevice{"dev:9": {}}}, current)
+	require.True(t, note.ErrorContains(err, note.ErrDeviceNotFound))
+
+	// A removal is submitted as the value that Notehub clears, which defaults don't have
+	clear, err := ParseDesiredState([]byte(`{"devices":{"dev:4":{"sn":"-"}}}`))
+	require.NoError(t, err)
+	job4, diff, err := Plan(clear, State{"dev:4": DeviceState{SerialNumber: "old"}})
+	require.NoError(t, err)
+	require.Equal(t, ClearValue, job4.DeviceRequests["dev:4"].SnToSet)
+	require.Equal(t, Diff{{DeviceUID: "dev:4", Field: "sn", From: "old"}}, diff)
+	clear, err = ParseDesiredState([]byte(`{"devices":{"dev:2":{"default_vars":{"interval":"-"}}}}`))
+	require.NoError(t, err)
+	_, _, err = Plan(clear, current)
+	require.True(t, note.ErrorContains(err, note.ErrSyntax))
+
+	bad := job
+	bad.DeviceRequests = map[string]api.HubJobReconciliationRequests{
+		"dev:1": {Enable: true, Disable: true, FleetsToJoin: []string{"fleet:a"}, FleetsToLeave: []string{"fleet:a"}},
+		"dev:7": {},
+	}
+	err = Validate(bad)
+	require.True(t, note.ErrorContains(err, note.ErrSyntax))
+	require.Contains(t, err.Error(), "both enable and disable")
+	require.Contains(t, err.Error(), "both join and leave fleet:a")
+	require.Contains(t, err.Error(), "dev:7 has requests but is not selected")
+
+	var submitted []notehub.HubRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req notehub.HubRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		submitted = append(submitted, req)
+		json.NewEncoder(w).Encode(notehub.HubRequest{})
+	}))
+	defer server.Close()
+	_, err = DryRun(notehub.NewClient(server.URL, "tok"), "app:1", job)
+	require.NoError(t, err)
+	require.Len(t, submitted, 2)
+	require.Equal(t, notehub.HubAppJobPut, submitted[0].Req)
+	require.Equal(t, notehub.HubAppJobSubmit, submitted[1].Req)
+	require.True(t, submitted[1].DryRun)
+	require.Equal(t, "rollout", submitted[1].Name)
+}
@@ -0,0 +1,196 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jobs
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/blues/note-go/notehub/api"
+)
+
+// DeviceResult is what a run of a job did to a device
+type DeviceResult struct {
+	DeviceUID string
+	Action    string
+	Error     string
+}
+
+// Failed returns true if the job failed on the device
+func (r DeviceResult) Failed() bool {
+	return r.Error != ""
+}
+
+// Summary summarizes a report of a run of a job
+type Summary struct {
+	JobName     string
+	JobID       string
+	Status      string
+	DryRun      bool
+	SubmittedBy string
+	Submitted   time.Time
+	Completed   time.Time
+	// Error is an error that affected the run as a whole
+	Error       string
+	DeviceCount int
+	Provisioned []string
+	// Devices are the results of the devices with an action or an error, ordered by UID
+	Devices   []DeviceResult
+	Succeeded int
+	Failed    int
+}
+
+// Summarize reassembles the per-device errors and actions of a report into a summary
+func Summarize(report api.HubReportReconciliation) (s Summary) {
+	s = Summary{
+		JobName:     report.Header.JobName,
+		JobID:       report.Header.JobId,
+		Status:      report.Header.Status,
+		DryRun:      report.Header.DryRun,
+		SubmittedBy: report.Header.SubmittedBy,
+		Error:       report.Status.Error,
+		DeviceCount: report.Status.DeviceCount,
+		Provisioned: append([]string{}, report.Status.Provisioned...),
+	}
+	if report.Header.Submitted != 0 {
+		s.Submitted = time.Unix(report.Header.Submitted, 0).UTC()
+	}
+	if report.Header.Completed != 0 {
+		s.Completed = time.Unix(report.Header.Completed, 0).UTC()
+	}
+
+	deviceUIDs := map[string]bool{}
+	for deviceUID := range report.Status.Actions {
+		deviceUIDs[deviceUID] = true
+	}
+	for deviceUID := range report.Status.Errors {
+		deviceUIDs[deviceUID] = true
+	}
+	for _, deviceUID := range sortedKeys(deviceUIDs) {
+		result := DeviceResult{
+			DeviceUID: deviceUID,
+			Action:    report.Status.Actions[deviceUID],
+			Error:     report.Status.Errors[deviceUID],
+		}
+		if result.Failed() {
+			s.Failed++
+		} else {
+			s.Succeeded++
+		}
+		s.Devices = append(s.Devices, result)
+	}
+	return
+}
+
+// WriteTable writes the summary as a table for display
+func (s Summary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	mode := ""
+	if s.DryRun {
+		mode = " (dry run)"
+	}
+	fmt.Fprintf(tw, "job %s%s: %s\n", s.JobName, mode, s.Status)
+	if s.Error != "" {
+		fmt.Fprintf(tw, "error: %s\n", s.Error)
+	}
+	fmt.Fprintf(tw, "%d devices, %d succeeded, %d failed\n", s.DeviceCount, s.Succeeded, s.Failed)
+	if len(s.Devices) > 0 {
+		fmt.Fprintf(tw, "\nDEVICE\tRESULT\tDETAIL\n")
+		for _, d := range s.Devices {
+			if d.Failed() {
+				fmt.Fprintf(tw, "%s\tfailed\t%s\n", d.DeviceUID, d.Error)
+			} else {
+				fmt.Fprintf(tw, "%s\tok\t%s\n", d.DeviceUID, d.Action)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes the results of the devices as CSV, with a header row
+func (s Summary) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"job", "job_id", "dry_run", "device", "result", "action", "error"})
+	for _, d := range s.Devices {
+		result := "ok"
+		if d.Failed() {
+			result = "failed"
+		}
+		cw.Write([]string{s.JobName, s.JobID, fmt.Sprint(s.DryRun), d.DeviceUID, result, d.Action, d.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// junitTestSuite is the root of a JUnit XML report
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Time      string          `xml:"time,attr,omitempty"`
+	Error     *junitFailure   `xml:"error,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the summary as a JUnit-style XML test suite, in which each device is
+// a test case that fails if the job failed on the device
+func (s Summary) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:     s.JobName,
+		Tests:    len(s.Devices),
+		Failures: s.Failed,
+	}
+	if s.DryRun {
+		suite.Name += " (dry run)"
+	}
+	if !s.Submitted.IsZero() {
+		suite.Timestamp = s.Submitted.Format(time.RFC3339)
+		if !s.Completed.IsZero() {
+			suite.Time = fmt.Sprintf("%.0f", s.Completed.Sub(s.Submitted).Seconds())
+		}
+	}
+	if s.Error != "" {
+		suite.Errors = 1
+		suite.Error = &junitFailure{Message: s.Error}
+	}
+	for _, d := range s.Devices {
+		tc := junitTestCase{Name: d.DeviceUID, ClassName: "job." + strings.ReplaceAll(s.JobName, " ", "_"), SystemOut: d.Action}
+		if d.Failed() {
+			tc.Failure = &junitFailure{Message: d.Error, Text: d.Error}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(suite)
+	if err == nil {
+		_, err = io.WriteString(w, "\n")
+	}
+	return err
+}
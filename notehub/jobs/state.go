@@ -0,0 +1,183 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package jobs authors, plans and runs Notehub jobs.  A reconciliation job is planned by
+// comparing a declarative desired state of devices with their current state, so that
+// the job requests only the changes needed to bring the devices into line.
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub/api"
+	"gopkg.in/yaml.v3"
+)
+
+// DesiredState is the state in which devices should be, as read from a YAML or JSON file
+type DesiredState struct {
+	// Name is the name of the job that reconciles the devices with this state
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	// Defaults apply to every device, unless overridden by the device
+	Defaults DesiredDevice `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	// Devices are keyed by device UID
+	Devices map[string]DesiredDevice `json:"devices,omitempty" yaml:"devices,omitempty"`
+}
+
+// DesiredDevice is the state in which a device should be.  Only what is specified is
+// reconciled; anything left unspecified is left as it is.
+type DesiredDevice struct {
+	// Provision is the product to which the device is provisioned if it isn't already
+	Provision string `json:"provision,omitempty" yaml:"provision,omitempty"`
+	// SerialNumber is the serial number, or "-" for none
+	SerialNumber *string `json:"sn,omitempty" yaml:"sn,omitempty"`
+	Enabled      *bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// ConnectivityAssurance is whether connectivity assurance is enabled
+	ConnectivityAssurance *bool `json:"connectivity_assurance,omitempty" yaml:"connectivity_assurance,omitempty"`
+	// Fleets are the only fleets of which the device should be a member
+	Fleets *[]string `json:"fleets,omitempty" yaml:"fleets,omitempty"`
+	// JoinFleets and LeaveFleets change fleet membership without affecting other fleets
+	JoinFleets  []string `json:"join_fleets,omitempty" yaml:"join_fleets,omitempty"`
+	LeaveFleets []string `json:"leave_fleets,omitempty" yaml:"leave_fleets,omitempty"`
+	// Vars are environment variables, whose value is "-" if they should not be set
+	Vars map[string]string `json:"vars,omitempty" yaml:"vars,omitempty"`
+	// DefaultVars are environment variable defaults, as if set by the device itself,
+	// which can't be "-" because defaults can't be cleared by a job
+	DefaultVars map[string]string `json:"default_vars,omitempty" yaml:"default_vars,omitempty"`
+}
+
+// ParseDesiredState parses a desired state in YAML or JSON
+func ParseDesiredState(data []byte) (state DesiredState, err error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		err = note.JSONUnmarshal(trimmed, &state)
+	} else {
+		err = yaml.Unmarshal(data, &state)
+	}
+	if err != nil {
+		return state, fmt.Errorf("cannot parse desired state: %w %s", err, note.ErrSyntax)
+	}
+	return
+}
+
+// LoadDesiredState reads a desired state from a YAML or JSON file
+func LoadDesiredState(path string) (state DesiredState, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	return ParseDesiredState(data)
+}
+
+// device returns the desired state of a device, with the defaults applied
+func (s DesiredState) device(deviceUID string) (d DesiredDevice) {
+	d = s.Defaults
+	override := s.Devices[deviceUID]
+	if override.Provision != "" {
+		d.Provision = override.Provision
+	}
+	if override.SerialNumber != nil {
+		d.SerialNumber = override.SerialNumber
+	}
+	if override.Enabled != nil {
+		d.Enabled = override.Enabled
+	}
+	if override.ConnectivityAssurance != nil {
+		d.ConnectivityAssurance = override.ConnectivityAssurance
+	}
+	if override.Fleets != nil {
+		d.Fleets = override.Fleets
+	}
+	d.JoinFleets = append(append([]string{}, d.JoinFleets...), override.JoinFleets...)
+	d.LeaveFleets = append(append([]string{}, d.LeaveFleets...), override.LeaveFleets...)
+	d.Vars = mergeVars(d.Vars, override.Vars)
+	d.DefaultVars = mergeVars(d.DefaultVars, override.DefaultVars)
+	return
+}
+
+func mergeVars(base map[string]string, override map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// DeviceState is the current state of a device
+type DeviceState struct {
+	UID          string
+	SerialNumber string
+	Disabled     bool
+	// ConnectivityAssurance is nil if it isn't known
+	ConnectivityAssurance *bool
+	Fleets                []string
+	Vars                  map[string]string
+	DefaultVars           map[string]string
+}
+
+// State is the current state of devices, keyed by device UID.  Devices that are absent
+// are taken not to be provisioned.
+type State map[string]DeviceState
+
+// StateFromReport returns the state of the devices in the report of a reconciliation job
+// run with the DeviceInfo and DeviceVars report options
+func StateFromReport(report *api.HubJobReconciliationReport) State {
+	state := State{}
+	if report == nil {
+		return state
+	}
+	for deviceUID, device := range report.Devices {
+		d := DeviceState{UID: deviceUID, Vars: map[string]string{}, DefaultVars: map[string]string{}}
+		if device.Info != nil {
+			d.SerialNumber = device.Info.SerialNumber
+			d.Disabled = device.Info.Disabled
+			d.Fleets = append(d.Fleets, device.Info.FleetUIDs...)
+		}
+		if device.Vars != nil {
+			d.Vars = mergeVars(nil, device.Vars.EnvironmentVariables)
+			d.DefaultVars = mergeVars(nil, device.Vars.EnvironmentVariablesEnvDefault)
+		}
+		state[deviceUID] = d
+	}
+	return state
+}
+
+// StateFromAPI returns the current state of devices of a project, fetched from the API.
+// Devices that don't exist are omitted.
+func StateFromAPI(ctx context.Context, client *api.Client, projectUID string, deviceUIDs []string) (state State, err error) {
+	state = State{}
+	report := &api.HubJobReconciliationReport{Devices: map[string]api.HubJobReconciliationDeviceReport{}}
+	for _, deviceUID := range deviceUIDs {
+		info, err := client.GetDevice(ctx, projectUID, deviceUID)
+		if api.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		vars, err := client.GetDeviceEnvironmentVariables(ctx, projectUID, deviceUID)
+		if err != nil {
+			return nil, err
+		}
+		report.Devices[deviceUID] = api.HubJobReconciliationDeviceReport{Info: &info, Vars: &vars}
+	}
+	return StateFromReport(report), nil
+}
+
+// DeviceUIDs returns the UIDs of the devices of the desired state, sorted
+func (s DesiredState) DeviceUIDs() (uids []string) {
+	for uid := range s.Devices {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return
+}
@@ -0,0 +1,304 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package notehub
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/blues/note-go/notehub/api"
+)
+
+// oauthClientID is the OAuth client used by the CLI flows
+const oauthClientID = "notehub_cli"
+
+// deviceCodeGrantType is the grant type of the OAuth device authorization flow (RFC 8628)
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// notehubHosts returns the API host and UI host for a Notehub, given either.  The
+// OAuth endpoints are served by the UI host.
+func notehubHosts(host string) (apiHost string, uiHost string) {
+	apiHost = host
+	if !strings.HasPrefix(apiHost, "api.") {
+		apiHost = "api." + apiHost
+	}
+	if apiHost == DefaultAPIService {
+		return apiHost, "notehub.io"
+	}
+	return apiHost, strings.TrimPrefix(apiHost, "api.")
+}
+
+// tokenResponse is the response of the OAuth token and device authorization endpoints
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+
+	// Device authorization
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	Interval                int64  `json:"interval"`
+}
+
+// err returns the OAuth error in the response, if any
+func (rsp tokenResponse) err() error {
+	if rsp.Error == "" {
+		return nil
+	}
+	if rsp.ErrorDescription != "" {
+		return fmt.Errorf("%s: %s %s", rsp.Error, rsp.ErrorDescription, note.ErrAuth)
+	}
+	return fmt.Errorf("%s %s", rsp.Error, note.ErrAuth)
+}
+
+// postForm posts a form to an OAuth endpoint, optionally authenticating as a client
+func postForm(ctx context.Context, client *http.Client, endpoint string, form url.Values, clientID string, clientSecret string) (rsp tokenResponse, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return rsp, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+	httpRsp, err := client.Do(req)
+	if err != nil {
+		return rsp, fmt.Errorf("making request: %w", err)
+	}
+	defer httpRsp.Body.Close()
+	body, err := ioutil.ReadAll(httpRsp.Body)
+	if err != nil {
+		return rsp, fmt.Errorf("reading response from %s: %w", endpoint, err)
+	}
+	err = note.JSONUnmarshal(body, &rsp)
+	if err != nil {
+		return rsp, fmt.Errorf("unexpected response from %s (%d): %s", endpoint, httpRsp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if rsp.Error == "" && httpRsp.StatusCode >= 500 {
+		// Not a rejection of the request, so not an authentication error
+		return rsp, fmt.Errorf("%s returned %s %s", endpoint, httpRsp.Status, note.ErrNetwork)
+	}
+	if rsp.Error == "" && (httpRsp.StatusCode < 200 || httpRsp.StatusCode > 299) {
+		rsp.Error = http.StatusText(httpRsp.StatusCode)
+	}
+	return rsp, nil
+}
+
+// fetchEmail returns the email address of the user to whom an access token was issued
+func fetchEmail(ctx context.Context, client *http.Client, apiBaseURL string, accessToken string) (email string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiBaseURL+"/userinfo", nil)
+	if err != nil {
+		return "", fmt.Errorf("could not create request for /userinfo: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rsp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not get userinfo: %w", err)
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return "", fmt.Errorf("could not read body from /userinfo: %w", err)
+	}
+	var userinfo map[string]interface{}
+	err = note.JSONUnmarshal(body, &userinfo)
+	if err != nil {
+		return "", fmt.Errorf("could not unmarshal body from /userinfo: %w", err)
+	}
+	email, ok := userinfo["email"].(string)
+	if !ok {
+		return "", fmt.Errorf("could not retrieve email")
+	}
+	return email, nil
+}
+
+// newAccessToken builds an access token from a token response
+func newAccessToken(apiHost string, email string, rsp tokenResponse) *AccessToken {
+	token := &AccessToken{
+		Host:         apiHost,
+		Email:        email,
+		AccessToken:  rsp.AccessToken,
+		RefreshToken: rsp.RefreshToken,
+	}
+	if rsp.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(rsp.ExpiresIn) * time.Second)
+	}
+	return token
+}
+
+// RefreshAccessToken exchanges the refresh token of an access token for a new access
+// token, which retains the refresh token if the server doesn't issue a new one
+func RefreshAccessToken(token *AccessToken) (*AccessToken, error) {
+	_, uiHost := notehubHosts(token.Host)
+	return refreshAccessToken(context.Background(), "https://"+uiHost, token)
+}
+
+func refreshAccessToken(ctx context.Context, uiBaseURL string, token *AccessToken) (*AccessToken, error) {
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("token cannot be refreshed %s", note.ErrAuth)
+	}
+	rsp, err := postForm(ctx, http.DefaultClient, uiBaseURL+"/oauth2/token", url.Values{
+		"client_id":     {oauthClientID},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	}, "", "")
+	if err == nil {
+		err = rsp.err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("refreshing token: %w", err)
+	}
+	refreshed := newAccessToken(token.Host, token.Email, rsp)
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	return refreshed, nil
+}
+
+// DeviceAuthorization is what the user must do to complete a device login: visit the
+// verification URI, on any device with a browser, and enter the user code
+type DeviceAuthorization struct {
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresAt               time.Time
+}
+
+// DeviceLoginOptions configures the device login flow.  The zero value selects the
+// behavior of InitiateDeviceLogin.
+type DeviceLoginOptions struct {
+	// OfflineAccess requests a refresh token along with the access token, so that the
+	// token can be refreshed with RefreshAccessToken rather than by signing in again
+	OfflineAccess bool
+}
+
+// InitiateDeviceLogin signs in without a browser on this machine, using the OAuth
+// device authorization flow.  The prompt function is called to tell the user where to
+// go and what code to enter, after which the Notehub is polled until the user approves
+// or denies the request, the code expires, or the context is cancelled.  Like the
+// browser login by default, it requests the same scope as the original CLI login and
+// not offline access.
+func InitiateDeviceLogin(ctx context.Context, notehubApiHost string, prompt func(DeviceAuthorization)) (*AccessToken, error) {
+	return DeviceLogin(ctx, notehubApiHost, DeviceLoginOptions{}, prompt)
+}
+
+// DeviceLogin runs the OAuth device authorization flow like InitiateDeviceLogin, with
+// options such as requesting offline access
+func DeviceLogin(ctx context.Context, notehubApiHost string, opts DeviceLoginOptions, prompt func(DeviceAuthorization)) (*AccessToken, error) {
+	apiHost, uiHost := notehubHosts(notehubApiHost)
+	return deviceLogin(ctx, apiHost, "https://"+apiHost, "https://"+uiHost, opts, prompt)
+}
+
+func deviceLogin(ctx context.Context, apiHost string, apiBaseURL string, uiBaseURL string, opts DeviceLoginOptions, prompt func(DeviceAuthorization)) (*AccessToken, error) {
+	scope := "openid email"
+	if opts.OfflineAccess {
+		scope += " offline_access"
+	}
+	auth, err := postForm(ctx, http.DefaultClient, uiBaseURL+"/oauth2/device/auth", url.Values{
+		"client_id": {oauthClientID},
+		"scope":     {scope},
+	}, "", "")
+	if err == nil {
+		err = auth.err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("requesting device authorization: %w", err)
+	}
+	if auth.DeviceCode == "" {
+		return nil, fmt.Errorf("no device code returned")
+	}
+
+	expiresAt := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	if auth.ExpiresIn <= 0 {
+		expiresAt = time.Now().Add(10 * time.Minute)
+	}
+	prompt(DeviceAuthorization{
+		UserCode:                auth.UserCode,
+		VerificationURI:         auth.VerificationURI,
+		VerificationURIComplete: auth.VerificationURIComplete,
+		ExpiresAt:               expiresAt,
+	})
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		if time.Now().After(expiresAt) {
+			return nil, fmt.Errorf("device authorization expired %s", note.ErrAuth)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		rsp, err := postForm(ctx, http.DefaultClient, uiBaseURL+"/oauth2/token", url.Values{
+			"client_id":   {oauthClientID},
+			"grant_type":  {deviceCodeGrantType},
+			"device_code": {auth.DeviceCode},
+		}, "", "")
+		if err != nil {
+			return nil, err
+		}
+		switch rsp.Error {
+		case "":
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += 5 * time.Second
+			continue
+		default:
+			return nil, rsp.err()
+		}
+		if rsp.AccessToken == "" {
+			return nil, fmt.Errorf("unexpected error: no access token returned")
+		}
+
+		email, err := fetchEmail(ctx, http.DefaultClient, apiBaseURL, rsp.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		return newAccessToken(apiHost, email, rsp), nil
+	}
+}
+
+// ClientCredentialsLogin obtains an access token for a client app generated for a
+// project, using the OAuth client credentials flow, which requires no user at all.
+// The token's Email is the client ID, under which it may be kept in a TokenStore.
+// Tokens issued this way can't be refreshed; log in again when they expire.
+func ClientCredentialsLogin(ctx context.Context, notehubApiHost string, credentials api.GenerateClientAppResponse) (*AccessToken, error) {
+	apiHost, uiHost := notehubHosts(notehubApiHost)
+	return clientCredentialsLogin(ctx, apiHost, "https://"+uiHost, credentials)
+}
+
+func clientCredentialsLogin(ctx context.Context, apiHost string, uiBaseURL string, credentials api.GenerateClientAppResponse) (*AccessToken, error) {
+	if credentials.ClientID == "" || credentials.ClientSecret == "" {
+		return nil, fmt.Errorf("client ID and secret are required %s", note.ErrAuth)
+	}
+	rsp, err := postForm(ctx, http.DefaultClient, uiBaseURL+"/oauth2/token", url.Values{
+		"grant_type": {"client_credentials"},
+	}, credentials.ClientID, credentials.ClientSecret)
+	if err == nil {
+		err = rsp.err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("client credentials login: %w", err)
+	}
+	if rsp.AccessToken == "" {
+		return nil, fmt.Errorf("unexpected error: no access token returned")
+	}
+	return newAccessToken(apiHost, credentials.ClientID, rsp), nil
+}
@@ -0,0 +1,436 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package notehub
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+)
+
+// DbQueryFormatJSON returns results as a JSON array of objects
+const DbQueryFormatJSON = "json"
+
+// DbQueryFormatCSV returns results as comma-separated values, with a header row unless
+// NoHeader is set
+const DbQueryFormatCSV = "csv"
+
+// queryOperators are the comparison operators that may be used in a Where clause
+var queryOperators = map[string]bool{
+	"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "NOT LIKE": true, "IS": true, "IS NOT": true,
+}
+
+// queryPathElement is an element of a path within a column that holds an object
+var queryPathElement = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// queryColumns maps the column names of a record, which are its JSON field names, to
+// the types of their fields
+func queryColumns(record interface{}) map[string]reflect.Type {
+	columns := map[string]reflect.Type{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				walk(field.Type)
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			columns[name] = field.Type
+		}
+	}
+	walk(reflect.TypeOf(record))
+	return columns
+}
+
+var eventColumns = queryColumns(note.Event{})
+var sessionColumns = queryColumns(note.DeviceSession{})
+
+// QueryBuilder assembles a DbQuery whose columns are checked against the fields of the
+// records being queried and whose literals are quoted.  The first error encountered is
+// remembered and returned by Build, so that calls may be chained.
+type QueryBuilder struct {
+	columns map[string]reflect.Type
+	query   DbQuery
+	where   []string
+	err     error
+}
+
+// NewEventQuery returns a builder of queries of events
+func NewEventQuery() *QueryBuilder {
+	return &QueryBuilder{columns: eventColumns}
+}
+
+// NewSessionQuery returns a builder of queries of device sessions
+func NewSessionQuery() *QueryBuilder {
+	return &QueryBuilder{columns: sessionColumns}
+}
+
+// column validates a column name, which may be a field of a record or a path within a
+// field that holds an object, such as body.temp
+func (b *QueryBuilder) column(name string) bool {
+	if b.err != nil {
+		return false
+	}
+	path := strings.Split(name, ".")
+	t, present := b.columns[path[0]]
+	if !present {
+		b.err = fmt.Errorf("unknown column: %s %s", name, note.ErrSyntax)
+		return false
+	}
+	if len(path) > 1 {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Map {
+			b.err = fmt.Errorf("column %s has no fields: %s %s", path[0], name, note.ErrSyntax)
+			return false
+		}
+		for _, element := range path[1:] {
+			if !queryPathElement.MatchString(element) {
+				b.err = fmt.Errorf("invalid field of column %s: %s %s", path[0], name, note.ErrSyntax)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Columns selects the columns to be returned, which are otherwise all columns
+func (b *QueryBuilder) Columns(names ...string) *QueryBuilder {
+	for _, name := range names {
+		if !b.column(name) {
+			return b
+		}
+	}
+	b.query.Columns = strings.Join(names, ",")
+	return b
+}
+
+// Where adds a condition that a column compares with a value using an operator such
+// as "=" or "LIKE".  Conditions are combined with AND.
+func (b *QueryBuilder) Where(column string, op string, value interface{}) *QueryBuilder {
+	if !b.column(column) {
+		return b
+	}
+	op = strings.ToUpper(strings.Join(strings.Fields(op), " "))
+	if !queryOperators[op] {
+		b.err = fmt.Errorf("invalid operator: %s %s", op, note.ErrSyntax)
+		return b
+	}
+	literal, err := QueryLiteral(value)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.where = append(b.where, column+" "+op+" "+literal)
+	return b
+}
+
+// WhereIn adds a condition that a column is equal to any of a set of values
+func (b *QueryBuilder) WhereIn(column string, values ...interface{}) *QueryBuilder {
+	if !b.column(column) {
+		return b
+	}
+	if len(values) == 0 {
+		b.err = fmt.Errorf("no values for column %s %s", column, note.ErrSyntax)
+		return b
+	}
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literal, err := QueryLiteral(value)
+		if err != nil {
+			b.err = err
+			return b
+		}
+		literals[i] = literal
+	}
+	b.where = append(b.where, column+" IN ("+strings.Join(literals, ",")+")")
+	return b
+}
+
+// OrderBy orders the results by a column
+func (b *QueryBuilder) OrderBy(column string, descending bool) *QueryBuilder {
+	if b.column(column) {
+		b.query.Order = column
+		b.query.Descending = descending
+	}
+	return b
+}
+
+// Limit sets the maximum number of results returned
+func (b *QueryBuilder) Limit(limit int) *QueryBuilder {
+	if limit < 0 && b.err == nil {
+		b.err = fmt.Errorf("invalid limit: %d %s", limit, note.ErrSyntax)
+	}
+	b.query.Limit = limit
+	return b
+}
+
+// Offset sets the number of results skipped
+func (b *QueryBuilder) Offset(offset int) *QueryBuilder {
+	if offset < 0 && b.err == nil {
+		b.err = fmt.Errorf("invalid offset: %d %s", offset, note.ErrSyntax)
+	}
+	b.query.Offset = offset
+	return b
+}
+
+// After returns only the results that follow the result with the specified value of the
+// order column, which pages through results more reliably than Offset when results are
+// being added concurrently
+func (b *QueryBuilder) After(last string) *QueryBuilder {
+	b.query.Last = last
+	return b
+}
+
+// Format sets the format of the results, DbQueryFormatJSON or DbQueryFormatCSV
+func (b *QueryBuilder) Format(format string) *QueryBuilder {
+	if format != DbQueryFormatJSON && format != DbQueryFormatCSV && b.err == nil {
+		b.err = fmt.Errorf("invalid format: %s %s", format, note.ErrSyntax)
+	}
+	b.query.Format = format
+	return b
+}
+
+// NoHeader omits the header row of CSV results
+func (b *QueryBuilder) NoHeader() *QueryBuilder {
+	b.query.NoHeader = true
+	return b
+}
+
+// Count returns the number of results rather than the results themselves
+func (b *QueryBuilder) Count() *QueryBuilder {
+	b.query.Count = true
+	return b
+}
+
+// Build returns the query, or the first error encountered while building it
+func (b *QueryBuilder) Build() (query DbQuery, err error) {
+	if b.err != nil {
+		return DbQuery{}, b.err
+	}
+	query = b.query
+	query.Where = strings.Join(b.where, " AND ")
+	return query, nil
+}
+
+// QueryLiteral returns a value as a literal that may be used in a Where clause.  Strings
+// are quoted with single quotes, which are escaped by doubling them.
+func QueryLiteral(value interface{}) (literal string, err error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("unsupported literal %v %s", v, note.ErrSyntax)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		// Times are stored as epoch seconds
+		return strconv.FormatInt(v.Unix(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported literal type %T %s", value, note.ErrSyntax)
+}
+
+// NextPage returns the query for the page that follows a page of results of this query,
+// given the number of results returned and, if paging with After, the value of the order
+// column of the last result.  No query is returned once a page isn't full.
+func (q DbQuery) NextPage(returned int, last string) (next DbQuery, more bool) {
+	if q.Limit <= 0 || returned < q.Limit {
+		return DbQuery{}, false
+	}
+	next = q
+	if last != "" {
+		next.Last = last
+	} else {
+		next.Offset += returned
+	}
+	return next, true
+}
+
+// DecodeEvents decodes the results of a query of events in the format of the query
+func (q DbQuery) DecodeEvents(data []byte) (events []note.Event, err error) {
+	err = q.decode(data, eventColumns, &events)
+	return
+}
+
+// DecodeSessions decodes the results of a query of device sessions in the format of the query
+func (q DbQuery) DecodeSessions(data []byte) (sessions []note.DeviceSession, err error) {
+	err = q.decode(data, sessionColumns, &sessions)
+	return
+}
+
+// decode decodes results into a pointer to a slice of records
+func (q DbQuery) decode(data []byte, columns map[string]reflect.Type, records interface{}) (err error) {
+	switch q.Format {
+	case "", DbQueryFormatJSON:
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			return nil
+		}
+		if data[0] != '[' {
+			// Rows may also be returned as newline-delimited objects
+			data = append(append([]byte("["), bytes.ReplaceAll(bytes.TrimSpace(data), []byte("}\n{"), []byte("},{"))...), ']')
+		}
+		err = note.JSONUnmarshal(data, records)
+		if err != nil {
+			return fmt.Errorf("cannot decode results: %w", err)
+		}
+		return nil
+	case DbQueryFormatCSV:
+		var rows []map[string]interface{}
+		rows, err = q.decodeCSV(data, columns)
+		if err != nil {
+			return
+		}
+		var rowsJSON []byte
+		rowsJSON, err = note.JSONMarshal(rows)
+		if err != nil {
+			return
+		}
+		err = note.JSONUnmarshal(rowsJSON, records)
+		if err != nil {
+			return fmt.Errorf("cannot decode results: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot decode results in format: %s %s", q.Format, note.ErrSyntax)
+}
+
+// decodeCSV converts CSV rows to objects whose values have the types of the fields of
+// the records.  Columns are named by the header row, or by the query's columns if there
+// is no header.
+func (q DbQuery) decodeCSV(data []byte, columns map[string]reflect.Type) (rows []map[string]interface{}, err error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	var header []string
+	if q.NoHeader {
+		if q.Columns == "" {
+			return nil, fmt.Errorf("columns must be specified to decode results without a header %s", note.ErrSyntax)
+		}
+		header = strings.Split(q.Columns, ",")
+	}
+	for {
+		var record []string
+		record, err = r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode results: %w", err)
+		}
+		if header == nil {
+			header = record
+			continue
+		}
+		row := map[string]interface{}{}
+		for i, value := range record {
+			if i >= len(header) || value == "" {
+				continue
+			}
+			path := strings.Split(strings.TrimSpace(header[i]), ".")
+			t, present := columns[path[0]]
+			if !present {
+				continue
+			}
+			if len(path) > 1 {
+				setPath(row, path, csvValue(value))
+				continue
+			}
+			row[path[0]], err = csvFieldValue(value, t)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", path[0], err)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// setPath sets a value at a path of nested objects, creating them as needed
+func setPath(object map[string]interface{}, path []string, value interface{}) {
+	for _, element := range path[:len(path)-1] {
+		child, ok := object[element].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			object[element] = child
+		}
+		object = child
+	}
+	object[path[len(path)-1]] = value
+}
+
+// csvFieldValue converts a CSV value to the JSON value of a field of the specified type
+func csvFieldValue(value string, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(value), nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are base64-encoded, as in JSON
+			return value, nil
+		}
+	}
+	// Objects and arrays are embedded as JSON
+	var object interface{}
+	err := note.JSONUnmarshal([]byte(value), &object)
+	if err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// csvValue converts a CSV value of a field of an object, whose type isn't known, to the
+// number or boolean that it looks like, or else leaves it as a string
+func csvValue(value string) interface{} {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return json.Number(value)
+	}
+	if value == "true" || value == "false" {
+		return value == "true"
+	}
+	return value
+}
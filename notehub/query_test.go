@@ -0,0 +1,62 @@
+package notehub
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	q, err := NewEventQuery().
+		Columns("event", "device", "when", "body.temp").
+		Where("device", "=", "dev:1' OR 1=1 --").
+		Where("body.temp", ">", 21.5).
+		WhereIn("file", "data.qo", "_health.qo").
+		OrderBy("when", true).
+		Limit(2).
+		Format(DbQueryFormatCSV).
+		Build()
+	require.NoError(t, err)
+	require.Equal(t, "event,device,when,body.temp", q.Columns)
+	require.Equal(t, "device = 'dev:1'' OR 1=1 --' AND body.temp > 21.5 AND file IN ('data.qo','_health.qo')", q.Where)
+	require.True(t, q.Descending)
+
+	_, err = NewEventQuery().Where("device; DROP TABLE events", "=", 1).Build()
+	require.True(t, note.ErrorContains(err, note.ErrSyntax))
+	_, err = NewEventQuery().Where("device", "; DELETE", 1).Build()
+	require.True(t, note.ErrorContains(err, note.ErrSyntax))
+	_, err = NewEventQuery().Columns("when.x").Build()
+	require.True(t, note.ErrorContains(err, note.ErrSyntax))
+	_, err = NewSessionQuery().Columns("session", "bogus").Build()
+	require.True(t, note.ErrorContains(err, note.ErrSyntax))
+
+	events, err := q.DecodeEvents([]byte("event,device,when,body.temp\ne1,dev:1,1700000000,22.5\ne2,dev:1,1700000060,23\n"))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "e2", events[1].EventUID)
+	require.Equal(t, int64(1700000060), events[1].When)
+	require.Equal(t, json.Number("22.5"), (*events[0].Body)["temp"])
+
+	next, more := q.NextPage(len(events), "")
+	require.True(t, more)
+	require.Equal(t, 2, next.Offset)
+	next, more = q.NextPage(len(events), "1700000060")
+	require.True(t, more)
+	require.Equal(t, "1700000060", next.Last)
+	_, more = q.NextPage(1, "")
+	require.False(t, more)
+
+	q.Format = DbQueryFormatJSON
+	events, err = q.DecodeEvents([]byte(`[{"event":"e1","when":1700000000}]`))
+	require.NoError(t, err)
+	require.Equal(t, "e1", events[0].EventUID)
+
+	sq, err := NewSessionQuery().Columns("session", "device").Format(DbQueryFormatCSV).NoHeader().Build()
+	require.NoError(t, err)
+	sessions, err := sq.DecodeSessions([]byte("s1,dev:1\n"))
+	require.NoError(t, err)
+	require.Equal(t, "s1", sessions[0].SessionUID)
+	require.Equal(t, "dev:1", sessions[0].DeviceUID)
+}
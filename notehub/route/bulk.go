@@ -0,0 +1,255 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package route
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blues/note-go/note"
+)
+
+// bulkFileSuffix is appended to the name of the file holding a bulk delivery's pieces
+const bulkFileSuffix = ".bulk.json"
+
+// BulkKey identifies a bulk delivery, whose pieces share a device and receipt time
+type BulkKey struct {
+	DeviceUID string
+	Received  float64
+}
+
+// BulkStore holds the pieces of bulk deliveries until every piece has arrived.  Its
+// methods must be safe to call concurrently.
+type BulkStore interface {
+	// Add stores a piece of a delivery, replacing any stored piece with the same number,
+	// and returns the number of pieces of the delivery now stored.  The time is when
+	// the piece arrived, and the delivery is timed from its first piece.
+	Add(key BulkKey, piece note.Event, at time.Time) (stored int, err error)
+	// Pieces returns the stored pieces of a delivery, in any order
+	Pieces(key BulkKey) (pieces []note.Event, err error)
+	// Remove discards the pieces of a delivery once they have been handled
+	Remove(key BulkKey) error
+	// Expired returns the deliveries whose first piece arrived no later than the cutoff
+	Expired(cutoff time.Time) (keys []BulkKey, err error)
+}
+
+// storedBulk is the form in which the pieces of a delivery are stored
+type storedBulk struct {
+	Started int64                 `json:"started"`
+	Pieces  map[uint32]note.Event `json:"pieces"`
+}
+
+// MemoryBulkStore holds the pieces of bulk deliveries in memory
+type MemoryBulkStore struct {
+	lock    sync.Mutex
+	batches map[BulkKey]*storedBulk
+}
+
+// NewMemoryBulkStore returns an empty BulkStore in memory
+func NewMemoryBulkStore() *MemoryBulkStore {
+	return &MemoryBulkStore{batches: map[BulkKey]*storedBulk{}}
+}
+
+// Add stores a piece of a delivery
+func (m *MemoryBulkStore) Add(key BulkKey, piece note.Event, at time.Time) (stored int, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	b := m.batches[key]
+	if b == nil {
+		b = &storedBulk{Started: at.UnixNano(), Pieces: map[uint32]note.Event{}}
+		m.batches[key] = b
+	}
+	b.Pieces[piece.BulkNumber] = piece
+	return len(b.Pieces), nil
+}
+
+// Pieces returns the stored pieces of a delivery
+func (m *MemoryBulkStore) Pieces(key BulkKey) (pieces []note.Event, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if b := m.batches[key]; b != nil {
+		for _, piece := range b.Pieces {
+			pieces = append(pieces, piece)
+		}
+	}
+	return
+}
+
+// Remove discards the pieces of a delivery
+func (m *MemoryBulkStore) Remove(key BulkKey) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.batches, key)
+	return nil
+}
+
+// Expired returns the deliveries whose first piece arrived no later than the cutoff
+func (m *MemoryBulkStore) Expired(cutoff time.Time) (keys []BulkKey, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for key, b := range m.batches {
+		if b.Started <= cutoff.UnixNano() {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
+
+// Pending returns the number of pieces stored
+func (m *MemoryBulkStore) Pending() (pieces int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, b := range m.batches {
+		pieces += len(b.Pieces)
+	}
+	return
+}
+
+// DirBulkStore holds the pieces of bulk deliveries in a directory, one file per delivery,
+// so that pieces acknowledged to Notehub survive a restart of the receiver
+type DirBulkStore struct {
+	dir  string
+	lock sync.Mutex
+}
+
+// NewDirBulkStore opens (creating if necessary) a BulkStore in a directory
+func NewDirBulkStore(dir string) (store *DirBulkStore, err error) {
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return
+	}
+	return &DirBulkStore{dir: dir}, nil
+}
+
+// path returns the path of the file of a delivery, whose name encodes the device UID so
+// that it is a valid file name
+func (d *DirBulkStore) path(key BulkKey) string {
+	received := strconv.FormatFloat(key.Received, 'f', -1, 64)
+	return filepath.Join(d.dir, hex.EncodeToString([]byte(key.DeviceUID))+"_"+received+bulkFileSuffix)
+}
+
+// keyOf returns the key of a delivery from the name of its file
+func keyOf(name string) (key BulkKey, ok bool) {
+	parts := strings.SplitN(strings.TrimSuffix(name, bulkFileSuffix), "_", 2)
+	if len(parts) != 2 {
+		return
+	}
+	deviceUID, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return
+	}
+	received, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return
+	}
+	return BulkKey{DeviceUID: string(deviceUID), Received: received}, true
+}
+
+func (d *DirBulkStore) read(key BulkKey) (b storedBulk, err error) {
+	contents, err := ioutil.ReadFile(d.path(key))
+	if os.IsNotExist(err) {
+		return storedBulk{Pieces: map[uint32]note.Event{}}, nil
+	}
+	if err != nil {
+		return
+	}
+	err = note.JSONUnmarshal(contents, &b)
+	if err != nil {
+		return b, fmt.Errorf("%s: %w", d.path(key), err)
+	}
+	if b.Pieces == nil {
+		b.Pieces = map[uint32]note.Event{}
+	}
+	return
+}
+
+// Add stores a piece of a delivery
+func (d *DirBulkStore) Add(key BulkKey, piece note.Event, at time.Time) (stored int, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	b, err := d.read(key)
+	if err != nil {
+		return
+	}
+	if len(b.Pieces) == 0 {
+		b.Started = at.UnixNano()
+	}
+	b.Pieces[piece.BulkNumber] = piece
+	contents, err := note.JSONMarshal(b)
+	if err != nil {
+		return
+	}
+	path := d.path(key)
+	temp := path + ".tmp"
+	err = ioutil.WriteFile(temp, contents, 0600)
+	if err != nil {
+		return
+	}
+	err = os.Rename(temp, path)
+	if err != nil {
+		return
+	}
+	return len(b.Pieces), nil
+}
+
+// Pieces returns the stored pieces of a delivery
+func (d *DirBulkStore) Pieces(key BulkKey) (pieces []note.Event, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	b, err := d.read(key)
+	if err != nil {
+		return
+	}
+	for _, piece := range b.Pieces {
+		pieces = append(pieces, piece)
+	}
+	return
+}
+
+// Remove discards the pieces of a delivery
+func (d *DirBulkStore) Remove(key BulkKey) (err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	err = os.Remove(d.path(key))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+// Expired returns the deliveries whose first piece arrived no later than the cutoff
+func (d *DirBulkStore) Expired(cutoff time.Time) (keys []BulkKey, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	entries, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), bulkFileSuffix) {
+			continue
+		}
+		key, ok := keyOf(entry.Name())
+		if !ok {
+			continue
+		}
+		var b storedBulk
+		b, err = d.read(key)
+		if err != nil {
+			return nil, err
+		}
+		if b.Started <= cutoff.UnixNano() {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
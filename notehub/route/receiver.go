@@ -0,0 +1,411 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package route receives events routed by Notehub to an HTTP endpoint.  A Receiver
+// authenticates each delivery, decodes the events in it, reassembles the pieces of bulk
+// deliveries, drops events that it has already handled, and dispatches the rest to the
+// handlers registered for their notefile or request.
+//
+// A delivery is only acknowledged once every event in it has been handled or, for the
+// pieces of a bulk delivery, stored in the receiver's BulkStore.  The pieces are
+// dispatched in order once all of them have arrived, or once BulkTimeout has passed.
+package route
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blues/note-go/note"
+)
+
+// DefaultSecretHeader carries the shared secret configured on the route
+const DefaultSecretHeader = "X-Notehub-Secret"
+
+// DefaultSignatureHeader carries the hex HMAC-SHA256 of the body, optionally prefixed
+// with "sha256="
+const DefaultSignatureHeader = "X-Notehub-Signature"
+
+// DefaultMaxBodyBytes is the size of the largest delivery accepted
+const DefaultMaxBodyBytes = 8 * 1024 * 1024
+
+// DefaultBulkTimeout is how long the pieces of a bulk delivery are held waiting for the
+// rest before those received are dispatched anyway
+const DefaultBulkTimeout = 5 * time.Minute
+
+// DefaultSeenCapacity is the number of event UIDs remembered for deduplication
+const DefaultSeenCapacity = 100000
+
+// Handler handles an event.  If it returns an error, the delivery fails so that Notehub
+// will deliver the event again.
+type Handler func(ctx context.Context, event note.Event) error
+
+// Seen remembers which events have been handled, so that redelivered events are dropped.
+// Its methods must be safe to call concurrently.
+type Seen interface {
+	// TryMark atomically reserves an event for handling, returning false if the event
+	// has been handled or is being handled
+	TryMark(eventUID string) bool
+	// Release releases the reservation of an event that could not be handled, so that
+	// it is handled when it is delivered again
+	Release(eventUID string)
+}
+
+// Receiver is an http.Handler for deliveries of routed events
+type Receiver struct {
+	// Secret, if set, must be presented in SecretHeader or as a bearer token
+	Secret       string
+	SecretHeader string
+
+	// HMACKey, if set, is the key of the HMAC-SHA256 of the body in SignatureHeader
+	HMACKey         []byte
+	SignatureHeader string
+
+	MaxBodyBytes int64
+	BulkTimeout  time.Duration
+
+	// Bulk holds the pieces of incomplete bulk deliveries.  If nil, they are held in
+	// memory and lost if the receiver restarts; a DirBulkStore keeps them on disk.
+	Bulk BulkStore
+
+	// Seen deduplicates events by UID.  If nil, the last DefaultSeenCapacity event UIDs
+	// are remembered in memory.
+	Seen Seen
+
+	lock     sync.Mutex
+	byFile   map[string]Handler
+	byReq    map[string]Handler
+	fallback Handler
+	bulkLock sync.Mutex
+	now      func() time.Time
+}
+
+// NewReceiver returns a receiver that authenticates with a shared secret, if not empty
+func NewReceiver(secret string) *Receiver {
+	return &Receiver{Secret: secret}
+}
+
+// HandleFile registers the handler of events of a notefile, such as "data.qo", which
+// takes precedence over handlers registered by request
+func (rcv *Receiver) HandleFile(notefileID string, handler Handler) {
+	rcv.lock.Lock()
+	defer rcv.lock.Unlock()
+	if rcv.byFile == nil {
+		rcv.byFile = map[string]Handler{}
+	}
+	rcv.byFile[notefileID] = handler
+}
+
+// HandleReq registers the handler of events of a request, such as "note.add" or
+// "session.begin"
+func (rcv *Receiver) HandleReq(req string, handler Handler) {
+	rcv.lock.Lock()
+	defer rcv.lock.Unlock()
+	if rcv.byReq == nil {
+		rcv.byReq = map[string]Handler{}
+	}
+	rcv.byReq[req] = handler
+}
+
+// HandleDefault registers the handler of events that no other handler handles.  Such
+// events are otherwise dropped.
+func (rcv *Receiver) HandleDefault(handler Handler) {
+	rcv.lock.Lock()
+	defer rcv.lock.Unlock()
+	rcv.fallback = handler
+}
+
+// BodyHandler returns a handler that decodes the body of each event into a new value
+// returned by newBody, such as a pointer to a struct, before calling fn with it
+func BodyHandler(newBody func() interface{}, fn func(ctx context.Context, event note.Event, body interface{}) error) Handler {
+	return func(ctx context.Context, event note.Event) error {
+		body := newBody()
+		if event.Body != nil {
+			err := note.BodyToObject(event.Body, body)
+			if err != nil {
+				return fmt.Errorf("cannot decode body of event %s: %w", event.EventUID, err)
+			}
+		}
+		return fn(ctx, event, body)
+	}
+}
+
+// handler returns the handler of an event, if any
+func (rcv *Receiver) handler(event note.Event) Handler {
+	rcv.lock.Lock()
+	defer rcv.lock.Unlock()
+	if h := rcv.byFile[event.NotefileID]; h != nil {
+		return h
+	}
+	if h := rcv.byReq[event.Req]; h != nil {
+		return h
+	}
+	return rcv.fallback
+}
+
+// ServeHTTP handles a delivery of one event or a JSON array of events
+func (rcv *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	maxBytes := rcv.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > maxBytes {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !rcv.authenticate(r, body) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	events, err := DecodeEvents(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = rcv.Deliver(r.Context(), events)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticate checks the secret and signature of a delivery, as configured
+func (rcv *Receiver) authenticate(r *http.Request, body []byte) bool {
+	if rcv.Secret != "" {
+		header := rcv.SecretHeader
+		if header == "" {
+			header = DefaultSecretHeader
+		}
+		presented := r.Header.Get(header)
+		if presented == "" {
+			presented = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(rcv.Secret)) != 1 {
+			return false
+		}
+	}
+	if len(rcv.HMACKey) > 0 {
+		header := rcv.SignatureHeader
+		if header == "" {
+			header = DefaultSignatureHeader
+		}
+		signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get(header), "sha256="))
+		if err != nil {
+			return false
+		}
+		if !hmac.Equal(signature, Sign(rcv.HMACKey, body)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sign returns the HMAC-SHA256 of a body, as presented in the signature header
+func Sign(key []byte, body []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// DecodeEvents decodes one event or a JSON array of events
+func DecodeEvents(body []byte) (events []note.Event, err error) {
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		err = note.JSONUnmarshal([]byte(trimmed), &events)
+	} else {
+		var event note.Event
+		err = note.JSONUnmarshal([]byte(trimmed), &event)
+		events = []note.Event{event}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode events: %w %s", err, note.ErrSyntax)
+	}
+	return
+}
+
+// Deliver handles decoded events in order as if they had been delivered in one request,
+// storing the pieces of bulk deliveries until they are complete and dropping events
+// already handled.  If a handler fails, the events that follow aren't handled, and the
+// event that failed will be handled if it is delivered again.
+func (rcv *Receiver) Deliver(ctx context.Context, events []note.Event) (err error) {
+	store := rcv.bulk()
+	for _, event := range events {
+		if !event.Bulk || event.BulkTotal <= 1 || event.BulkNumber == 0 {
+			err = rcv.dispatch(ctx, event)
+			if err != nil {
+				return
+			}
+			continue
+		}
+		key := BulkKey{DeviceUID: event.DeviceUID, Received: event.BulkReceived}
+		var stored int
+		stored, err = store.Add(key, event, rcv.clock())
+		if err != nil {
+			return fmt.Errorf("storing piece %d of bulk delivery from %s: %w", event.BulkNumber, event.DeviceUID, err)
+		}
+		if uint32(stored) >= event.BulkTotal {
+			err = rcv.dispatchBulk(ctx, key)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	// A failure to handle an expired bulk delivery, which may be from another device,
+	// doesn't fail this delivery; it stays stored and is retried by the next flush
+	_ = rcv.Flush(ctx)
+	return nil
+}
+
+// Flush dispatches the pieces of bulk deliveries that have waited longer than BulkTimeout
+// for the rest, in order.  It is called after each delivery, and may also be called
+// periodically so that pieces aren't held until the next delivery.
+func (rcv *Receiver) Flush(ctx context.Context) (err error) {
+	timeout := rcv.BulkTimeout
+	if timeout <= 0 {
+		timeout = DefaultBulkTimeout
+	}
+	keys, err := rcv.bulk().Expired(rcv.clock().Add(-timeout))
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		err = rcv.dispatchBulk(ctx, key)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// dispatchBulk dispatches the stored pieces of a bulk delivery in order, discarding them
+// once all have been handled.  If a handler fails they remain stored, and those already
+// handled are dropped when they are dispatched again.
+func (rcv *Receiver) dispatchBulk(ctx context.Context, key BulkKey) (err error) {
+	rcv.bulkLock.Lock()
+	defer rcv.bulkLock.Unlock()
+	store := rcv.bulk()
+	pieces, err := store.Pieces(key)
+	if err != nil {
+		return
+	}
+	sort.Slice(pieces, func(i, j int) bool { return pieces[i].BulkNumber < pieces[j].BulkNumber })
+	for _, piece := range pieces {
+		err = rcv.dispatch(ctx, piece)
+		if err != nil {
+			return
+		}
+	}
+	return store.Remove(key)
+}
+
+// dispatch hands an event to its handler unless it has already been handled
+func (rcv *Receiver) dispatch(ctx context.Context, event note.Event) (err error) {
+	seen := rcv.seen()
+	if event.EventUID != "" && !seen.TryMark(event.EventUID) {
+		return nil
+	}
+	h := rcv.handler(event)
+	if h == nil {
+		return nil
+	}
+	err = h(ctx, event)
+	if err != nil {
+		if event.EventUID != "" {
+			seen.Release(event.EventUID)
+		}
+		return fmt.Errorf("event %s: %w", event.EventUID, err)
+	}
+	return nil
+}
+
+func (rcv *Receiver) clock() time.Time {
+	if rcv.now != nil {
+		return rcv.now()
+	}
+	return time.Now()
+}
+
+func (rcv *Receiver) bulk() BulkStore {
+	rcv.lock.Lock()
+	defer rcv.lock.Unlock()
+	if rcv.Bulk == nil {
+		rcv.Bulk = NewMemoryBulkStore()
+	}
+	return rcv.Bulk
+}
+
+func (rcv *Receiver) seen() Seen {
+	rcv.lock.Lock()
+	defer rcv.lock.Unlock()
+	if rcv.Seen == nil {
+		rcv.Seen = NewMemorySeen(DefaultSeenCapacity)
+	}
+	return rcv.Seen
+}
+
+// MemorySeen remembers the most recently handled event UIDs in memory
+type MemorySeen struct {
+	lock     sync.Mutex
+	capacity int
+	uids     map[string]bool
+	order    []string
+}
+
+// NewMemorySeen returns a Seen that remembers up to capacity event UIDs
+func NewMemorySeen(capacity int) *MemorySeen {
+	return &MemorySeen{capacity: capacity, uids: map[string]bool{}}
+}
+
+// TryMark reserves an event for handling unless it has been handled or is being handled,
+// forgetting the oldest event if full
+func (m *MemorySeen) TryMark(eventUID string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.uids[eventUID] {
+		return false
+	}
+	m.uids[eventUID] = true
+	m.order = append(m.order, eventUID)
+	if len(m.order) > m.capacity {
+		delete(m.uids, m.order[0])
+		m.order = m.order[1:]
+	}
+	return true
+}
+
+// Release forgets an event that could not be handled
+func (m *MemorySeen) Release(eventUID string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.uids[eventUID] {
+		return
+	}
+	delete(m.uids, eventUID)
+	for i := len(m.order) - 1; i >= 0; i-- {
+		if m.order[i] == eventUID {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func post(rcv http.Handler, body string, header map[string]string) int {
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	rcv.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestReceiverAuthentication(t *testing.T) {
+	rcv := NewReceiver("s3cret")
+	body := `{"event":"e1","file":"data.qo"}`
+	require.Equal(t, http.StatusUnauthorized, post(rcv, body, nil))
+	require.Equal(t, http.StatusUnauthorized, post(rcv, body, map[string]string{DefaultSecretHeader: "wrong"}))
+	require.Equal(t, http.StatusOK, post(rcv, body, map[string]string{DefaultSecretHeader: "s3cret"}))
+	require.Equal(t, http.StatusOK, post(rcv, body, map[string]string{"Authorization": "Bearer s3cret"}))
+
+	rcv = &Receiver{HMACKey: []byte("key")}
+	signature := hex.EncodeToString(Sign([]byte("key"), []byte(body)))
+	require.Equal(t, http.StatusUnauthorized, post(rcv, body, nil))
+	require.Equal(t, http.StatusUnauthorized, post(rcv, body+" ", map[string]string{DefaultSignatureHeader: signature}))
+	require.Equal(t, http.StatusOK, post(rcv, body, map[string]string{DefaultSignatureHeader: signature}))
+	require.Equal(t, http.StatusOK, post(rcv, body, map[string]string{DefaultSignatureHeader: "sha256=" + signature}))
+
+	require.Equal(t, http.StatusBadRequest, post(rcv, "{", map[string]string{DefaultSignatureHeader: hex.EncodeToString(Sign([]byte("key"), []byte("{")))}))
+}
+
+func TestReceiverDispatch(t *testing.T) {
+	type reading struct {
+		Temp float64 `json:"temp"`
+	}
+	var temps []float64
+	var reqs []string
+	rcv := NewReceiver("")
+	rcv.HandleFile("data.qo", BodyHandler(func() interface{} { return &reading{} },
+		func(ctx context.Context, event note.Event, body interface{}) error {
+			temps = append(temps, body.(*reading).Temp)
+			return nil
+		}))
+	rcv.HandleReq("session.begin", func(ctx context.Context, event note.Event) error {
+		reqs = append(reqs, event.EventUID)
+		return nil
+	})
+
+	code := post(rcv, `[{"event":"e1","file":"data.qo","req":"note.add","body":{"temp":21.5}},
+		{"event":"e2","file":"_session.qo","req":"session.begin"},
+		{"event":"e3","file":"other.qo"}]`, nil)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []float64{21.5}, temps)
+	require.Equal(t, []string{"e2"}, reqs)
+
+	// Redelivered events are dropped
+	require.Equal(t, http.StatusOK, post(rcv, `{"event":"e1","file":"data.qo","body":{"temp":99}}`, nil))
+	require.Equal(t, []float64{21.5}, temps)
+}
+
+func TestReceiverHandlerError(t *testing.T) {
+	calls := 0
+	rcv := NewReceiver("")
+	rcv.HandleDefault(func(ctx context.Context, event note.Event) error {
+		calls++
+		if calls == 1 {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+	require.Equal(t, http.StatusInternalServerError, post(rcv, `{"event":"e1"}`, nil))
+	require.Equal(t, http.StatusOK, post(rcv, `{"event":"e1"}`, nil))
+	require.Equal(t, http.StatusOK, post(rcv, `{"event":"e1"}`, nil))
+	require.Equal(t, 2, calls)
+}
+
+func TestReceiverBulk(t *testing.T) {
+	var order []uint32
+	now := time.Unix(1000, 0)
+	rcv := NewReceiver("")
+	rcv.now = func() time.Time { return now }
+	rcv.HandleDefault(func(ctx context.Context, event note.Event) error {
+		order = append(order, event.BulkNumber)
+		return nil
+	})
+
+	piece := func(uid string, n uint32) note.Event {
+		return note.Event{EventUID: uid, DeviceUID: "dev:1", Bulk: true, BulkReceived: 1000, BulkNumber: n, BulkTotal: 3}
+	}
+	store := NewMemoryBulkStore()
+	rcv.Bulk = store
+	require.NoError(t, rcv.Deliver(context.Background(), []note.Event{piece("a", 3), piece("b", 1)}))
+	require.Empty(t, order)
+	require.Equal(t, 2, store.Pending())
+	require.NoError(t, rcv.Deliver(context.Background(), []note.Event{piece("c", 2)}))
+	require.Equal(t, []uint32{1, 2, 3}, order)
+	require.Equal(t, 0, store.Pending())
+
+	// Incomplete deliveries are dispatched once they time out
+	order = nil
+	other := piece("d", 2)
+	other.BulkReceived = 2000
+	require.NoError(t, rcv.Deliver(context.Background(), []note.Event{other}))
+	require.Empty(t, order)
+	now = now.Add(DefaultBulkTimeout)
+	require.NoError(t, rcv.Flush(context.Background()))
+	require.Equal(t, []uint32{2}, order)
+	require.Equal(t, 0, store.Pending())
+}
+
+func TestReceiverBulkFailure(t *testing.T) {
+	var handled []string
+	fail := true
+	rcv := NewReceiver("")
+	rcv.HandleDefault(func(ctx context.Context, event note.Event) error {
+		if event.EventUID == "b" && fail {
+			fail = false
+			return errors.New("database unavailable")
+		}
+		handled = append(handled, event.EventUID)
+		return nil
+	})
+
+	// The pieces stay stored when a handler fails, and those already handled aren't
+	// handled again when the delivery is retried
+	require.Equal(t, http.StatusOK, post(rcv, `{"event":"a","device":"dev:1","bulk":true,"batch_received":1,"batch_number":1,"batch_total":2}`, nil))
+	last := `{"event":"b","device":"dev:1","bulk":true,"batch_received":1,"batch_number":2,"batch_total":2}`
+	require.Equal(t, http.StatusInternalServerError, post(rcv, last, nil))
+	require.Equal(t, []string{"a"}, handled)
+	require.Equal(t, http.StatusOK, post(rcv, last, nil))
+	require.Equal(t, []string{"a", "b"}, handled)
+}
+
+func TestDirBulkStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDirBulkStore(dir)
+	require.NoError(t, err)
+	piece := func(uid string, n uint32) note.Event {
+		return note.Event{EventUID: uid, DeviceUID: "dev:1", Bulk: true, BulkReceived: 1000.5, BulkNumber: n, BulkTotal: 2}
+	}
+
+	// Pieces stored by one receiver are reassembled by another after a restart
+	rcv := NewReceiver("")
+	rcv.Bulk = store
+	rcv.HandleDefault(func(ctx context.Context, event note.Event) error {
+		return errors.New("not reached")
+	})
+	require.NoError(t, rcv.Deliver(context.Background(), []note.Event{piece("b", 2)}))
+
+	store, err = NewDirBulkStore(dir)
+	require.NoError(t, err)
+	var order []string
+	rcv = NewReceiver("")
+	rcv.Bulk = store
+	rcv.HandleDefault(func(ctx context.Context, event note.Event) error {
+		order = append(order, event.EventUID)
+		return nil
+	})
+	require.NoError(t, rcv.Deliver(context.Background(), []note.Event{piece("a", 1)}))
+	require.Equal(t, []string{"a", "b"}, order)
+
+	keys, err := store.Expired(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	_, err = store.Add(BulkKey{DeviceUID: "dev:2", Received: 7}, piece("c", 1), time.Now())
+	require.NoError(t, err)
+	keys, err = store.Expired(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, []BulkKey{{DeviceUID: "dev:2", Received: 7}}, keys)
+}
+
+func TestReceiverPartialFailure(t *testing.T) {
+	var handled []string
+	rcv := NewReceiver("")
+	rcv.HandleDefault(func(ctx context.Context, event note.Event) error {
+		if event.EventUID == "e2" && len(handled) == 1 {
+			handled = append(handled, "failed")
+			return errors.New("database unavailable")
+		}
+		handled = append(handled, event.EventUID)
+		return nil
+	})
+
+	// Events handled before a failure aren't handled again when the request is retried
+	body := `[{"event":"e1"},{"event":"e2"},{"event":"e3"}]`
+	require.Equal(t, http.StatusInternalServerError, post(rcv, body, nil))
+	require.Equal(t, http.StatusOK, post(rcv, body, nil))
+	require.Equal(t, []string{"e1", "failed", "e2", "e3"}, handled)
+}
+
+func TestMemorySeen(t *testing.T) {
+	seen := NewMemorySeen(2)
+	require.True(t, seen.TryMark("a"))
+	require.False(t, seen.TryMark("a"))
+	require.True(t, seen.TryMark("b"))
+	require.True(t, seen.TryMark("c"))
+	require.True(t, seen.TryMark("a"))
+	require.False(t, seen.TryMark("c"))
+
+	seen.Release("c")
+	require.True(t, seen.TryMark("c"))
+	seen.Release("unknown")
+
+	// Only one of several concurrent deliveries of an event reserves it
+	var reserved int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if seen.TryMark("d") {
+				atomic.AddInt32(&reserved, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), reserved)
+}
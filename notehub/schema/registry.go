@@ -0,0 +1,265 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package schema
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blues/note-go/note"
+)
+
+// fileSuffix is appended to the notefileID to form the name of the schema file on disk
+const fileSuffix = ".schema.json"
+
+// DefaultMinSamples is the number of events observed in a notefile before drift is
+// reported, so that the schema has settled
+const DefaultMinSamples = 10
+
+// Drift is a violation of a notefile's schema by the body of an event
+type Drift struct {
+	NotefileID string `json:"file"`
+	EventUID   string `json:"event,omitempty"`
+	DeviceUID  string `json:"device,omitempty"`
+	Violation
+}
+
+// String describes the drift
+func (d Drift) String() string {
+	s := d.NotefileID + ": " + d.Violation.String()
+	if d.EventUID != "" {
+		s += " in event " + d.EventUID
+	}
+	if d.DeviceUID != "" {
+		s += " from " + d.DeviceUID
+	}
+	return s
+}
+
+// Registry holds the schemas inferred for each notefile, optionally persisted in a
+// directory with one file per notefile
+type Registry struct {
+	// MinSamples is the number of events observed in a notefile before drift is reported
+	MinSamples int64
+	// CheckRanges reports numbers outside the range observed as drift
+	CheckRanges bool
+
+	dir     string
+	lock    sync.Mutex
+	schemas map[string]*Schema
+	dirty   map[string]bool
+}
+
+// NewRegistry returns an empty registry that isn't persisted
+func NewRegistry() *Registry {
+	return &Registry{MinSamples: DefaultMinSamples, schemas: map[string]*Schema{}, dirty: map[string]bool{}}
+}
+
+// Open opens (creating if necessary) a registry persisted in a directory, loading the
+// schemas previously saved there.  A schema file whose name isn't a valid notefile ID
+// is an error.
+func Open(dir string) (r *Registry, err error) {
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	r = NewRegistry()
+	r.dir = dir
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileSuffix) {
+			continue
+		}
+		notefileID := strings.TrimSuffix(entry.Name(), fileSuffix)
+		_, err = note.ParseNotefileID(notefileID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		var contents []byte
+		contents, err = ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		s := &Schema{}
+		err = note.JSONUnmarshal(contents, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		r.schemas[notefileID] = s
+	}
+	return
+}
+
+// Observe checks the body of an event against the schema of its notefile, once enough
+// events have been observed, and then widens the schema to include it, so that each
+// change to the body is reported once.  Events without a notefile or a body are ignored,
+// and an event whose notefile ID is invalid is an error.
+func (r *Registry) Observe(event note.Event) (drift []Drift, err error) {
+	if event.NotefileID == "" || event.Body == nil {
+		return
+	}
+	_, err = note.ParseNotefileID(event.NotefileID)
+	if err != nil {
+		return
+	}
+	body := *event.Body
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s, present := r.schemas[event.NotefileID]
+	if !present {
+		s = New(event.NotefileID)
+		r.schemas[event.NotefileID] = s
+	}
+	if s.Samples >= r.MinSamples {
+		for _, v := range s.Check(body, r.CheckRanges) {
+			drift = append(drift, Drift{NotefileID: event.NotefileID, EventUID: event.EventUID, DeviceUID: event.DeviceUID, Violation: v})
+		}
+	}
+	s.Observe(body)
+	r.dirty[event.NotefileID] = true
+	return
+}
+
+// Check checks the body of an event against the schema of its notefile without
+// changing the schema.  A notefile without a schema has no drift.
+func (r *Registry) Check(event note.Event) (drift []Drift) {
+	if event.Body == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s, present := r.schemas[event.NotefileID]
+	if !present {
+		return
+	}
+	for _, v := range s.Check(*event.Body, r.CheckRanges) {
+		drift = append(drift, Drift{NotefileID: event.NotefileID, EventUID: event.EventUID, DeviceUID: event.DeviceUID, Violation: v})
+	}
+	return
+}
+
+// Notefiles returns the IDs of the notefiles with schemas, sorted
+func (r *Registry) Notefiles() (notefileIDs []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for notefileID := range r.schemas {
+		notefileIDs = append(notefileIDs, notefileID)
+	}
+	sort.Strings(notefileIDs)
+	return
+}
+
+// Schema returns the schema of a notefile as JSON
+func (r *Registry) Schema(notefileID string) (contents []byte, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s, present := r.schemas[notefileID]
+	if !present {
+		return nil, fmt.Errorf("no schema for notefile %s %s", notefileID, note.ErrNotefileNoExist)
+	}
+	return note.JSONMarshalIndent(s, "", "  ")
+}
+
+// Import replaces the schemas of notefiles with schemas in JSON Schema form, such as
+// those returned by notehub.Client.GetSchemas, keyed by notefile ID.  Imported schemas
+// are treated as settled, so drift from them is reported immediately, unless they
+// record how many samples they were inferred from.
+func (r *Registry) Import(schemas map[string]interface{}) (err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for notefileID, v := range schemas {
+		_, err = note.ParseNotefileID(notefileID)
+		if err != nil {
+			return
+		}
+		var contents []byte
+		contents, err = note.JSONMarshal(v)
+		if err != nil {
+			return
+		}
+		s := &Schema{}
+		err = note.JSONUnmarshal(contents, s)
+		if err != nil {
+			return fmt.Errorf("schema of %s: %w", notefileID, err)
+		}
+		settle(s, r.MinSamples)
+		r.schemas[notefileID] = s
+		r.dirty[notefileID] = true
+	}
+	return
+}
+
+// Forget discards the schema of a notefile, so that it is inferred afresh
+func (r *Registry) Forget(notefileID string) (err error) {
+	_, err = note.ParseNotefileID(notefileID)
+	if err != nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.schemas, notefileID)
+	delete(r.dirty, notefileID)
+	if r.dir == "" {
+		return
+	}
+	err = os.Remove(filepath.Join(r.dir, notefileID+fileSuffix))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+// settle gives a schema and its subschemas that don't record how many samples they were
+// inferred from enough samples that drift from them is reported
+func settle(s *Schema, samples int64) {
+	if s == nil {
+		return
+	}
+	if s.Samples == 0 {
+		s.Samples = samples
+	}
+	for _, p := range s.Properties {
+		settle(p, samples)
+	}
+	settle(s.Items, samples)
+}
+
+// Save writes the schemas that have changed since they were last saved, if the registry
+// is persisted
+func (r *Registry) Save() (err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.dir == "" {
+		return
+	}
+	for notefileID := range r.dirty {
+		var contents []byte
+		contents, err = note.JSONMarshalIndent(r.schemas[notefileID], "", "  ")
+		if err != nil {
+			return
+		}
+		path := filepath.Join(r.dir, notefileID+fileSuffix)
+		temp := path + ".tmp"
+		err = ioutil.WriteFile(temp, contents, 0600)
+		if err != nil {
+			return
+		}
+		err = os.Rename(temp, path)
+		if err != nil {
+			return
+		}
+		delete(r.dirty, notefileID)
+	}
+	return
+}
@@ -0,0 +1,338 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package schema infers JSON Schemas for the bodies of the notes in each notefile from
+// the events that carry them, and flags drift when a new event's body doesn't conform
+// to the schema inferred from those before it, such as when firmware adds, removes or
+// retypes a field.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dialect is the JSON Schema dialect of inferred schemas
+const Dialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSON Schema type names
+const (
+	TypeNull    = "null"
+	TypeBoolean = "boolean"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeString  = "string"
+	TypeArray   = "array"
+	TypeObject  = "object"
+)
+
+// typeOrder is the order in which types are listed
+var typeOrder = []string{TypeNull, TypeBoolean, TypeInteger, TypeNumber, TypeString, TypeArray, TypeObject}
+
+// Types is the set of types that a value may have, which is marshaled as a single name
+// if there is only one
+type Types []string
+
+// MarshalJSON marshals a single type as a string and several as an array
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON accepts either a string or an array of strings
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		*t = Types{name}
+		return nil
+	}
+	var names []string
+	err := json.Unmarshal(data, &names)
+	if err != nil {
+		return err
+	}
+	*t = names
+	return nil
+}
+
+// has returns true if the set contains a type
+func (t Types) has(name string) bool {
+	for _, n := range t {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if a value of a type conforms, noting that an integer is a number
+func (t Types) allows(name string) bool {
+	return t.has(name) || (name == TypeInteger && t.has(TypeNumber))
+}
+
+// add adds a type, subsuming integer within number
+func (t Types) add(name string) Types {
+	if t.allows(name) {
+		return t
+	}
+	added := Types{}
+	for _, n := range typeOrder {
+		if (n == name || t.has(n)) && !(n == TypeInteger && name == TypeNumber) {
+			added = append(added, n)
+		}
+	}
+	return added
+}
+
+// String lists the types
+func (t Types) String() string {
+	return strings.Join(t, "|")
+}
+
+// Schema is a JSON Schema inferred from observed values.  Objects are closed, listing
+// every property observed, and required properties are those present in every object.
+type Schema struct {
+	Dialect    string             `json:"$schema,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Type       Types              `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Minimum    *float64           `json:"minimum,omitempty"`
+	Maximum    *float64           `json:"maximum,omitempty"`
+	// Samples is the number of values observed
+	Samples int64 `json:"x-samples,omitempty"`
+}
+
+// New returns an empty schema for the bodies of a notefile
+func New(notefileID string) *Schema {
+	return &Schema{Dialect: Dialect, Title: notefileID}
+}
+
+// typeOf returns the JSON Schema type of a value, along with its value if it's a number
+func typeOf(v interface{}) (name string, number float64) {
+	switch v := v.(type) {
+	case nil:
+		return TypeNull, 0
+	case bool:
+		return TypeBoolean, 0
+	case string:
+		return TypeString, 0
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return TypeString, 0
+		}
+		number = f
+	case map[string]interface{}:
+		return TypeObject, 0
+	case []interface{}:
+		return TypeArray, 0
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			number = float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			number = float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			number = rv.Float()
+		default:
+			return TypeString, 0
+		}
+	}
+	if number == math.Trunc(number) && !math.IsInf(number, 0) {
+		return TypeInteger, number
+	}
+	return TypeNumber, number
+}
+
+// Observe incorporates a value into the schema, widening it as needed
+func (s *Schema) Observe(v interface{}) {
+	name, number := typeOf(v)
+	wasObject := s.Type.has(TypeObject)
+	s.Type = s.Type.add(name)
+	s.Samples++
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if s.Properties == nil {
+			s.Properties = map[string]*Schema{}
+		}
+		for k, item := range v {
+			p := s.Properties[k]
+			if p == nil {
+				p = &Schema{}
+				s.Properties[k] = p
+			}
+			p.Observe(item)
+		}
+		if !wasObject {
+			s.Required = sortedKeys(v)
+		} else {
+			var required []string
+			for _, k := range s.Required {
+				if _, present := v[k]; present {
+					required = append(required, k)
+				}
+			}
+			s.Required = required
+		}
+
+	case []interface{}:
+		if s.Items == nil {
+			s.Items = &Schema{}
+		}
+		for _, item := range v {
+			s.Items.Observe(item)
+		}
+	}
+
+	if name == TypeInteger || name == TypeNumber {
+		if s.Minimum == nil || number < *s.Minimum {
+			min := number
+			s.Minimum = &min
+		}
+		if s.Maximum == nil || number > *s.Maximum {
+			max := number
+			s.Maximum = &max
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) (keys []string) {
+	keys = []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// Kinds of drift
+const (
+	// DriftType is a value whose type the schema doesn't allow
+	DriftType = "type"
+	// DriftMissing is a required property that is missing
+	DriftMissing = "missing"
+	// DriftAdded is a property that the schema doesn't have
+	DriftAdded = "added"
+	// DriftRange is a number outside the range observed
+	DriftRange = "range"
+)
+
+// Violation is a way in which a value doesn't conform to a schema
+type Violation struct {
+	// Path locates the value within the body, such as "readings[].temp"
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// String describes the violation
+func (v Violation) String() string {
+	switch v.Kind {
+	case DriftType:
+		return fmt.Sprintf("%s is %s rather than %s", v.Path, v.Actual, v.Expected)
+	case DriftMissing:
+		return fmt.Sprintf("%s is missing", v.Path)
+	case DriftAdded:
+		return fmt.Sprintf("%s was added as %s", v.Path, v.Actual)
+	case DriftRange:
+		return fmt.Sprintf("%s is %s, outside %s", v.Path, v.Actual, v.Expected)
+	}
+	return fmt.Sprintf("%s: %s", v.Path, v.Kind)
+}
+
+// Check returns the ways in which a value doesn't conform to the schema, ordered by
+// path, reporting each kind of violation at each path once.  Ranges are only checked
+// if checkRanges is set, because they widen naturally over time.
+func (s *Schema) Check(v interface{}, checkRanges bool) (violations []Violation) {
+	seen := map[Violation]bool{}
+	s.check("", v, checkRanges, func(violation Violation) {
+		key := Violation{Path: violation.Path, Kind: violation.Kind}
+		if !seen[key] {
+			seen[key] = true
+			violations = append(violations, violation)
+		}
+	})
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return
+}
+
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func (s *Schema) check(path string, v interface{}, checkRanges bool, report func(Violation)) {
+	name, number := typeOf(v)
+	where := path
+	if where == "" {
+		where = "body"
+	}
+	// A schema without a type, such as one imported, allows a value of any type
+	if len(s.Type) > 0 && !s.Type.allows(name) {
+		report(Violation{Path: where, Kind: DriftType, Expected: s.Type.String(), Actual: name})
+		return
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range s.Required {
+			if _, present := v[k]; !present {
+				missing := Violation{Path: join(path, k), Kind: DriftMissing}
+				if p := s.Properties[k]; p != nil {
+					missing.Expected = p.Type.String()
+				}
+				report(missing)
+			}
+		}
+		for _, k := range sortedKeys(v) {
+			p, present := s.Properties[k]
+			if !present {
+				actual, _ := typeOf(v[k])
+				report(Violation{Path: join(path, k), Kind: DriftAdded, Actual: actual})
+				continue
+			}
+			// A property may be listed without a subschema, which allows any value
+			if p != nil {
+				p.check(join(path, k), v[k], checkRanges, report)
+			}
+		}
+
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+		for _, item := range v {
+			if s.Items.Samples == 0 {
+				actual, _ := typeOf(item)
+				report(Violation{Path: where + "[]", Kind: DriftAdded, Actual: actual})
+				continue
+			}
+			s.Items.check(where+"[]", item, checkRanges, report)
+		}
+	}
+
+	if checkRanges && (name == TypeInteger || name == TypeNumber) && s.Minimum != nil && s.Maximum != nil {
+		if number < *s.Minimum || number > *s.Maximum {
+			report(Violation{
+				Path:     where,
+				Kind:     DriftRange,
+				Expected: fmt.Sprintf("[%g, %g]", *s.Minimum, *s.Maximum),
+				Actual:   fmt.Sprintf("%g", number),
+			})
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package schema
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func event(uid string, file string, body string) note.Event {
+	var b map[string]interface{}
+	err := note.JSONUnmarshal([]byte(body), &b)
+	if err != nil {
+		panic(err)
+	}
+	return note.Event{EventUID: uid, DeviceUID: "dev:1", NotefileID: file, Body: &b}
+}
+
+func observe(t *testing.T, r *Registry, e note.Event) []Drift {
+	drift, err := r.Observe(e)
+	require.NoError(t, err)
+	return drift
+}
+
+func TestInfer(t *testing.T) {
+	s := New("data.qo")
+	for _, body := range []string{
+		`{"temp": 20, "status": "ok", "readings": [1, 2]}`,
+		`{"temp": 21.5, "status": "ok", "flag": true}`,
+		`{"temp": -3, "status": null, "readings": []}`,
+	} {
+		var b map[string]interface{}
+		require.NoError(t, note.JSONUnmarshal([]byte(body), &b))
+		s.Observe(b)
+	}
+	require.Equal(t, Types{TypeObject}, s.Type)
+	require.Equal(t, []string{"status", "temp"}, s.Required)
+	require.Equal(t, Types{TypeNumber}, s.Properties["temp"].Type)
+	require.Equal(t, -3.0, *s.Properties["temp"].Minimum)
+	require.Equal(t, 21.5, *s.Properties["temp"].Maximum)
+	require.Equal(t, Types{TypeNull, TypeString}, s.Properties["status"].Type)
+	require.Equal(t, Types{TypeInteger}, s.Properties["readings"].Items.Type)
+	require.Equal(t, int64(3), s.Samples)
+
+	contents, err := note.JSONMarshal(s)
+	require.NoError(t, err)
+	require.Contains(t, string(contents), `"$schema":"`+Dialect+`"`)
+	require.Contains(t, string(contents), `"status":{"type":["null","string"],"x-samples":3}`)
+	var decoded Schema
+	require.NoError(t, note.JSONUnmarshal(contents, &decoded))
+	require.Equal(t, s.Properties["status"].Type, decoded.Properties["status"].Type)
+}
+
+func TestDrift(t *testing.T) {
+	r := NewRegistry()
+	r.MinSamples = 2
+	require.Empty(t, observe(t, r, event("e1", "data.qo", `{"temp": 20, "status": "ok"}`)))
+	require.Empty(t, observe(t, r, event("e2", "data.qo", `{"temp": 30, "status": "ok"}`)))
+	require.Empty(t, observe(t, r, event("e3", "data.qo", `{"temp": 25, "status": "ok"}`)))
+
+	drift := observe(t, r, event("e4", "data.qo", `{"temp": "hot", "humidity": 40}`))
+	require.Len(t, drift, 3)
+	require.Equal(t, "data.qo: humidity was added as integer in event e4 from dev:1", drift[0].String())
+	require.Equal(t, Violation{Path: "status", Kind: DriftMissing, Expected: "string"}, drift[1].Violation)
+	require.Equal(t, Violation{Path: "temp", Kind: DriftType, Expected: "integer", Actual: "string"}, drift[2].Violation)
+
+	// Once observed, the same change is no longer drift
+	require.Empty(t, observe(t, r, event("e5", "data.qo", `{"temp": "warm", "humidity": 41}`)))
+
+	r.CheckRanges = true
+	drift = r.Check(event("e6", "data.qo", `{"temp": 99, "status": "ok"}`))
+	require.Len(t, drift, 1)
+	require.Equal(t, "temp is 99, outside [20, 30]", drift[0].Violation.String())
+
+	require.Empty(t, observe(t, r, note.Event{NotefileID: "data.qo"}))
+	require.Equal(t, []string{"data.qo"}, r.Notefiles())
+}
+
+func TestPersist(t *testing.T) {
+	dir := t.TempDir()
+	r, err := Open(dir)
+	require.NoError(t, err)
+	observe(t, r, event("e1", "data.qo", `{"temp": 20}`))
+	observe(t, r, event("e2", "_track.qo", `{"velocity": 1.5}`))
+	require.NoError(t, r.Save())
+
+	r, err = Open(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"_track.qo", "data.qo"}, r.Notefiles())
+	contents, err := r.Schema("data.qo")
+	require.NoError(t, err)
+	require.Contains(t, string(contents), `"title": "data.qo"`)
+
+	require.NoError(t, r.Forget("data.qo"))
+	r, err = Open(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"_track.qo"}, r.Notefiles())
+	_, err = r.Schema("data.qo")
+	require.True(t, note.ErrorContains(err, note.ErrNotefileNoExist))
+
+	require.NoError(t, r.Import(map[string]interface{}{
+		"env.qo": map[string]interface{}{"type": "object", "required": []interface{}{"id"}, "properties": map[string]interface{}{"id": map[string]interface{}{"type": "string"}}},
+	}))
+	drift := r.Check(event("e3", "env.qo", `{}`))
+	require.Len(t, drift, 1)
+	require.Equal(t, DriftMissing, drift[0].Kind)
+}
+
+func TestImportedSchemas(t *testing.T) {
+	r := NewRegistry()
+	var schemas map[string]interface{}
+	require.NoError(t, note.JSONUnmarshal([]byte(`{
+		"env.qo": {"type": "object", "required": ["id", "ts"], "properties": {"id": {"type": "string"}, "loose": null}},
+		"data.qo": {"type": "object", "required": ["temp"], "properties": {"temp": {"type": "number"}, "tags": {"type": "array", "items": {"type": "string"}}}}
+	}`), &schemas))
+	require.NoError(t, r.Import(schemas))
+
+	// A required property without a subschema, and a property whose subschema is null,
+	// are handled rather than dereferenced
+	drift := r.Check(event("e1", "env.qo", `{"loose": 1}`))
+	require.Len(t, drift, 2)
+	require.Equal(t, Violation{Path: "id", Kind: DriftMissing, Expected: "string"}, drift[0].Violation)
+	require.Equal(t, Violation{Path: "ts", Kind: DriftMissing}, drift[1].Violation)
+	drift = observe(t, r, event("e2", "env.qo", `{"id": "a", "ts": 1, "loose": {}}`))
+	require.Equal(t, []Drift{{NotefileID: "env.qo", EventUID: "e2", DeviceUID: "dev:1", Violation: Violation{Path: "ts", Kind: DriftAdded, Actual: "integer"}}}, drift)
+
+	// Imported schemas are settled, so the first event that violates one is drift
+	drift = observe(t, r, event("e3", "data.qo", `{"temp": "hot", "tags": [1]}`))
+	require.Len(t, drift, 2)
+	require.Equal(t, DriftType, drift[0].Kind)
+	require.Equal(t, "tags[]", drift[0].Path)
+	require.Equal(t, "temp", drift[1].Path)
+}
+
+func TestInvalidNotefileID(t *testing.T) {
+	dir := t.TempDir()
+	r, err := Open(dir)
+	require.NoError(t, err)
+	_, err = r.Observe(event("e1", "../../x.qo", `{"temp": 20}`))
+	require.True(t, note.ErrorContains(err, note.ErrNotefileName))
+	err = r.Import(map[string]interface{}{"../x.qo": map[string]interface{}{"type": "object"}})
+	require.True(t, note.ErrorContains(err, note.ErrNotefileName))
+	require.True(t, note.ErrorContains(r.Forget("../x.qo"), note.ErrNotefileName))
+	require.Empty(t, r.Notefiles())
+	require.NoError(t, r.Save())
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bad#name.qo"+fileSuffix), []byte(`{}`), 0600))
+	_, err = Open(dir)
+	require.True(t, note.ErrorContains(err, note.ErrNotefileName))
+}
@@ -0,0 +1,173 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package notehub
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// This is an implementation of the snappy block format, which is all that's needed for
+// request payloads, so that we needn't take a dependency for so little.  See
+// https://github.com/google/snappy/blob/main/format_description.txt
+
+const (
+	snappyTagLiteral = 0x00
+	snappyTagCopy1   = 0x01
+	snappyTagCopy2   = 0x02
+	snappyTagCopy4   = 0x03
+
+	snappyMinMatch   = 4
+	snappyMaxOffset  = 65535
+	snappyHashBits   = 14
+	snappyMaxCopyLen = 64
+)
+
+// snappyEncode compresses a buffer in the snappy block format
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(src)+len(src)/6+32)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+
+	table := make([]int, 1<<snappyHashBits)
+	for i := range table {
+		table[i] = -1
+	}
+	hash := func(i int) uint32 {
+		return (binary.LittleEndian.Uint32(src[i:]) * 0x1e35a7bd) >> (32 - snappyHashBits)
+	}
+
+	literal := 0
+	i := 0
+	for i+snappyMinMatch <= len(src) {
+		h := hash(i)
+		candidate := table[h]
+		table[h] = i
+		if candidate < 0 || i-candidate > snappyMaxOffset ||
+			binary.LittleEndian.Uint32(src[candidate:]) != binary.LittleEndian.Uint32(src[i:]) {
+			i++
+			continue
+		}
+		length := snappyMinMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = snappyEmitLiteral(dst, src[literal:i])
+		dst = snappyEmitCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	return snappyEmitLiteral(dst, src[literal:])
+}
+
+func snappyEmitLiteral(dst []byte, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n<<2)|snappyTagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyTagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|snappyTagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|snappyTagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|snappyTagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+func snappyEmitCopy(dst []byte, offset int, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > snappyMaxCopyLen {
+			n = snappyMaxCopyLen
+			// Never leave a remainder too short to be a copy
+			if length-n < snappyMinMatch {
+				n = length - snappyMinMatch
+			}
+		}
+		if n >= 4 && n <= 11 && offset < 2048 {
+			dst = append(dst, byte(offset>>8)<<5|byte(n-4)<<2|snappyTagCopy1, byte(offset))
+		} else {
+			dst = append(dst, byte(n-1)<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		}
+		length -= n
+	}
+	return dst
+}
+
+// snappyDecode decompresses a buffer in the snappy block format
+func snappyDecode(src []byte) (dst []byte, err error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 0xffffffff {
+		return nil, fmt.Errorf("snappy: invalid length")
+	}
+	src = src[n:]
+	dst = make([]byte, 0, length)
+
+	for len(src) > 0 {
+		tag := src[0]
+		var offset, count int
+		switch tag & 0x03 {
+		case snappyTagLiteral:
+			count = int(tag >> 2)
+			src = src[1:]
+			if count >= 60 {
+				extra := count - 59
+				if len(src) < extra {
+					return nil, fmt.Errorf("snappy: truncated literal")
+				}
+				count = 0
+				for j := extra - 1; j >= 0; j-- {
+					count = count<<8 | int(src[j])
+				}
+				src = src[extra:]
+			}
+			count++
+			if count > len(src) || len(dst)+count > int(length) {
+				return nil, fmt.Errorf("snappy: literal overruns buffer")
+			}
+			dst = append(dst, src[:count]...)
+			src = src[count:]
+			continue
+		case snappyTagCopy1:
+			if len(src) < 2 {
+				return nil, fmt.Errorf("snappy: truncated copy")
+			}
+			count = int(tag>>2&0x07) + 4
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case snappyTagCopy2:
+			if len(src) < 3 {
+				return nil, fmt.Errorf("snappy: truncated copy")
+			}
+			count = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case snappyTagCopy4:
+			if len(src) < 5 {
+				return nil, fmt.Errorf("snappy: truncated copy")
+			}
+			count = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) || len(dst)+count > int(length) {
+			return nil, fmt.Errorf("snappy: invalid copy")
+		}
+		// Copies may overlap the bytes they produce, so copy one byte at a time
+		start := len(dst) - offset
+		for j := 0; j < count; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+	if len(dst) != int(length) {
+		return nil, fmt.Errorf("snappy: expected %d bytes but decoded %d", length, len(dst))
+	}
+	return dst, nil
+}
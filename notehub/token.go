@@ -0,0 +1,231 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package notehub
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/gofrs/flock"
+)
+
+// DefaultExpiryMargin is how long before its expiry a token is treated as expired, so
+// that it isn't used for a request that may still be in flight when it expires
+const DefaultExpiryMargin = 5 * time.Minute
+
+// Expired returns true if the token has expired.  A token with no expiry time never expires.
+func (t *AccessToken) Expired() bool {
+	return t.ExpiresWithin(0)
+}
+
+// ExpiresWithin returns true if the token will have expired within the specified duration
+func (t *AccessToken) ExpiresWithin(d time.Duration) bool {
+	if t.ExpiresAt.IsZero() {
+		return false
+	}
+	return !time.Now().Add(d).Before(t.ExpiresAt)
+}
+
+// TokenStore is a file holding the access tokens of any number of users on any
+// number of Notehubs, keyed by host and email.  The file is created with permissions
+// that allow only its owner to read it, and is locked while being read or written so
+// that it may be shared by concurrent processes.
+type TokenStore struct {
+	Path string
+}
+
+// DefaultTokenStorePath returns the path of the token store in the user's config directory
+func DefaultTokenStorePath() (path string, err error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return
+	}
+	return filepath.Join(dir, "notehub", "tokens.json"), nil
+}
+
+// NewTokenStore returns a store backed by the specified file, or by the default file if empty
+func NewTokenStore(path string) (store *TokenStore, err error) {
+	if path == "" {
+		path, err = DefaultTokenStorePath()
+		if err != nil {
+			return
+		}
+	}
+	return &TokenStore{Path: path}, nil
+}
+
+// tokenKey is the key under which a token is stored
+func tokenKey(host string, email string) string {
+	return strings.ToLower(host) + "/" + strings.ToLower(email)
+}
+
+// locked runs a function while holding the store's lock, loading the tokens beforehand
+// and saving them afterwards if the function changed them
+func (store *TokenStore) locked(fn func(tokens map[string]AccessToken) (changed bool, err error)) (err error) {
+	err = os.MkdirAll(filepath.Dir(store.Path), 0700)
+	if err != nil {
+		return
+	}
+	lock := flock.New(store.Path + ".lock")
+	err = lock.Lock()
+	if err != nil {
+		return fmt.Errorf("cannot lock token store: %w", err)
+	}
+	defer lock.Unlock()
+
+	tokens := map[string]AccessToken{}
+	contents, err := ioutil.ReadFile(store.Path)
+	if err == nil {
+		err = note.JSONUnmarshal(contents, &tokens)
+		if err != nil {
+			return fmt.Errorf("cannot parse token store %s: %w", store.Path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return
+	}
+
+	changed, err := fn(tokens)
+	if err != nil || !changed {
+		return
+	}
+
+	contents, err = note.JSONMarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := store.Path + ".tmp"
+	err = ioutil.WriteFile(tmp, contents, 0600)
+	if err != nil {
+		return
+	}
+	// WriteFile doesn't change the permissions of a file that already exists
+	err = os.Chmod(tmp, 0600)
+	if err != nil {
+		return
+	}
+	return os.Rename(tmp, store.Path)
+}
+
+// Save stores a token, replacing any existing token for the same host and email
+func (store *TokenStore) Save(token *AccessToken) (err error) {
+	return store.locked(func(tokens map[string]AccessToken) (bool, error) {
+		tokens[tokenKey(token.Host, token.Email)] = *token
+		return true, nil
+	})
+}
+
+// Load returns the token for a host and email, returning an error containing
+// note.ErrAuth if there is none
+func (store *TokenStore) Load(host string, email string) (token *AccessToken, err error) {
+	err = store.locked(func(tokens map[string]AccessToken) (bool, error) {
+		t, present := tokens[tokenKey(host, email)]
+		if !present {
+			return false, fmt.Errorf("not signed in to %s as %s %s", host, email, note.ErrAuth)
+		}
+		token = &t
+		return false, nil
+	})
+	return
+}
+
+// Delete removes the token for a host and email, if any
+func (store *TokenStore) Delete(host string, email string) (err error) {
+	return store.locked(func(tokens map[string]AccessToken) (bool, error) {
+		key := tokenKey(host, email)
+		_, present := tokens[key]
+		delete(tokens, key)
+		return present, nil
+	})
+}
+
+// List returns every stored token, ordered by host and email
+func (store *TokenStore) List() (list []AccessToken, err error) {
+	err = store.locked(func(tokens map[string]AccessToken) (bool, error) {
+		for _, t := range tokens {
+			list = append(list, t)
+		}
+		return false, nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return tokenKey(list[i].Host, list[i].Email) < tokenKey(list[j].Host, list[j].Email)
+	})
+	return
+}
+
+// Purge removes every token that has expired, returning the number removed
+func (store *TokenStore) Purge() (purged int, err error) {
+	err = store.locked(func(tokens map[string]AccessToken) (bool, error) {
+		for key, t := range tokens {
+			if t.Expired() && t.RefreshToken == "" {
+				delete(tokens, key)
+				purged++
+			}
+		}
+		return purged > 0, nil
+	})
+	return
+}
+
+// Valid returns the token for a host and email, refreshing it first if it will expire
+// within the specified margin and it can be refreshed.  A token that has expired is
+// removed only if it can't be refreshed, or if the Notehub rejects its refresh token,
+// and then an error containing note.ErrAuth is returned so that the caller knows to
+// sign in again.  If the refresh fails for any other reason, such as the network, the
+// token is kept so that it can be refreshed later.  Only tokens from a login that requested
+// offline access, with LoginOptions.OfflineAccess or DeviceLoginOptions.OfflineAccess,
+// have a refresh token; any other token must be replaced by signing in again.
+func (store *TokenStore) Valid(host string, email string, margin time.Duration) (token *AccessToken, err error) {
+	return store.valid(host, email, margin, RefreshAccessToken)
+}
+
+func (store *TokenStore) valid(host string, email string, margin time.Duration, refresh func(*AccessToken) (*AccessToken, error)) (token *AccessToken, err error) {
+	token, err = store.Load(host, email)
+	if err != nil || !token.ExpiresWithin(margin) {
+		return
+	}
+	if token.RefreshToken != "" {
+		refreshed, refreshErr := refresh(token)
+		if refreshErr == nil {
+			return refreshed, store.Save(refreshed)
+		}
+		if !token.Expired() {
+			// Still usable for now, so don't fail just because the refresh did
+			return token, nil
+		}
+		if !note.ErrorContains(refreshErr, note.ErrAuth) {
+			return nil, fmt.Errorf("token for %s on %s has expired and could not be refreshed: %w", email, host, refreshErr)
+		}
+	}
+	if token.Expired() {
+		err = store.Delete(host, email)
+		if err != nil {
+			return nil, fmt.Errorf("removing expired token for %s on %s: %w", email, host, err)
+		}
+		return nil, fmt.Errorf("token for %s on %s has expired %s", email, host, note.ErrAuth)
+	}
+	return token, nil
+}
+
+// Revoke revokes the token for a host and email on the Notehub that issued it, and
+// removes it from the store whether or not the Notehub could be reached
+func (store *TokenStore) Revoke(host string, email string) (err error) {
+	token, err := store.Load(host, email)
+	if err != nil {
+		return
+	}
+	_, uiHost := notehubHosts(token.Host)
+	revokeErr := RevokeAccessToken(uiHost, token.AccessToken)
+	err = store.Delete(host, email)
+	if err == nil {
+		err = revokeErr
+	}
+	return
+}
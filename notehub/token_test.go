@@ -0,0 +1,140 @@
+package notehub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenStore(t *testing.T) {
+	store, err := NewTokenStore(filepath.Join(t.TempDir(), "notehub", "tokens.json"))
+	require.NoError(t, err)
+
+	_, err = store.Load("api.notefile.net", "a@example.com")
+	require.True(t, note.ErrorContains(err, note.ErrAuth))
+
+	live := &AccessToken{Host: "api.notefile.net", Email: "a@example.com", AccessToken: "a", ExpiresAt: time.Now().Add(time.Hour)}
+	stale := &AccessToken{Host: "api.notefile.net", Email: "b@example.com", AccessToken: "b", ExpiresAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, store.Save(live))
+	require.NoError(t, store.Save(stale))
+
+	info, err := os.Stat(store.Path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	token, err := store.Load("API.notefile.net", "A@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "a", token.AccessToken)
+
+	token, err = store.Valid("api.notefile.net", "a@example.com", DefaultExpiryMargin)
+	require.NoError(t, err)
+	require.Equal(t, "a", token.AccessToken)
+
+	_, err = store.Valid("api.notefile.net", "b@example.com", DefaultExpiryMargin)
+	require.True(t, note.ErrorContains(err, note.ErrAuth))
+	list, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	require.NoError(t, store.Delete("api.notefile.net", "a@example.com"))
+	list, err = store.List()
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
+
+func TestTokenStoreRefresh(t *testing.T) {
+	store, err := NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	require.NoError(t, err)
+	expired := &AccessToken{Host: "api.notefile.net", Email: "a@example.com", AccessToken: "a", RefreshToken: "r", ExpiresAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, store.Save(expired))
+
+	// A transient failure keeps the token, along with its refresh token
+	transient := func(*AccessToken) (*AccessToken, error) {
+		return nil, fmt.Errorf("making request: connection refused %s", note.ErrNetwork)
+	}
+	_, err = store.valid("api.notefile.net", "a@example.com", DefaultExpiryMargin, transient)
+	require.True(t, note.ErrorContains(err, note.ErrNetwork))
+	require.False(t, note.ErrorContains(err, note.ErrAuth))
+	token, err := store.Load("api.notefile.net", "a@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "r", token.RefreshToken)
+
+	refresh := func(token *AccessToken) (*AccessToken, error) {
+		return &AccessToken{Host: token.Host, Email: token.Email, AccessToken: "b", RefreshToken: "r", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	}
+	token, err = store.valid("api.notefile.net", "a@example.com", DefaultExpiryMargin, refresh)
+	require.NoError(t, err)
+	require.Equal(t, "b", token.AccessToken)
+
+	// A rejected refresh token removes the token
+	require.NoError(t, store.Save(expired))
+	invalid := func(*AccessToken) (*AccessToken, error) {
+		return nil, fmt.Errorf("invalid_grant %s", note.ErrAuth)
+	}
+	_, err = store.valid("api.notefile.net", "a@example.com", DefaultExpiryMargin, invalid)
+	require.True(t, note.ErrorContains(err, note.ErrAuth))
+	_, err = store.Load("api.notefile.net", "a@example.com")
+	require.True(t, note.ErrorContains(err, note.ErrAuth))
+}
+
+func TestPostFormServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	_, err := refreshAccessToken(context.Background(), server.URL, &AccessToken{RefreshToken: "r"})
+	require.True(t, note.ErrorContains(err, note.ErrNetwork))
+	require.False(t, note.ErrorContains(err, note.ErrAuth))
+}
+
+func TestDeviceLogin(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/device/auth":
+			require.Equal(t, "openid email offline_access", r.FormValue("scope"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "dc",
+				"user_code":        "ABCD-EFGH",
+				"verification_uri": "https://notehub.io/device",
+				"expires_in":       60,
+				"interval":         1,
+			})
+		case "/oauth2/token":
+			require.Equal(t, deviceCodeGrantType, r.FormValue("grant_type"))
+			require.Equal(t, "dc", r.FormValue("device_code"))
+			polls++
+			if polls == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "at", "refresh_token": "rt", "expires_in": 3600})
+		case "/userinfo":
+			require.Equal(t, "Bearer at", r.Header.Get("Authorization"))
+			json.NewEncoder(w).Encode(map[string]string{"email": "a@example.com"})
+		}
+	}))
+	defer server.Close()
+
+	var prompted DeviceAuthorization
+	token, err := deviceLogin(context.Background(), "api.example.com", server.URL, server.URL, DeviceLoginOptions{OfflineAccess: true}, func(auth DeviceAuthorization) {
+		prompted = auth
+	})
+	require.NoError(t, err)
+	require.Equal(t, "ABCD-EFGH", prompted.UserCode)
+	require.Equal(t, 2, polls)
+	require.Equal(t, "a@example.com", token.Email)
+	require.Equal(t, "rt", token.RefreshToken)
+	require.False(t, token.ExpiresWithin(DefaultExpiryMargin))
+}
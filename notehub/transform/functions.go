@@ -0,0 +1,516 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blues/note-go/note"
+)
+
+// function is a built-in function, called with the context and its evaluated arguments
+type function func(ctx interface{}, args []interface{}) (interface{}, error)
+
+// functions are the built-in functions, by name without the $
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		// Strings
+		"string":          withContext(fnString),
+		"length":          withContext(fnLength),
+		"substring":       withContext(fnSubstring),
+		"substringBefore": withContext(fnSubstringBefore),
+		"substringAfter":  withContext(fnSubstringAfter),
+		"uppercase":       withContext(stringFunc(strings.ToUpper)),
+		"lowercase":       withContext(stringFunc(strings.ToLower)),
+		"trim":            withContext(stringFunc(func(s string) string { return strings.Join(strings.Fields(s), " ") })),
+		"contains":        withContext(fnContains),
+		"split":           withContext(fnSplit),
+		"join":            fnJoin,
+		"replace":         withContext(fnReplace),
+		// Numbers
+		"number":  withContext(fnNumber),
+		"abs":     withContext(numberFunc(math.Abs)),
+		"floor":   withContext(numberFunc(math.Floor)),
+		"ceil":    withContext(numberFunc(math.Ceil)),
+		"sqrt":    withContext(numberFunc(math.Sqrt)),
+		"round":   withContext(fnRound),
+		"power":   fnPower,
+		"sum":     aggregate(func(items []float64) interface{} { return sum(items) }),
+		"max":     aggregate(fnMax),
+		"min":     aggregate(fnMin),
+		"average": aggregate(fnAverage),
+		"count":   fnCount,
+		// Booleans and existence
+		"boolean": withContext(func(args []interface{}) (interface{}, error) { return truthyOrNil(arg(args, 0)), nil }),
+		"not":     withContext(fnNot),
+		"exists":  fnExists,
+		// Arrays and objects
+		"append": fnAppend,
+		"keys":   withContext(fnKeys),
+		"lookup": fnLookup,
+		"merge":  fnMerge,
+		// Dates
+		"fromMillis": fnFromMillis,
+		"toMillis":   withContext(fnToMillis),
+	}
+}
+
+// withContext adapts a function so that, when called with no arguments, it is applied to
+// the context, as JSONata does for functions such as $string()
+func withContext(fn func(args []interface{}) (interface{}, error)) function {
+	return func(ctx interface{}, args []interface{}) (interface{}, error) {
+		if len(args) == 0 {
+			args = []interface{}{ctx}
+		}
+		return fn(args)
+	}
+}
+
+// arg returns an argument, or nil if it wasn't given
+func arg(args []interface{}, i int) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+func stringArg(args []interface{}, i int) (s string, present bool, err error) {
+	v := arg(args, i)
+	if v == nil {
+		return
+	}
+	s, present = v.(string)
+	if !present {
+		err = fmt.Errorf("argument %d must be a string, not %s", i+1, typeName(v))
+	}
+	return
+}
+
+func numberArg(args []interface{}, i int) (f float64, present bool, err error) {
+	v := arg(args, i)
+	if v == nil {
+		return
+	}
+	f, present = v.(float64)
+	if !present {
+		err = fmt.Errorf("argument %d must be a number, not %s", i+1, typeName(v))
+	}
+	return
+}
+
+func stringFunc(fn func(string) string) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, present, err := stringArg(args, 0)
+		if !present || err != nil {
+			return nil, err
+		}
+		return fn(s), nil
+	}
+}
+
+func numberFunc(fn func(float64) float64) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		f, present, err := numberArg(args, 0)
+		if !present || err != nil {
+			return nil, err
+		}
+		return number(fn(f))
+	}
+}
+
+// formatNumber formats a number as JSONata does, to 15 significant digits
+func formatNumber(f float64) string {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	if math.Abs(rounded) < 1e21 {
+		return strconv.FormatFloat(rounded, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(rounded, 'g', -1, 64)
+}
+
+// stringOf converts a value to a string, as $string does, returning false if there is
+// no value
+func stringOf(v interface{}) (s string, present bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case float64:
+		return formatNumber(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nullValue:
+		return "null", true
+	}
+	contents, _ := note.JSONMarshal(export(v))
+	return string(contents), true
+}
+
+func fnString(args []interface{}) (interface{}, error) {
+	s, present := stringOf(arg(args, 0))
+	if !present {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func fnLength(args []interface{}) (interface{}, error) {
+	s, present, err := stringArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	return float64(len([]rune(s))), nil
+}
+
+func fnSubstring(args []interface{}) (interface{}, error) {
+	s, present, err := stringArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	start, _, err := numberArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	from := int(start)
+	if from < 0 {
+		from += len(runes)
+		if from < 0 {
+			from = 0
+		}
+	}
+	if from > len(runes) {
+		from = len(runes)
+	}
+	to := len(runes)
+	length, hasLength, err := numberArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if hasLength {
+		if length < 0 {
+			length = 0
+		}
+		if from+int(length) < to {
+			to = from + int(length)
+		}
+	}
+	return string(runes[from:to]), nil
+}
+
+func fnSubstringBefore(args []interface{}) (interface{}, error) {
+	s, present, err := stringArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	sep, _, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], nil
+	}
+	return s, nil
+}
+
+func fnSubstringAfter(args []interface{}) (interface{}, error) {
+	s, present, err := stringArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	sep, _, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[i+len(sep):], nil
+	}
+	return s, nil
+}
+
+func fnContains(args []interface{}) (interface{}, error) {
+	s, present, err := stringArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	substr, _, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Contains(s, substr), nil
+}
+
+func fnSplit(args []interface{}) (interface{}, error) {
+	s, present, err := stringArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	sep, _, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(s, sep)
+	limit, hasLimit, err := numberArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if hasLimit && int(limit) < len(parts) {
+		parts = parts[:int(limit)]
+	}
+	items := []interface{}{}
+	for _, part := range parts {
+		items = append(items, part)
+	}
+	return items, nil
+}
+
+func fnJoin(ctx interface{}, args []interface{}) (interface{}, error) {
+	sep, _, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	for _, item := range sequence(arg(args, 0)) {
+		s, isString := item.(string)
+		if !isString {
+			return nil, fmt.Errorf("can only join strings, not %s", typeName(item))
+		}
+		parts = append(parts, s)
+	}
+	if parts == nil {
+		return nil, nil
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func fnReplace(args []interface{}) (interface{}, error) {
+	s, present, err := stringArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	pattern, _, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern cannot be empty")
+	}
+	replacement, _, err := stringArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	limit, hasLimit, err := numberArg(args, 3)
+	if err != nil {
+		return nil, err
+	}
+	n := -1
+	if hasLimit {
+		n = int(limit)
+	}
+	return strings.Replace(s, pattern, replacement, n), nil
+}
+
+func fnNumber(args []interface{}) (interface{}, error) {
+	switch v := arg(args, 0).(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %q to a number", v)
+		}
+		return number(f)
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a number", typeName(v))
+	}
+}
+
+// fnRound rounds half to even, as JSONata does, to a number of decimal places
+func fnRound(args []interface{}) (interface{}, error) {
+	f, present, err := numberArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	precision, _, err := numberArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	scale := math.Pow(10, precision)
+	return number(math.RoundToEven(f*scale) / scale)
+}
+
+func fnPower(ctx interface{}, args []interface{}) (interface{}, error) {
+	base, present, err := numberArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	exponent, _, err := numberArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return number(math.Pow(base, exponent))
+}
+
+// aggregate adapts a function of the numbers in an array
+func aggregate(fn func(items []float64) interface{}) function {
+	return func(ctx interface{}, args []interface{}) (interface{}, error) {
+		var items []float64
+		for _, item := range sequence(arg(args, 0)) {
+			f, isNumber := item.(float64)
+			if !isNumber {
+				return nil, fmt.Errorf("can only aggregate numbers, not %s", typeName(item))
+			}
+			items = append(items, f)
+		}
+		return fn(items), nil
+	}
+}
+
+func sum(items []float64) (total float64) {
+	for _, f := range items {
+		total += f
+	}
+	return
+}
+
+func fnMax(items []float64) interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	max := items[0]
+	for _, f := range items[1:] {
+		max = math.Max(max, f)
+	}
+	return max
+}
+
+func fnMin(items []float64) interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	min := items[0]
+	for _, f := range items[1:] {
+		min = math.Min(min, f)
+	}
+	return min
+}
+
+func fnAverage(items []float64) interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	return sum(items) / float64(len(items))
+}
+
+func fnCount(ctx interface{}, args []interface{}) (interface{}, error) {
+	return float64(len(sequence(arg(args, 0)))), nil
+}
+
+func truthyOrNil(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return truthy(v)
+}
+
+func fnNot(args []interface{}) (interface{}, error) {
+	v := arg(args, 0)
+	if v == nil {
+		return nil, nil
+	}
+	return !truthy(v), nil
+}
+
+func fnExists(ctx interface{}, args []interface{}) (interface{}, error) {
+	return arg(args, 0) != nil, nil
+}
+
+func fnAppend(ctx interface{}, args []interface{}) (interface{}, error) {
+	first, second := arg(args, 0), arg(args, 1)
+	if second == nil {
+		return first, nil
+	}
+	if first == nil {
+		return second, nil
+	}
+	items := append([]interface{}{}, sequence(first)...)
+	return append(items, sequence(second)...), nil
+}
+
+func fnKeys(args []interface{}) (interface{}, error) {
+	keys := map[string]bool{}
+	var names []interface{}
+	for _, item := range sequence(arg(args, 0)) {
+		obj, isObject := item.(map[string]interface{})
+		if !isObject {
+			continue
+		}
+		for _, k := range sortedKeys(obj) {
+			if !keys[k] {
+				keys[k] = true
+				names = append(names, k)
+			}
+		}
+	}
+	return collapse(names), nil
+}
+
+func fnLookup(ctx interface{}, args []interface{}) (interface{}, error) {
+	key, _, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return lookupField(arg(args, 0), key), nil
+}
+
+func fnMerge(ctx interface{}, args []interface{}) (interface{}, error) {
+	merged := map[string]interface{}{}
+	for _, item := range sequence(arg(args, 0)) {
+		obj, isObject := item.(map[string]interface{})
+		if !isObject {
+			return nil, fmt.Errorf("can only merge objects, not %s", typeName(item))
+		}
+		for k, v := range obj {
+			merged[k] = v
+		}
+	}
+	return merged, nil
+}
+
+// fnFromMillis formats milliseconds since the epoch as an ISO 8601 timestamp in UTC
+func fnFromMillis(ctx interface{}, args []interface{}) (interface{}, error) {
+	ms, present, err := numberArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	t := time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+	return t.Format("2006-01-02T15:04:05.000Z"), nil
+}
+
+// fnToMillis parses an ISO 8601 timestamp as milliseconds since the epoch
+func fnToMillis(args []interface{}) (interface{}, error) {
+	s, present, err := stringArg(args, 0)
+	if !present || err != nil {
+		return nil, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse timestamp %q", s)
+	}
+	return float64(t.UnixNano() / int64(time.Millisecond)), nil
+}
@@ -0,0 +1,536 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blues/note-go/note"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	// tokName is a field name or a keyword such as "and" or "true"
+	tokName
+	// tokVariable is $name, or $ alone for the context and $$ for the root
+	tokVariable
+	tokOperator
+)
+
+type token struct {
+	kind   tokenKind
+	value  string
+	number float64
+	pos    int
+	// quoted is set for names in backquotes, which are never keywords
+	quoted bool
+}
+
+// operators are the punctuation operators, longest first
+var operators = []string{":=", "!=", "<=", ">=", ".", "[", "]", "{", "}", "(", ")", ",", ":", ";", "?", "+", "-", "*", "/", "%", "&", "=", "<", ">"}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// syntaxError returns an error at a position in the source
+func syntaxError(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("transform: %s at position %d %s", fmt.Sprintf(format, args...), pos, note.ErrSyntax)
+}
+
+// lex splits the source of an expression into tokens
+func lex(src string) (tokens []token, err error) {
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, syntaxError(i, "unterminated comment")
+			}
+			i += end + 4
+			continue
+
+		case isDigit(c):
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i+1 < len(src) && src[i] == '.' && isDigit(src[i+1]) {
+				i++
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					i = j
+					for i < len(src) && isDigit(src[i]) {
+						i++
+					}
+				}
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, syntaxError(start, "invalid number %s", src[start:i])
+			}
+			tokens = append(tokens, token{kind: tokNumber, number: n, pos: start})
+			continue
+
+		case c == '"' || c == '\'':
+			s, n, err := lexString(src[i:], i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, value: s, pos: start})
+			i += n
+			continue
+
+		case c == '`':
+			end := strings.IndexByte(src[i+1:], '`')
+			if end < 0 {
+				return nil, syntaxError(i, "unterminated name")
+			}
+			tokens = append(tokens, token{kind: tokName, value: src[i+1 : i+1+end], pos: start, quoted: true})
+			i += end + 2
+			continue
+
+		case c == '$':
+			i++
+			if i < len(src) && src[i] == '$' {
+				i++
+				tokens = append(tokens, token{kind: tokVariable, value: "$", pos: start})
+				continue
+			}
+			for i < len(src) && isNameChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokVariable, value: src[start+1 : i], pos: start})
+			continue
+
+		case isNameStart(c):
+			for i < len(src) && isNameChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokName, value: src[start:i], pos: start})
+			continue
+		}
+
+		found := false
+		for _, op := range operators {
+			if strings.HasPrefix(src[i:], op) {
+				tokens = append(tokens, token{kind: tokOperator, value: op, pos: start})
+				i += len(op)
+				found = true
+				break
+			}
+		}
+		if !found {
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return nil, syntaxError(i, "unexpected character %q", r)
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(src)})
+	return
+}
+
+// lexString decodes a quoted string at the start of src, returning the string and the
+// number of bytes consumed
+func lexString(src string, pos int) (s string, n int, err error) {
+	quote := src[0]
+	var b strings.Builder
+	i := 1
+	for i < len(src) {
+		c := src[i]
+		if c == quote {
+			return b.String(), i + 1, nil
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 >= len(src) {
+			break
+		}
+		i++
+		switch src[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 >= len(src) {
+				return "", 0, syntaxError(pos+i, "invalid escape")
+			}
+			r, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", 0, syntaxError(pos+i, "invalid escape")
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(src[i])
+		}
+		i++
+	}
+	return "", 0, syntaxError(pos, "unterminated string")
+}
+
+// Nodes of the syntax tree
+type (
+	node interface{}
+
+	literalNode struct {
+		value interface{}
+	}
+	fieldNode struct {
+		name string
+	}
+	wildcardNode struct{}
+	// variableNode refers to a variable, or to the context if name is empty and the
+	// root if name is "$"
+	variableNode struct {
+		name string
+	}
+	pathNode struct {
+		steps []node
+	}
+	filterNode struct {
+		expr      node
+		predicate node
+	}
+	negateNode struct {
+		expr node
+	}
+	binaryNode struct {
+		op    string
+		left  node
+		right node
+	}
+	conditionNode struct {
+		condition node
+		then      node
+		otherwise node
+	}
+	blockNode struct {
+		exprs []node
+	}
+	bindNode struct {
+		name string
+		expr node
+	}
+	callNode struct {
+		name string
+		args []node
+		pos  int
+	}
+	arrayNode struct {
+		items []node
+	}
+	objectNode struct {
+		keys   []node
+		values []node
+	}
+)
+
+// bindingPowers are the binding powers of infix operators, as in JSONata
+var bindingPowers = map[string]int{
+	".":   75,
+	"[":   80,
+	"*":   60,
+	"/":   60,
+	"%":   60,
+	"+":   50,
+	"-":   50,
+	"&":   50,
+	"=":   40,
+	"!=":  40,
+	"<":   40,
+	"<=":  40,
+	">":   40,
+	">=":  40,
+	"in":  40,
+	"and": 30,
+	"or":  25,
+	"?":   20,
+	":=":  10,
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+// parse parses the source of an expression into a syntax tree
+func parse(src string) (n node, err error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, syntaxError(0, "empty expression")
+	}
+	n, err = p.expression(0)
+	if err != nil {
+		return
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, syntaxError(t.pos, "unexpected %s", describe(t))
+	}
+	return
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+// isOperator returns true if a token is the given operator
+func isOperator(t token, op string) bool {
+	return t.kind == tokOperator && t.value == op
+}
+
+func (p *parser) expect(op string) error {
+	t := p.advance()
+	if !isOperator(t, op) {
+		return syntaxError(t.pos, "expected %q but found %s", op, describe(t))
+	}
+	return nil
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokNumber:
+		return strconv.FormatFloat(t.number, 'g', -1, 64)
+	case tokString:
+		return strconv.Quote(t.value)
+	case tokVariable:
+		return "$" + t.value
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// infix returns the operator of a token in infix position, if any
+func infix(t token) string {
+	switch t.kind {
+	case tokOperator:
+		return t.value
+	case tokName:
+		if !t.quoted && (t.value == "and" || t.value == "or" || t.value == "in") {
+			return t.value
+		}
+	}
+	return ""
+}
+
+// expression parses an expression whose operators bind more tightly than rbp
+func (p *parser) expression(rbp int) (left node, err error) {
+	left, err = p.prefix(p.advance())
+	if err != nil {
+		return
+	}
+	for rbp < bindingPowers[infix(p.peek())] {
+		left, err = p.infix(p.advance(), left)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// list parses expressions separated by sep up to the closing operator
+func (p *parser) list(sep string, closing string) (exprs []node, err error) {
+	if isOperator(p.peek(), closing) {
+		p.advance()
+		return
+	}
+	for {
+		var n node
+		n, err = p.expression(0)
+		if err != nil {
+			return
+		}
+		exprs = append(exprs, n)
+		t := p.advance()
+		if isOperator(t, closing) {
+			return
+		}
+		if !isOperator(t, sep) {
+			return nil, syntaxError(t.pos, "expected %q or %q but found %s", sep, closing, describe(t))
+		}
+	}
+}
+
+func (p *parser) prefix(t token) (n node, err error) {
+	switch t.kind {
+	case tokNumber:
+		return literalNode{t.number}, nil
+	case tokString:
+		return literalNode{t.value}, nil
+	case tokName:
+		if !t.quoted {
+			switch t.value {
+			case "true":
+				return literalNode{true}, nil
+			case "false":
+				return literalNode{false}, nil
+			case "null":
+				return literalNode{null}, nil
+			}
+		}
+		return fieldNode{t.value}, nil
+	case tokVariable:
+		if t.value != "" && t.value != "$" && isOperator(p.peek(), "(") {
+			p.advance()
+			var args []node
+			args, err = p.list(",", ")")
+			return callNode{name: t.value, args: args, pos: t.pos}, err
+		}
+		return variableNode{t.value}, nil
+	case tokOperator:
+		switch t.value {
+		case "*":
+			return wildcardNode{}, nil
+		case "-":
+			var expr node
+			expr, err = p.expression(70)
+			return negateNode{expr}, err
+		case "(":
+			var exprs []node
+			exprs, err = p.list(";", ")")
+			return blockNode{exprs}, err
+		case "[":
+			var items []node
+			items, err = p.list(",", "]")
+			return arrayNode{items}, err
+		case "{":
+			return p.object()
+		}
+	}
+	return nil, syntaxError(t.pos, "unexpected %s", describe(t))
+}
+
+func (p *parser) object() (n node, err error) {
+	var obj objectNode
+	if isOperator(p.peek(), "}") {
+		p.advance()
+		return obj, nil
+	}
+	for {
+		var key, value node
+		key, err = p.expression(0)
+		if err != nil {
+			return
+		}
+		err = p.expect(":")
+		if err != nil {
+			return
+		}
+		value, err = p.expression(0)
+		if err != nil {
+			return
+		}
+		obj.keys = append(obj.keys, key)
+		obj.values = append(obj.values, value)
+		t := p.advance()
+		if isOperator(t, "}") {
+			return obj, nil
+		}
+		if !isOperator(t, ",") {
+			return nil, syntaxError(t.pos, "expected \",\" or \"}\" but found %s", describe(t))
+		}
+	}
+}
+
+func (p *parser) infix(t token, left node) (n node, err error) {
+	op := infix(t)
+	switch op {
+	case ".":
+		var right node
+		right, err = p.expression(bindingPowers["."])
+		if err != nil {
+			return
+		}
+		if path, isPath := left.(pathNode); isPath {
+			path.steps = append(path.steps, right)
+			return path, nil
+		}
+		return pathNode{steps: []node{left, right}}, nil
+
+	case "[":
+		var predicate node
+		predicate, err = p.expression(0)
+		if err != nil {
+			return
+		}
+		return filterNode{expr: left, predicate: predicate}, p.expect("]")
+
+	case "?":
+		c := conditionNode{condition: left}
+		c.then, err = p.expression(0)
+		if err != nil {
+			return
+		}
+		if isOperator(p.peek(), ":") {
+			p.advance()
+			c.otherwise, err = p.expression(bindingPowers["?"] - 1)
+		}
+		return c, err
+
+	case ":=":
+		v, isVariable := left.(variableNode)
+		if !isVariable || v.name == "" || v.name == "$" {
+			return nil, syntaxError(t.pos, "can only assign to a variable")
+		}
+		var expr node
+		expr, err = p.expression(bindingPowers[":="] - 1)
+		return bindNode{name: v.name, expr: expr}, err
+	}
+
+	var right node
+	right, err = p.expression(bindingPowers[op])
+	return binaryNode{op: op, left: left, right: right}, err
+}
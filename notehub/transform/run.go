@@ -0,0 +1,233 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/blues/note-go/note"
+)
+
+// Case is a captured event and, optionally, the output that the transform is expected
+// to produce from it
+type Case struct {
+	Name  string     `json:"name,omitempty"`
+	Event note.Event `json:"event"`
+	// Expected is the expected output as JSON, or empty if there is no expectation
+	Expected json.RawMessage `json:"expected,omitempty"`
+}
+
+// Result is the outcome of applying a transform to a case
+type Result struct {
+	Name   string
+	Output interface{}
+	Err    error
+	// Expected is set along with Diff if the case has an expected output
+	Expected interface{}
+	Checked  bool
+	// Diff lists the differences between the expected and actual output
+	Diff []string
+}
+
+// Passed returns true if the transform succeeded and produced the expected output
+func (r Result) Passed() bool {
+	return r.Err == nil && len(r.Diff) == 0
+}
+
+// ParseCases parses captured events from a JSON array or from newline-delimited JSON.
+// Each item is either an event or a Case, whose "event" field is an event.
+// Cases are named by their position if they aren't named otherwise.
+func ParseCases(contents []byte) (cases []Case, err error) {
+	var items []json.RawMessage
+	trimmed := bytes.TrimSpace(contents)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		err = note.JSONUnmarshal(trimmed, &items)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse cases: %w", err)
+		}
+	} else {
+		for _, line := range bytes.Split(trimmed, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if len(line) > 0 {
+				items = append(items, json.RawMessage(line))
+			}
+		}
+	}
+
+	for i, item := range items {
+		var fields map[string]json.RawMessage
+		err = note.JSONUnmarshal(item, &fields)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse case %d: %w", i+1, err)
+		}
+		var c Case
+		// An event's own "event" field is its UID, whereas a case's is an object
+		if bytes.HasPrefix(bytes.TrimSpace(fields["event"]), []byte("{")) {
+			err = note.JSONUnmarshal(item, &c)
+		} else {
+			err = note.JSONUnmarshal(item, &c.Event)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse case %d: %w", i+1, err)
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", i+1)
+			if c.Event.EventUID != "" {
+				c.Name += " (" + c.Event.EventUID + ")"
+			}
+		}
+		cases = append(cases, c)
+	}
+	return
+}
+
+// LoadCases reads captured events from a file, as parsed by ParseCases
+func LoadCases(path string) (cases []Case, err error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	cases, err = ParseCases(contents)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return
+}
+
+// Test applies the transform to each case, comparing the output with the expected output
+func (e *Expression) Test(cases []Case) (results []Result) {
+	for _, c := range cases {
+		r := Result{Name: c.Name}
+		r.Output, r.Err = e.Apply(c.Event)
+		if len(c.Expected) > 0 {
+			r.Checked = true
+			var expected interface{}
+			err := note.JSONUnmarshal(c.Expected, &expected)
+			if err != nil {
+				r.Err = fmt.Errorf("cannot parse expected output: %w", err)
+			} else {
+				r.Expected = export(normalize(expected))
+				if r.Err == nil {
+					r.Diff = Diff(r.Expected, r.Output)
+				}
+			}
+		}
+		results = append(results, r)
+	}
+	return
+}
+
+// RunFile applies the transform in one file to the cases in another
+func RunFile(transformPath string, casesPath string) (results []Result, err error) {
+	expr, err := CompileFile(transformPath)
+	if err != nil {
+		return
+	}
+	cases, err := LoadCases(casesPath)
+	if err != nil {
+		return
+	}
+	return expr.Test(cases), nil
+}
+
+// render renders a value compactly as JSON
+func render(v interface{}) string {
+	if v == nil {
+		return "nothing"
+	}
+	contents, err := note.JSONMarshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(contents)
+}
+
+// Diff describes the differences between an expected and an actual output, one per
+// line, each prefixed with the JSON path at which it occurs
+func Diff(expected interface{}, actual interface{}) (diff []string) {
+	return diffAt("$", expected, actual, diff)
+}
+
+func diffAt(path string, expected interface{}, actual interface{}, diff []string) []string {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, isObject := actual.(map[string]interface{})
+		if !isObject {
+			break
+		}
+		names := map[string]interface{}{}
+		for k := range e {
+			names[k] = nil
+		}
+		for k := range a {
+			names[k] = nil
+		}
+		for _, k := range sortedKeys(names) {
+			ev, inExpected := e[k]
+			av, inActual := a[k]
+			switch {
+			case !inActual:
+				diff = append(diff, fmt.Sprintf("%s.%s: missing, expected %s", path, k, render(ev)))
+			case !inExpected:
+				diff = append(diff, fmt.Sprintf("%s.%s: unexpected %s", path, k, render(av)))
+			default:
+				diff = diffAt(path+"."+k, ev, av, diff)
+			}
+		}
+		return diff
+
+	case []interface{}:
+		a, isArray := actual.([]interface{})
+		if !isArray {
+			break
+		}
+		if len(a) != len(e) {
+			return append(diff, fmt.Sprintf("%s: expected %d items, got %d: %s", path, len(e), len(a), render(a)))
+		}
+		for i := range e {
+			diff = diffAt(fmt.Sprintf("%s[%d]", path, i), e[i], a[i], diff)
+		}
+		return diff
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		diff = append(diff, fmt.Sprintf("%s: expected %s, got %s", path, render(expected), render(actual)))
+	}
+	return diff
+}
+
+// WriteResults writes the results for display, showing the differences for those that
+// failed and the output for those without an expected output, followed by a summary.
+// It returns the number of cases that failed.
+func WriteResults(w io.Writer, results []Result) (failed int, err error) {
+	var b strings.Builder
+	passed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Fprintf(&b, "FAIL %s\n    error: %s\n", r.Name, r.Err)
+		case !r.Passed():
+			failed++
+			fmt.Fprintf(&b, "FAIL %s\n", r.Name)
+			for _, line := range r.Diff {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		case r.Checked:
+			passed++
+			fmt.Fprintf(&b, "ok   %s\n", r.Name)
+		default:
+			fmt.Fprintf(&b, "---  %s\n    %s\n", r.Name, render(r.Output))
+		}
+	}
+	fmt.Fprintf(&b, "%d passed, %d failed, %d unchecked\n", passed, failed, len(results)-passed-failed)
+	_, err = io.WriteString(w, b.String())
+	return
+}
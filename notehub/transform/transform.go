@@ -0,0 +1,584 @@
+// Copyright 2025 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+// Package transform evaluates JSONata expressions, such as those configured as event
+// transforms with hub.app.transform.set, so that transforms can be tested against
+// captured events before they are deployed.
+//
+// A practical subset of JSONata is supported: field paths including backquoted names
+// and the * wildcard, $ for the context and $$ for the root, predicates that filter or
+// index, arithmetic, string concatenation with &, comparison, and, or, in, conditions,
+// blocks with variable bindings, array and object construction, and the common string,
+// numeric, aggregate and object functions.  Lambdas, regular expressions, ranges, sorting
+// and grouping are not supported.  Because JSON objects are decoded without their order,
+// the wildcard visits fields in order of name.
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/blues/note-go/note"
+)
+
+// nullValue represents a JSON null, which is distinct from a missing value, which is
+// represented by nil
+type nullValue struct{}
+
+var null = nullValue{}
+
+// Expression is a compiled transform
+type Expression struct {
+	source string
+	root   node
+}
+
+// Compile parses the source of a transform
+func Compile(source string) (expr *Expression, err error) {
+	root, err := parse(source)
+	if err != nil {
+		return
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// CompileFile parses the transform in a file
+func CompileFile(path string) (expr *Expression, err error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	expr, err = Compile(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return
+}
+
+// String returns the source of the transform
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Evaluate evaluates the transform against an input, which may be any value that can be
+// marshaled as JSON.  The output is composed of the types produced by unmarshaling JSON
+// into an interface{}, with numbers as float64, and is nil if the transform produces
+// nothing.
+func (e *Expression) Evaluate(input interface{}) (output interface{}, err error) {
+	contents, err := note.JSONMarshal(input)
+	if err != nil {
+		return
+	}
+	return e.EvaluateJSON(contents)
+}
+
+// EvaluateJSON evaluates the transform against JSON input
+func (e *Expression) EvaluateJSON(input []byte) (output interface{}, err error) {
+	var decoded interface{}
+	err = note.JSONUnmarshal(input, &decoded)
+	if err != nil {
+		return
+	}
+	root := normalize(decoded)
+	output, err = eval(e.root, root, &scope{root: root})
+	if err != nil {
+		return nil, err
+	}
+	return export(output), nil
+}
+
+// Apply evaluates the transform against an event, as the notehub does before routing it
+func (e *Expression) Apply(event note.Event) (output interface{}, err error) {
+	return e.Evaluate(event)
+}
+
+// normalize converts decoded JSON to the values used during evaluation
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return null
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, item := range v {
+			a[i] = normalize(item)
+		}
+		return a
+	}
+	return v
+}
+
+// export converts the values used during evaluation to those of decoded JSON
+func export(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nullValue:
+		return nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = export(item)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, item := range v {
+			a[i] = export(item)
+		}
+		return a
+	}
+	return v
+}
+
+// scope holds the variables bound within a block
+type scope struct {
+	vars   map[string]interface{}
+	parent *scope
+	root   interface{}
+}
+
+func (s *scope) lookup(name string) interface{} {
+	for ; s != nil; s = s.parent {
+		if v, present := s.vars[name]; present {
+			return v
+		}
+	}
+	return nil
+}
+
+func (s *scope) bind(name string, v interface{}) {
+	if s.vars == nil {
+		s.vars = map[string]interface{}{}
+	}
+	s.vars[name] = v
+}
+
+// sequence returns the items of a value, which are the elements of an array
+func sequence(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	return []interface{}{v}
+}
+
+// collapse returns the value of a sequence: nothing if empty, the item itself if it has
+// one item, and otherwise an array
+func collapse(items []interface{}) interface{} {
+	switch len(items) {
+	case 0:
+		return nil
+	case 1:
+		return items[0]
+	}
+	return items
+}
+
+// appendFlat appends a value to a sequence, flattening an array
+func appendFlat(items []interface{}, v interface{}) []interface{} {
+	if a, isArray := v.([]interface{}); isArray {
+		return append(items, a...)
+	}
+	if v == nil {
+		return items
+	}
+	return append(items, v)
+}
+
+// lookupField returns a field of an object, or of each object in an array
+func lookupField(ctx interface{}, name string) interface{} {
+	switch ctx := ctx.(type) {
+	case map[string]interface{}:
+		return ctx[name]
+	case []interface{}:
+		var items []interface{}
+		for _, item := range ctx {
+			items = appendFlat(items, lookupField(item, name))
+		}
+		return collapse(items)
+	}
+	return nil
+}
+
+// wildcard returns the values of every field of an object, in order of name
+func wildcard(ctx interface{}) interface{} {
+	switch ctx := ctx.(type) {
+	case map[string]interface{}:
+		var items []interface{}
+		for _, k := range sortedKeys(ctx) {
+			items = appendFlat(items, ctx[k])
+		}
+		return collapse(items)
+	case []interface{}:
+		var items []interface{}
+		for _, item := range ctx {
+			items = appendFlat(items, wildcard(item))
+		}
+		return collapse(items)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// truthy returns the boolean value of a value, as JSONata's $boolean
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil, nullValue:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		for _, item := range v {
+			if truthy(item) {
+				return true
+			}
+		}
+		return false
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return false
+}
+
+// typeName names the type of a value for errors
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "undefined"
+	case nullValue:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// number checks that a result of arithmetic is finite
+func number(f float64) (interface{}, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("transform: number out of range")
+	}
+	return f, nil
+}
+
+func eval(n node, ctx interface{}, s *scope) (v interface{}, err error) {
+	switch n := n.(type) {
+	case literalNode:
+		return n.value, nil
+
+	case fieldNode:
+		return lookupField(ctx, n.name), nil
+
+	case wildcardNode:
+		return wildcard(ctx), nil
+
+	case variableNode:
+		switch n.name {
+		case "":
+			return ctx, nil
+		case "$":
+			return s.root, nil
+		}
+		return s.lookup(n.name), nil
+
+	case pathNode:
+		v, err = eval(n.steps[0], ctx, s)
+		for _, step := range n.steps[1:] {
+			if err != nil || v == nil {
+				return
+			}
+			items, isArray := v.([]interface{})
+			if !isArray {
+				v, err = eval(step, v, s)
+				continue
+			}
+			var results []interface{}
+			for _, item := range items {
+				var r interface{}
+				r, err = eval(step, item, s)
+				if err != nil {
+					return
+				}
+				results = appendFlat(results, r)
+			}
+			v = collapse(results)
+		}
+		return
+
+	case filterNode:
+		v, err = eval(n.expr, ctx, s)
+		if err != nil || v == nil {
+			return
+		}
+		items := sequence(v)
+		var kept []interface{}
+		for i, item := range items {
+			var p interface{}
+			p, err = eval(n.predicate, item, s)
+			if err != nil {
+				return
+			}
+			if index, isNumber := p.(float64); isNumber {
+				index = math.Floor(index)
+				if index < 0 {
+					index += float64(len(items))
+				}
+				if index == float64(i) {
+					kept = append(kept, item)
+				}
+			} else if truthy(p) {
+				kept = append(kept, item)
+			}
+		}
+		return collapse(kept), nil
+
+	case negateNode:
+		v, err = eval(n.expr, ctx, s)
+		if err != nil || v == nil {
+			return
+		}
+		f, isNumber := v.(float64)
+		if !isNumber {
+			return nil, fmt.Errorf("transform: cannot negate %s", typeName(v))
+		}
+		return -f, nil
+
+	case binaryNode:
+		return evalBinary(n, ctx, s)
+
+	case conditionNode:
+		v, err = eval(n.condition, ctx, s)
+		if err != nil {
+			return
+		}
+		if truthy(v) {
+			return eval(n.then, ctx, s)
+		}
+		if n.otherwise == nil {
+			return nil, nil
+		}
+		return eval(n.otherwise, ctx, s)
+
+	case blockNode:
+		inner := &scope{parent: s, root: s.root}
+		for _, expr := range n.exprs {
+			v, err = eval(expr, ctx, inner)
+			if err != nil {
+				return
+			}
+		}
+		return
+
+	case bindNode:
+		v, err = eval(n.expr, ctx, s)
+		if err == nil {
+			s.bind(n.name, v)
+		}
+		return
+
+	case callNode:
+		fn, present := functions[n.name]
+		if !present {
+			return nil, fmt.Errorf("transform: unknown function $%s at position %d", n.name, n.pos)
+		}
+		args := make([]interface{}, len(n.args))
+		for i, arg := range n.args {
+			args[i], err = eval(arg, ctx, s)
+			if err != nil {
+				return
+			}
+		}
+		v, err = fn(ctx, args)
+		if err != nil {
+			return nil, fmt.Errorf("transform: $%s: %w", n.name, err)
+		}
+		return
+
+	case arrayNode:
+		// As in JSONata, the items of a sequence are flattened into the array, while an
+		// array constructed within it remains nested
+		items := []interface{}{}
+		for _, item := range n.items {
+			v, err = eval(item, ctx, s)
+			if err != nil {
+				return
+			}
+			if _, nested := item.(arrayNode); nested {
+				items = append(items, v)
+			} else {
+				items = appendFlat(items, v)
+			}
+		}
+		return items, nil
+
+	case objectNode:
+		obj := map[string]interface{}{}
+		for i := range n.keys {
+			var key interface{}
+			key, err = eval(n.keys[i], ctx, s)
+			if err != nil {
+				return
+			}
+			name, isString := key.(string)
+			if !isString {
+				return nil, fmt.Errorf("transform: object key must be a string, not %s", typeName(key))
+			}
+			v, err = eval(n.values[i], ctx, s)
+			if err != nil {
+				return
+			}
+			if v != nil {
+				obj[name] = v
+			}
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("transform: cannot evaluate %T", n)
+}
+
+func evalBinary(n binaryNode, ctx interface{}, s *scope) (v interface{}, err error) {
+	left, err := eval(n.left, ctx, s)
+	if err != nil {
+		return
+	}
+	switch n.op {
+	case "and":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := eval(n.right, ctx, s)
+		return truthy(right), err
+	case "or":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := eval(n.right, ctx, s)
+		return truthy(right), err
+	}
+
+	right, err := eval(n.right, ctx, s)
+	if err != nil {
+		return
+	}
+	switch n.op {
+	case "&":
+		l, _ := stringOf(left)
+		r, _ := stringOf(right)
+		return l + r, nil
+
+	case "+", "-", "*", "/", "%":
+		if left == nil || right == nil {
+			return nil, nil
+		}
+		l, lok := left.(float64)
+		r, rok := right.(float64)
+		if !lok || !rok {
+			return nil, fmt.Errorf("transform: cannot apply %s to %s and %s", n.op, typeName(left), typeName(right))
+		}
+		switch n.op {
+		case "+":
+			return number(l + r)
+		case "-":
+			return number(l - r)
+		case "*":
+			return number(l * r)
+		case "/":
+			return number(l / r)
+		}
+		return number(math.Mod(l, r))
+
+	case "=", "!=":
+		if left == nil || right == nil {
+			return false, nil
+		}
+		equal := reflect.DeepEqual(left, right)
+		return equal == (n.op == "="), nil
+
+	case "<", "<=", ">", ">=":
+		if left == nil || right == nil {
+			return false, nil
+		}
+		var c int
+		switch l := left.(type) {
+		case float64:
+			r, isNumber := right.(float64)
+			if !isNumber {
+				return nil, fmt.Errorf("transform: cannot compare number with %s", typeName(right))
+			}
+			c = compareNumbers(l, r)
+		case string:
+			r, isString := right.(string)
+			if !isString {
+				return nil, fmt.Errorf("transform: cannot compare string with %s", typeName(right))
+			}
+			c = strings.Compare(l, r)
+		default:
+			return nil, fmt.Errorf("transform: cannot compare %s", typeName(left))
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+
+	case "in":
+		if left == nil {
+			return false, nil
+		}
+		for _, item := range sequence(right) {
+			if reflect.DeepEqual(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return nil, fmt.Errorf("transform: unknown operator %s", n.op)
+}
+
+func compareNumbers(l float64, r float64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package transform
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/blues/note-go/note"
+	"github.com/stretchr/testify/require"
+)
+
+const testInput = `{
+	"event": "e1",
+	"device": "dev:864475040000000",
+	"file": "data.qo",
+	"when": 1700000000,
+	"body": {
+		"temp": 21.5,
+		"voltage": 3.71,
+		"name": "  north   tank ",
+		"readings": [
+			{"sensor": "a", "value": 10},
+			{"sensor": "b", "value": 20},
+			{"sensor": "c", "value": 30}
+		],
+		"tags": ["x", "y"],
+		"empty": null
+	}
+}`
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`body.temp`, 21.5},
+		{`body.missing`, nil},
+		{`body.empty`, nil},
+		{`$exists(body.empty)`, true},
+		{`body.readings.value`, []interface{}{10.0, 20.0, 30.0}},
+		{`body.readings[1].sensor`, "b"},
+		{`body.readings[-1].value`, 30.0},
+		{`body.readings[value > 15].sensor`, []interface{}{"b", "c"}},
+		{`body.readings[sensor = "a"].value`, 10.0},
+		{`body.tags`, []interface{}{"x", "y"}},
+		{`body.tags[0]`, "x"},
+		{`$sum(body.readings.value)`, 60.0},
+		{`$count(body.readings)`, 3.0},
+		{`$average(body.readings.value)`, 20.0},
+		{`$max(body.readings.value) - $min(body.readings.value)`, 20.0},
+		{`body.temp * 9 / 5 + 32`, 70.7},
+		{`-body.temp`, -21.5},
+		{`7 % 3`, 1.0},
+		{`2 + 3 * 4`, 14.0},
+		{`(2 + 3) * 4`, 20.0},
+		{`$round(body.voltage, 1)`, 3.7},
+		{`$round(2.5)`, 2.0},
+		{`$string(0.1 + 0.2)`, "0.3"},
+		{`$string(body.temp) & "C"`, "21.5C"},
+		{`"t=" & body.temp & body.missing`, "t=21.5"},
+		{`$uppercase(file)`, "DATA.QO"},
+		{`$trim(body.name)`, "north tank"},
+		{`$substring(device, 4)`, "864475040000000"},
+		{`$substring(device, -4, 2)`, "00"},
+		{`$substringBefore(file, ".")`, "data"},
+		{`$substringAfter(device, ":")`, "864475040000000"},
+		{`$contains(file, "qo")`, true},
+		{`$split("a,b,c", ",", 2)`, []interface{}{"a", "b"}},
+		{`$join(body.tags, "-")`, "x-y"},
+		{`$replace(file, ".", "_")`, "data_qo"},
+		{`$number("42") + 1`, 43.0},
+		{`$length(file)`, 7.0},
+		{`body.temp > 20 and body.voltage < 3.5`, false},
+		{`body.temp > 20 or body.voltage < 3.5`, true},
+		{`"y" in body.tags`, true},
+		{`body.temp > 30 ? "hot" : body.temp > 20 ? "warm" : "cold"`, "warm"},
+		{`body.temp > 30 ? "hot"`, nil},
+		{`($t := body.temp; $t * 2)`, 43.0},
+		{`body.readings[0].$.value`, 10.0},
+		{`body.readings.($$.file & ":" & sensor)`, []interface{}{"data.qo:a", "data.qo:b", "data.qo:c"}},
+		{`$keys(body.readings[0])`, []interface{}{"sensor", "value"}},
+		{`$lookup(body, "temp")`, 21.5},
+		{`$merge([{"a": 1}, {"b": 2}])`, map[string]interface{}{"a": 1.0, "b": 2.0}},
+		{`$append(body.tags, "z")`, []interface{}{"x", "y", "z"}},
+		{`$fromMillis(when * 1000)`, "2023-11-14T22:13:20.000Z"},
+		{`$toMillis("2023-11-14T22:13:20Z") / 1000 = when`, true},
+		{`$count(body.*)`, 9.0},
+		{"`body`.`temp`", 21.5},
+		{`/* comment */ null`, nil},
+		{`{"device": device, "temp": body.temp, "gone": body.missing, "sensors": body.readings.sensor}`,
+			map[string]interface{}{"device": "dev:864475040000000", "temp": 21.5, "sensors": []interface{}{"a", "b", "c"}}},
+		{`[body.temp, body.missing, "x"]`, []interface{}{21.5, "x"}},
+		{`[body.tags]`, []interface{}{"x", "y"}},
+		{`[body.tags, body.readings[0].sensor]`, []interface{}{"x", "y", "a"}},
+		{`[[1, 2], []]`, []interface{}{[]interface{}{1.0, 2.0}, []interface{}{}}},
+	}
+	for _, test := range tests {
+		expr, err := Compile(test.expr)
+		require.NoError(t, err, test.expr)
+		output, err := expr.EvaluateJSON([]byte(testInput))
+		require.NoError(t, err, test.expr)
+		require.Equal(t, test.expected, output, test.expr)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, src := range []string{``, `body.`, `(1; 2`, `{"a" 1}`, `"open`, `1 := 2`, `#`} {
+		_, err := Compile(src)
+		require.Error(t, err, src)
+		require.True(t, note.ErrorContains(err, note.ErrSyntax), src)
+	}
+	for _, src := range []string{`"a" + 1`, `1 / 0`, `$nope()`, `{body.temp: 1}`, `1 < "a"`} {
+		expr, err := Compile(src)
+		require.NoError(t, err, src)
+		_, err = expr.EvaluateJSON([]byte(testInput))
+		require.Error(t, err, src)
+	}
+}
+
+func TestApply(t *testing.T) {
+	expr, err := Compile(`{"id": $substringAfter(device, ":"), "temp": body.temp}`)
+	require.NoError(t, err)
+	body := map[string]interface{}{"temp": 19}
+	output, err := expr.Apply(note.Event{DeviceUID: "dev:1", Body: &body})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": "1", "temp": 19.0}, output)
+}
+
+func TestDiff(t *testing.T) {
+	expected := map[string]interface{}{"a": 1.0, "b": []interface{}{1.0, 2.0}, "c": "x"}
+	actual := map[string]interface{}{"a": 2.0, "b": []interface{}{1.0}, "d": true}
+	require.Equal(t, []string{
+		`$.a: expected 1, got 2`,
+		`$.b: expected 2 items, got 1: [1]`,
+		`$.c: missing, expected "x"`,
+		`$.d: unexpected true`,
+	}, Diff(expected, actual))
+	require.Empty(t, Diff(expected, expected))
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	transformPath := filepath.Join(dir, "transform.jsonata")
+	casesPath := filepath.Join(dir, "events.json")
+	require.NoError(t, ioutil.WriteFile(transformPath, []byte(`{"temp_f": body.temp * 9 / 5 + 32}`), 0600))
+	require.NoError(t, ioutil.WriteFile(casesPath, []byte(`[
+		{"name": "freezing", "event": {"body": {"temp": 0}}, "expected": {"temp_f": 32}},
+		{"name": "wrong", "event": {"body": {"temp": 100}}, "expected": {"temp_f": 100}},
+		{"event": "e3", "body": {"temp": 10}}
+	]`), 0600))
+
+	results, err := RunFile(transformPath, casesPath)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.True(t, results[0].Passed())
+	require.False(t, results[1].Passed())
+	require.Equal(t, []string{"$.temp_f: expected 100, got 212"}, results[1].Diff)
+	require.Equal(t, "case 3 (e3)", results[2].Name)
+
+	var out bytes.Buffer
+	failed, err := WriteResults(&out, results)
+	require.NoError(t, err)
+	require.Equal(t, 1, failed)
+	require.Equal(t, "ok   freezing\n"+
+		"FAIL wrong\n    $.temp_f: expected 100, got 212\n"+
+		"---  case 3 (e3)\n    {\"temp_f\":50}\n"+
+		"1 passed, 1 failed, 1 unchecked\n", out.String())
+
+	// Newline-delimited events are also accepted
+	cases, err := ParseCases([]byte("{\"event\":\"a\"}\n\n{\"event\":\"b\"}\n"))
+	require.NoError(t, err)
+	require.Len(t, cases, 2)
+	require.Equal(t, "b", cases[1].Event.EventUID)
+}